@@ -3,9 +3,12 @@ module github.com/tionis/gyroskop
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/lib/pq v1.10.9
 	github.com/lithammer/fuzzysearch v1.1.8
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require golang.org/x/text v0.9.0 // indirect
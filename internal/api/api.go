@@ -0,0 +1,367 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/bot"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/export"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Store is the subset of database.DB used by the API
+type Store interface {
+	GetAPIToken(ctx context.Context, token string) (*database.APIToken, error)
+	GetGyroskopByID(ctx context.Context, gyroskopID int) (*database.Gyroskop, error)
+	GetGyroskopsByChat(ctx context.Context, chatID int64, from, to time.Time) ([]database.Gyroskop, error)
+	GetOrdersByGyroskop(ctx context.Context, gyroskopID int) ([]database.Order, error)
+	GetOrder(ctx context.Context, gyroskopID int, userID int64) (*database.Order, error)
+}
+
+// Backend performs the operations that also have to update Telegram, implemented by bot.Bot
+type Backend interface {
+	OpenGyroskop(ctx context.Context, chatID, createdBy int64, creatorName, name string, foodOptions []string, deadline time.Time) (*database.Gyroskop, error)
+	CloseGyroskop(ctx context.Context, chatID int64, gyroskopID int) error
+	PlaceOrder(ctx context.Context, chatID int64, gyroskopID int, userID int64, username, firstName, lastName string, quantities map[string]int) error
+}
+
+// Server serves the REST API for the gyroskops of a chat
+type Server struct {
+	store   Store
+	backend Backend
+	log     *slog.Logger
+}
+
+// maxBodyBytes limits the size of request bodies
+const maxBodyBytes = 16 << 10
+
+// createGyroskopRequest is the body of POST /gyroskops
+type createGyroskopRequest struct {
+	Name        string     `json:"name"`
+	FoodOptions []string   `json:"food_options"`
+	Deadline    *time.Time `json:"deadline"`
+}
+
+// placeOrderRequest is the body of POST /gyroskops/{id}/orders
+type placeOrderRequest struct {
+	UserID     int64          `json:"user_id"`
+	Username   string         `json:"username"`
+	FirstName  string         `json:"first_name"`
+	LastName   string         `json:"last_name"`
+	Quantities map[string]int `json:"quantities"`
+}
+
+// hiddenOrdersResponse is returned by GET /gyroskops/{id}/orders instead of
+// the orders of a hidden gyroskop, which only its creator may see
+type hiddenOrdersResponse struct {
+	Participants int            `json:"participants"`
+	Totals       map[string]int `json:"totals"`
+}
+
+// New creates a new API server
+func New(store Store, backend Backend, logger *slog.Logger) *Server {
+	return &Server{store: store, backend: backend, log: logger}
+}
+
+// Handler returns the HTTP handler serving the API below /api/v1/
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/api/v1/gyroskops", s.authenticated(s.handleGyroskops))
+	mux.HandleFunc("/api/v1/gyroskops/", s.authenticated(s.handleGyroskop))
+	return mux
+}
+
+// authenticated resolves the bearer token to a chat and passes it to the handler
+func (s *Server) authenticated(next func(http.ResponseWriter, *http.Request, *database.APIToken)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			s.writeError(w, r, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		apiToken, err := s.store.GetAPIToken(r.Context(), token)
+		if errors.Is(err, sql.ErrNoRows) {
+			s.writeError(w, r, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "Error checking API token", "error", err)
+			s.writeError(w, r, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		next(w, r, apiToken)
+	}
+}
+
+// handleOpenAPI serves the OpenAPI description
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// handleGyroskops handles /api/v1/gyroskops
+func (s *Server) handleGyroskops(w http.ResponseWriter, r *http.Request, token *database.APIToken) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listGyroskops(w, r, token)
+	case http.MethodPost:
+		s.createGyroskop(w, r, token)
+	default:
+		s.writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleGyroskop handles /api/v1/gyroskops/{id}[/close|/orders]
+func (s *Server) handleGyroskop(w http.ResponseWriter, r *http.Request, token *database.APIToken) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/gyroskops/"), "/"), "/")
+
+	gyroskopID, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 {
+		s.writeError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+
+	gyroskop, err := s.store.GetGyroskopByID(r.Context(), gyroskopID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && gyroskop.ChatID != token.ChatID) {
+		s.writeError(w, r, http.StatusNotFound, "gyroskop not found")
+		return
+	}
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "Error loading gyroskop", "gyroskop_id", gyroskopID, "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		s.writeJSON(w, r, http.StatusOK, gyroskop)
+	case action == "close" && r.Method == http.MethodPost:
+		s.closeGyroskop(w, r, gyroskop)
+	case action == "orders" && r.Method == http.MethodGet:
+		s.listOrders(w, r, gyroskop)
+	case action == "orders" && r.Method == http.MethodPost:
+		s.placeOrder(w, r, gyroskop)
+	case action == "" || action == "close" || action == "orders":
+		s.writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		s.writeError(w, r, http.StatusNotFound, "not found")
+	}
+}
+
+// listGyroskops returns the gyroskops of the chat, optionally filtered by
+// creation time (from/to as RFC 3339) and open state
+func (s *Server) listGyroskops(w http.ResponseWriter, r *http.Request, token *database.APIToken) {
+	query := r.URL.Query()
+
+	from := time.Time{}
+	to := time.Now().Add(24 * time.Hour)
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "invalid from, expected RFC 3339")
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "invalid to, expected RFC 3339")
+			return
+		}
+	}
+
+	gyroskops, err := s.store.GetGyroskopsByChat(r.Context(), token.ChatID, from, to)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "Error listing gyroskops", "chat_id", token.ChatID, "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if value := query.Get("open"); value != "" {
+		open, err := strconv.ParseBool(value)
+		if err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "invalid open, expected true or false")
+			return
+		}
+		filtered := gyroskops[:0]
+		for _, gyroskop := range gyroskops {
+			if gyroskop.IsOpen == open {
+				filtered = append(filtered, gyroskop)
+			}
+		}
+		gyroskops = filtered
+	}
+
+	if gyroskops == nil {
+		gyroskops = []database.Gyroskop{}
+	}
+	s.writeJSON(w, r, http.StatusOK, gyroskops)
+}
+
+// createGyroskop opens a new gyroskop in the chat
+func (s *Server) createGyroskop(w http.ResponseWriter, r *http.Request, token *database.APIToken) {
+	var req createGyroskopRequest
+	if !s.decodeBody(w, r, &req) {
+		return
+	}
+
+	// A zero deadline makes the bot use its default duration
+	var deadline time.Time
+	if req.Deadline != nil {
+		deadline = req.Deadline.UTC()
+		if !deadline.After(time.Now()) {
+			s.writeError(w, r, http.StatusBadRequest, "deadline must be in the future")
+			return
+		}
+	}
+
+	var foodOptions []string
+	for _, option := range req.FoodOptions {
+		if option = strings.TrimSpace(option); option != "" {
+			foodOptions = append(foodOptions, option)
+		}
+	}
+
+	gyroskop, err := s.backend.OpenGyroskop(r.Context(), token.ChatID, token.CreatedBy, token.CreatedByName, strings.TrimSpace(req.Name), foodOptions, deadline)
+	if err != nil {
+		s.writeBackendError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusCreated, gyroskop)
+}
+
+// closeGyroskop closes the gyroskop and returns its final state
+func (s *Server) closeGyroskop(w http.ResponseWriter, r *http.Request, gyroskop *database.Gyroskop) {
+	if err := s.backend.CloseGyroskop(r.Context(), gyroskop.ChatID, gyroskop.ID); err != nil {
+		s.writeBackendError(w, r, err)
+		return
+	}
+
+	gyroskop.IsOpen = false
+	gyroskop.State = database.StateClosed
+	s.writeJSON(w, r, http.StatusOK, gyroskop)
+}
+
+// listOrders returns all non-empty orders of the gyroskop. Of a hidden
+// gyroskop only the totals and the number of participants are returned.
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request, gyroskop *database.Gyroskop) {
+	orders, err := s.store.GetOrdersByGyroskop(r.Context(), gyroskop.ID)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "Error listing orders", "gyroskop_id", gyroskop.ID, "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if gyroskop.Hidden {
+		totals, _ := export.Totals(orders)
+		s.writeJSON(w, r, http.StatusOK, hiddenOrdersResponse{Participants: len(orders), Totals: totals})
+		return
+	}
+
+	if orders == nil {
+		orders = []database.Order{}
+	}
+	s.writeJSON(w, r, http.StatusOK, orders)
+}
+
+// placeOrder adds or replaces the order of a user
+func (s *Server) placeOrder(w http.ResponseWriter, r *http.Request, gyroskop *database.Gyroskop) {
+	var req placeOrderRequest
+	if !s.decodeBody(w, r, &req) {
+		return
+	}
+
+	if req.UserID == 0 {
+		s.writeError(w, r, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if req.Quantities == nil {
+		req.Quantities = map[string]int{}
+	}
+
+	err := s.backend.PlaceOrder(r.Context(), gyroskop.ChatID, gyroskop.ID, req.UserID, req.Username, req.FirstName, req.LastName, req.Quantities)
+	if err != nil {
+		s.writeBackendError(w, r, err)
+		return
+	}
+
+	order, err := s.store.GetOrder(r.Context(), gyroskop.ID, req.UserID)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "Error loading order", "gyroskop_id", gyroskop.ID, "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, order)
+}
+
+// decodeBody decodes a JSON request body of at most maxBodyBytes into v and
+// writes an error response if it is too large, malformed or has unknown fields
+func (s *Server) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		s.writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+		return false
+	case err != nil:
+		s.writeError(w, r, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeBackendError maps errors of the bot operations to HTTP status codes
+func (s *Server) writeBackendError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, bot.ErrInvalidOrder):
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, bot.ErrNotPermitted):
+		s.writeError(w, r, http.StatusForbidden, err.Error())
+	case errors.Is(err, bot.ErrGyroskopActive), errors.Is(err, bot.ErrGyroskopNotActive), errors.Is(err, bot.ErrGyroskopExpired):
+		s.writeError(w, r, http.StatusConflict, err.Error())
+	default:
+		s.log.ErrorContext(r.Context(), "Error in API operation", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// writeJSON writes a JSON response
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.ErrorContext(r.Context(), "Error writing API response", "error", err)
+	}
+}
+
+// writeError writes a JSON error response
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	s.writeJSON(w, r, status, map[string]string{"error": message})
+}
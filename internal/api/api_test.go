@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/bot"
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+const testToken = "gyr_test"
+
+type fakeStore struct {
+	gyroskops map[int]*database.Gyroskop
+	orders    map[int][]database.Order
+}
+
+func (s *fakeStore) GetAPIToken(ctx context.Context, token string) (*database.APIToken, error) {
+	if token != testToken {
+		return nil, sql.ErrNoRows
+	}
+	return &database.APIToken{ChatID: 100, CreatedBy: 1, CreatedByName: "Anna"}, nil
+}
+
+func (s *fakeStore) GetGyroskopByID(ctx context.Context, gyroskopID int) (*database.Gyroskop, error) {
+	g, ok := s.gyroskops[gyroskopID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	gyroskop := *g
+	return &gyroskop, nil
+}
+
+func (s *fakeStore) GetGyroskopsByChat(ctx context.Context, chatID int64, from, to time.Time) ([]database.Gyroskop, error) {
+	var gyroskops []database.Gyroskop
+	for id := 1; id <= len(s.gyroskops); id++ {
+		if g, ok := s.gyroskops[id]; ok && g.ChatID == chatID {
+			gyroskops = append(gyroskops, *g)
+		}
+	}
+	return gyroskops, nil
+}
+
+func (s *fakeStore) GetOrdersByGyroskop(ctx context.Context, gyroskopID int) ([]database.Order, error) {
+	return s.orders[gyroskopID], nil
+}
+
+func (s *fakeStore) GetOrder(ctx context.Context, gyroskopID int, userID int64) (*database.Order, error) {
+	for _, order := range s.orders[gyroskopID] {
+		if order.UserID == userID {
+			return &order, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type fakeBackend struct {
+	store *fakeStore
+}
+
+func (b *fakeBackend) OpenGyroskop(ctx context.Context, chatID, createdBy int64, creatorName, name string, foodOptions []string, deadline time.Time) (*database.Gyroskop, error) {
+	if name == "Verboten" {
+		return nil, bot.ErrNotPermitted
+	}
+	if deadline.IsZero() {
+		deadline = time.Now().Add(15 * time.Minute)
+	}
+	g := &database.Gyroskop{ID: len(b.store.gyroskops) + 1, ChatID: chatID, CreatedBy: createdBy, Name: name, FoodOptions: foodOptions, Deadline: deadline, IsOpen: true}
+	b.store.gyroskops[g.ID] = g
+	return g, nil
+}
+
+func (b *fakeBackend) CloseGyroskop(ctx context.Context, chatID int64, gyroskopID int) error {
+	g := b.store.gyroskops[gyroskopID]
+	if !g.IsOpen {
+		return bot.ErrGyroskopNotActive
+	}
+	g.IsOpen = false
+	return nil
+}
+
+func (b *fakeBackend) PlaceOrder(ctx context.Context, chatID int64, gyroskopID int, userID int64, username, firstName, lastName string, quantities map[string]int) error {
+	if _, ok := quantities["Unbekannt"]; ok {
+		return bot.ErrInvalidOrder
+	}
+	b.store.orders[gyroskopID] = append(b.store.orders[gyroskopID], database.Order{GyroskopID: gyroskopID, UserID: userID, FirstName: firstName, Quantities: quantities})
+	return nil
+}
+
+func newTestServer() http.Handler {
+	store := &fakeStore{
+		gyroskops: map[int]*database.Gyroskop{
+			1: {ID: 1, ChatID: 100, Name: "Gyros", FoodOptions: []string{"Fleisch"}, IsOpen: true},
+			2: {ID: 2, ChatID: 200, Name: "Fremd", FoodOptions: []string{"Fleisch"}, IsOpen: true},
+		},
+		orders: map[int][]database.Order{},
+	}
+	return New(store, &fakeBackend{store: store}, slog.New(slog.NewTextHandler(io.Discard, nil))).Handler()
+}
+
+func doRequest(handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIStatusCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"openapi without token", http.MethodGet, "/api/v1/openapi.json", "", "", http.StatusOK},
+		{"missing token", http.MethodGet, "/api/v1/gyroskops", "", "", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/api/v1/gyroskops", "wrong", "", http.StatusUnauthorized},
+		{"list", http.MethodGet, "/api/v1/gyroskops?open=true", testToken, "", http.StatusOK},
+		{"list invalid filter", http.MethodGet, "/api/v1/gyroskops?open=vielleicht", testToken, "", http.StatusBadRequest},
+		{"get", http.MethodGet, "/api/v1/gyroskops/1", testToken, "", http.StatusOK},
+		{"get other chat", http.MethodGet, "/api/v1/gyroskops/2", testToken, "", http.StatusNotFound},
+		{"get unknown", http.MethodGet, "/api/v1/gyroskops/99", testToken, "", http.StatusNotFound},
+		{"get invalid id", http.MethodGet, "/api/v1/gyroskops/abc", testToken, "", http.StatusNotFound},
+		{"delete not allowed", http.MethodDelete, "/api/v1/gyroskops/1", testToken, "", http.StatusMethodNotAllowed},
+		{"create past deadline", http.MethodPost, "/api/v1/gyroskops", testToken, `{"deadline": "2000-01-01T12:00:00Z"}`, http.StatusBadRequest},
+		{"create not permitted", http.MethodPost, "/api/v1/gyroskops", testToken, `{"name": "Verboten"}`, http.StatusForbidden},
+		{"create invalid json", http.MethodPost, "/api/v1/gyroskops", testToken, `{`, http.StatusBadRequest},
+		{"create unknown field", http.MethodPost, "/api/v1/gyroskops", testToken, `{"nmae": "Pizza"}`, http.StatusBadRequest},
+		{"create body too large", http.MethodPost, "/api/v1/gyroskops", testToken, `{"name": "` + strings.Repeat("x", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge},
+		{"order unknown field", http.MethodPost, "/api/v1/gyroskops/1/orders", testToken, `{"user_id": 5, "quantity": {"Fleisch": 1}}`, http.StatusBadRequest},
+		{"order without user", http.MethodPost, "/api/v1/gyroskops/1/orders", testToken, `{"quantities": {"Fleisch": 1}}`, http.StatusBadRequest},
+		{"order unknown option", http.MethodPost, "/api/v1/gyroskops/1/orders", testToken, `{"user_id": 5, "quantities": {"Unbekannt": 1}}`, http.StatusBadRequest},
+		{"unknown action", http.MethodPost, "/api/v1/gyroskops/1/reopen", testToken, "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(newTestServer(), tt.method, tt.path, tt.token, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d, want %d (body: %s)", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIOrderFlow(t *testing.T) {
+	handler := newTestServer()
+
+	rec := doRequest(handler, http.MethodPost, "/api/v1/gyroskops", testToken, `{"name": "Pizza", "food_options": ["Margherita", " "]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var gyroskop database.Gyroskop
+	if err := json.Unmarshal(rec.Body.Bytes(), &gyroskop); err != nil {
+		t.Fatalf("create returned invalid JSON: %v", err)
+	}
+	if gyroskop.ChatID != 100 || gyroskop.CreatedBy != 1 || len(gyroskop.FoodOptions) != 1 {
+		t.Errorf("create returned %+v", gyroskop)
+	}
+	if !gyroskop.Deadline.After(time.Now()) {
+		t.Errorf("create should default to a deadline in the future, got %v", gyroskop.Deadline)
+	}
+
+	path := fmt.Sprintf("/api/v1/gyroskops/%d", gyroskop.ID)
+	rec = doRequest(handler, http.MethodPost, path+"/orders", testToken, `{"user_id": 5, "first_name": "Ben", "quantities": {"Margherita": 2}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("place order = %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(handler, http.MethodGet, path+"/orders", testToken, "")
+	var orders []database.Order
+	if err := json.Unmarshal(rec.Body.Bytes(), &orders); err != nil || len(orders) != 1 || orders[0].Quantities["Margherita"] != 2 {
+		t.Errorf("list orders = %s", rec.Body.String())
+	}
+
+	rec = doRequest(handler, http.MethodPost, path+"/close", testToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("close = %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var closed database.Gyroskop
+	if err := json.Unmarshal(rec.Body.Bytes(), &closed); err != nil || closed.IsOpen || closed.State != database.StateClosed {
+		t.Errorf("close returned %s", rec.Body.String())
+	}
+
+	rec = doRequest(handler, http.MethodPost, path+"/close", testToken, "")
+	if rec.Code != http.StatusConflict {
+		t.Errorf("second close = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestOpenAPISpecIsValidJSON(t *testing.T) {
+	var spec map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	if spec["openapi"] == nil || spec["paths"] == nil {
+		t.Error("openapi.json misses openapi or paths")
+	}
+}
+
+func TestAPIHiddenOrders(t *testing.T) {
+	store := &fakeStore{
+		gyroskops: map[int]*database.Gyroskop{
+			1: {ID: 1, ChatID: 100, Name: "Gyros", FoodOptions: []string{"Fleisch", "Vegetarisch"}, IsOpen: true, Hidden: true},
+		},
+		orders: map[int][]database.Order{
+			1: {
+				{GyroskopID: 1, UserID: 5, FirstName: "Ben", Quantities: map[string]int{"Fleisch": 2}},
+				{GyroskopID: 1, UserID: 6, FirstName: "Cem", Quantities: map[string]int{"Fleisch": 1, "Vegetarisch": 1}},
+			},
+		},
+	}
+	handler := New(store, &fakeBackend{store: store}, slog.New(slog.NewTextHandler(io.Discard, nil))).Handler()
+
+	rec := doRequest(handler, http.MethodGet, "/api/v1/gyroskops/1/orders", testToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list orders = %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "Ben") {
+		t.Errorf("list orders of a hidden gyroskop reveals who ordered: %s", rec.Body.String())
+	}
+
+	var got hiddenOrdersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("list orders returned invalid JSON: %v", err)
+	}
+	want := hiddenOrdersResponse{Participants: 2, Totals: map[string]int{"Fleisch": 3, "Vegetarisch": 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("list orders = %+v, want %+v", got, want)
+	}
+}
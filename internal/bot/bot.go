@@ -1,26 +1,60 @@
 package bot
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/logging"
+	"github.com/tionis/gyroskop/internal/metrics"
+	"github.com/tionis/gyroskop/internal/outbox"
+	"github.com/tionis/gyroskop/internal/render"
 )
 
 type Bot struct {
 	api             *tgbotapi.BotAPI
+	outbox          *outbox.Dispatcher // Rate limited queue for everything sent to Telegram
 	db              *database.DB
+	log             *slog.Logger
 	activeGyroskops map[int64]*database.Gyroskop // Cache für aktive Gyroskops
-	stopChan        chan bool                    // Channel to stop the background goroutine
+	mu              sync.Mutex                   // Protects activeGyroskops
+	admins          adminCache                   // Cached results of getChatMember
+	settings        settingsCache                // Cached per-chat settings
+	private         privateSessions              // Gyroskops users order for in private chats
+	chatTitles      sync.Map                     // Chat ID to group title, shown in private chats
+	queues          updateQueues                 // Updates waiting per chat, chats are handled concurrently
+	opening         chatLocks                    // Held while a gyroskop is opened in a chat
+	wg              sync.WaitGroup               // Background goroutines awaited on shutdown
+	webhook         *webhook                     // Set if updates are received via webhook
+	defaults        Defaults
+}
+
+// Defaults are the defaults for new gyroskops and orders
+type Defaults struct {
+	Duration    time.Duration  // Time until the deadline if none is given
+	Name        string         // Name of a gyroskop if none is given
+	FoodOptions []string       // Options of a gyroskop if none are given
+	MaxQuantity int            // Highest quantity that can be ordered per option
+	Location    *time.Location // Time zone deadlines are entered and shown in
 }
 
 // New erstellt eine neue Bot-Instanz
-func New(token string, db *database.DB) (*Bot, error) {
+func New(token string, db *database.DB, logger *slog.Logger, defaults Defaults) (*Bot, error) {
+	// The Telegram library logs request errors which contain the token
+	if err := tgbotapi.SetLogger(apiLogger{logger}); err != nil {
+		return nil, err
+	}
+
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, err
@@ -28,104 +62,376 @@ func New(token string, db *database.DB) (*Bot, error) {
 
 	return &Bot{
 		api:             api,
+		outbox:          outbox.New(api, outbox.DefaultConfig, logger),
 		db:              db,
+		log:             logger,
+		defaults:        defaults,
 		activeGyroskops: make(map[int64]*database.Gyroskop),
-		stopChan:        make(chan bool),
 	}, nil
 }
 
-// Run starts the bot
-func (b *Bot) Run() {
+// Timeouts of handling updates and of shutting down
+const (
+	handlerTimeout  = 30 * time.Second // Per update, including all database calls
+	shutdownTimeout = 10 * time.Second // For pending updates and closes once ctx is cancelled
+)
+
+// Run receives and handles updates until ctx is cancelled or the webhook
+// listener fails, whose error is returned. It then stops receiving updates,
+// handles the ones already received and waits for pending closes. Work still
+// running after shutdownTimeout is cancelled.
+func (b *Bot) Run(ctx context.Context) error {
+	// Handlers and closes outlive ctx until the shutdown timeout cancels them
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
 	// Load existing open gyroskops on startup
-	b.loadActiveGyroskops()
+	b.loadActiveGyroskops(workCtx)
 
-	// Start background goroutine to check for expired gyroskops
-	go b.backgroundExpiryChecker()
+	var updates tgbotapi.UpdatesChannel
+	var listenerFailed <-chan error // Stays nil when long polling
+	if b.webhook != nil {
+		var err error
+		updates, err = b.startWebhook()
+		if err != nil {
+			return fmt.Errorf("starting webhook: %w", err)
+		}
+		listenerFailed = b.webhook.failed
+	} else {
+		// A previously registered webhook would make getUpdates fail
+		b.deleteWebhook()
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
 
-	updates := b.api.GetUpdatesChan(u)
+		updates = b.api.GetUpdatesChan(u)
+	}
+
+	// Start background goroutine to check for expired gyroskops
+	b.spawn(func() { b.backgroundExpiryChecker(ctx, workCtx) })
 
-	for update := range updates {
+	// Without its listener the bot receives no more updates and shuts down
+	var runErr error
+receive:
+	for {
 		select {
-		case <-b.stopChan:
-			log.Println("Bot stopping...")
-			return
-		default:
-			if update.Message != nil {
-				b.handleMessage(update.Message)
+		case update := <-updates:
+			b.dispatch(workCtx, update)
+		case runErr = <-listenerFailed:
+			b.log.Error("Error running webhook listener", "error", runErr)
+			break receive
+		case <-ctx.Done():
+			break receive
+		}
+	}
+
+	b.log.Info("Bot stopping...")
+	timer := time.AfterFunc(shutdownTimeout, cancelWork)
+	defer timer.Stop()
+
+	if b.webhook != nil {
+		// Requests in flight are still passed on until the listener is shut down
+		go b.stopWebhook()
+		for update := range updates {
+			b.dispatch(workCtx, update)
+		}
+	} else {
+		// The channel is only closed once the current long poll returns; handle
+		// the updates already buffered, Telegram considers them delivered
+		b.api.StopReceivingUpdates()
+	drain:
+		for {
+			select {
+			case update := <-updates:
+				b.dispatch(workCtx, update)
+			default:
+				break drain
 			}
-			if update.CallbackQuery != nil {
-				b.handleCallbackQuery(update.CallbackQuery)
+		}
+	}
+
+	// Wait for pending updates and closes, then send what is still queued
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		if err := b.outbox.Close(workCtx); err != nil {
+			b.log.Warn("Error flushing outgoing messages", "error", err)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		b.log.Info("Bot stopped")
+		return runErr
+	case <-workCtx.Done():
+		return errors.Join(runErr, fmt.Errorf("shutdown timed out after %s, pending work was cancelled", shutdownTimeout))
+	}
+}
+
+// spawn runs f in a goroutine that Run waits for on shutdown
+func (b *Bot) spawn(f func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		f()
+	}()
+}
+
+// dispatch queues an update for its chat. The updates of a chat are handled
+// one after another in the order received, while a chat waiting for its
+// rate limit does not hold up the others.
+func (b *Bot) dispatch(ctx context.Context, update tgbotapi.Update) {
+	var chatID int64
+	if chat := update.FromChat(); chat != nil {
+		chatID = chat.ID
+	}
+	if !b.queues.push(chatID, update) {
+		return // The worker of the chat picks it up
+	}
+
+	b.spawn(func() {
+		for {
+			update, ok := b.queues.pop(chatID)
+			if !ok {
+				return
 			}
+			b.handleUpdate(ctx, update)
+		}
+	})
+}
+
+// chatLocks are mutexes per chat for work that must not run concurrently in a
+// chat but is started from outside its worker, e.g. by the HTTP API or timers.
+type chatLocks struct {
+	mu    sync.Mutex
+	chats map[int64]*sync.Mutex
+}
+
+// lock locks the mutex of a chat and returns the function unlocking it
+func (l *chatLocks) lock(chatID int64) func() {
+	l.mu.Lock()
+	if l.chats == nil {
+		l.chats = make(map[int64]*sync.Mutex)
+	}
+	chat, ok := l.chats[chatID]
+	if !ok {
+		chat = &sync.Mutex{}
+		l.chats[chatID] = chat
+	}
+	l.mu.Unlock()
+
+	chat.Lock()
+	return chat.Unlock
+}
+
+// updateQueues holds the updates waiting per chat. A chat has a queue while
+// its worker is running.
+type updateQueues struct {
+	mu    sync.Mutex
+	chats map[int64][]tgbotapi.Update
+}
+
+// push queues an update and reports whether the chat needs a new worker
+func (q *updateQueues) push(chatID int64, update tgbotapi.Update) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.chats == nil {
+		q.chats = make(map[int64][]tgbotapi.Update)
+	}
+	pending, running := q.chats[chatID]
+	q.chats[chatID] = append(pending, update)
+	return !running
+}
+
+// pop returns the next update of a chat. If there is none, the queue is
+// removed and the worker has to stop.
+func (q *updateQueues) pop(chatID int64) (tgbotapi.Update, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := q.chats[chatID]
+	if len(pending) == 0 {
+		delete(q.chats, chatID)
+		return tgbotapi.Update{}, false
+	}
+	q.chats[chatID] = pending[1:]
+	return pending[0], true
+}
+
+// handleUpdate handles an update received via long polling or webhook.
+// Everything logged while handling it is tagged with the update, chat and user ID.
+func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	ctx, cancel := context.WithTimeout(ctx, handlerTimeout)
+	defer cancel()
+
+	ctx = logging.With(ctx, "update_id", update.UpdateID)
+	if chat := update.FromChat(); chat != nil {
+		ctx = logging.With(ctx, "chat_id", chat.ID)
+	}
+	if user := update.SentFrom(); user != nil {
+		ctx = logging.With(ctx, "user_id", user.ID)
+	}
+
+	if update.Message != nil {
+		start := time.Now()
+		handler := "message"
+		if update.Message.IsCommand() {
+			handler = "command"
+			ctx = logging.With(ctx, "command", update.Message.Command())
 		}
+		b.log.DebugContext(ctx, "Handling message")
+		b.handleMessage(ctx, update.Message)
+		metrics.HandlerDuration.ObserveDuration(start, handler)
+	}
+	if update.CallbackQuery != nil {
+		start := time.Now()
+		b.log.DebugContext(ctx, "Handling callback query", "data", update.CallbackQuery.Data)
+		b.handleCallbackQuery(ctx, update.CallbackQuery)
+		metrics.HandlerDuration.ObserveDuration(start, "callback_query")
+	}
+	if update.EditedMessage != nil {
+		start := time.Now()
+		b.log.DebugContext(ctx, "Handling edited message")
+		b.handleEditedMessage(ctx, update.EditedMessage)
+		metrics.HandlerDuration.ObserveDuration(start, "edited_message")
 	}
 }
 
+// withGyroskop tags everything logged with the returned context with the gyroskop ID
+func withGyroskop(ctx context.Context, gyroskop *database.Gyroskop) context.Context {
+	return logging.With(ctx, "gyroskop_id", gyroskop.ID)
+}
+
 // handleMessage verarbeitet eingehende Nachrichten
-func (b *Bot) handleMessage(message *tgbotapi.Message) {
-	// Nur Gruppennachrichten verarbeiten
+func (b *Bot) handleMessage(ctx context.Context, message *tgbotapi.Message) {
+	if message.Chat.IsPrivate() {
+		b.handlePrivateMessage(ctx, message)
+		return
+	}
+
+	// Sonst nur Gruppennachrichten verarbeiten
 	if !message.Chat.IsGroup() && !message.Chat.IsSuperGroup() {
-		b.sendMessage(message.Chat.ID, "🥙 Gyroskop funktioniert nur in Gruppen!")
+		b.sendMessage(ctx, message.Chat.ID, "🥙 Gyroskop funktioniert nur in Gruppen!")
 		return
 	}
+	b.chatTitles.Store(message.Chat.ID, message.Chat.Title)
 
 	if message.IsCommand() {
-		b.handleCommand(message)
+		b.handleCommand(ctx, message)
 	} else {
-		b.handleTextMessage(message)
+		b.handleTextMessage(ctx, message)
 	}
 }
 
 // handleCommand verarbeitet Bot-Befehle
-func (b *Bot) handleCommand(message *tgbotapi.Message) {
+func (b *Bot) handleCommand(ctx context.Context, message *tgbotapi.Message) {
 	command := message.Command()
 	args := message.CommandArguments()
 
 	switch command {
 	case "start", "help":
-		b.handleHelp(message)
+		b.handleHelp(ctx, message)
 	case "gyroskop":
-		b.handleNewGyroskop(message, args)
+		b.handleNewGyroskop(ctx, message, args)
 	case "status":
-		b.handleStatus(message)
+		b.handleStatus(ctx, message)
 	case "ende":
-		b.handleEndGyroskop(message)
+		b.handleEndGyroskop(ctx, message)
 	case "stornieren", "cancel":
-		b.handleCancelOrder(message)
+		b.handleCancelOrder(ctx, message)
+	case "statistik", "stats":
+		b.handleStatistics(ctx, message, args)
+	case "export":
+		b.handleExport(ctx, message, args)
+	case "apitoken":
+		b.handleAPIToken(ctx, message, args)
+	case "mitorganisator":
+		b.handleOrganizers(ctx, message, args)
+	case "uebergeben":
+		b.handleTransfer(ctx, message, args)
+	case "einstellungen", "settings":
+		b.handleSettings(ctx, message, args)
+	case "vorlage", "vorlagen":
+		b.handleTemplate(ctx, message, args)
+	case "bestellt":
+		b.handleOrdered(ctx, message, args)
+	case "angekommen":
+		b.handleDelivered(ctx, message)
+	case "hochholen":
+		b.handleBringUp(ctx, message)
+	case "kosten":
+		b.handleCosts(ctx, message, args)
+	case "einsammeln":
+		b.handleCollect(ctx, message)
+	case "zahlung":
+		b.sendMessage(ctx, message.Chat.ID, "🔒 Deine Zahlungsdaten trägst du in einem privaten Chat mit mir ein: /zahlung")
+	case "bestellzettel", "zettel":
+		b.handleOrderSheet(ctx, message, args)
+	case "restaurant", "restaurants":
+		b.handleRestaurant(ctx, message, args)
+	case "abstimmung", "vote":
+		b.handleVote(ctx, message, args)
 	}
 }
 
 // handleHelp sends the help message
-func (b *Bot) handleHelp(message *tgbotapi.Message) {
-	helpText := `🥙 *Gyroskop Bot - Essensbestellungen koordinieren*
+func (b *Bot) handleHelp(ctx context.Context, message *tgbotapi.Message) {
+	helpText := `🥙 <b>Gyroskop Bot - Essensbestellungen koordinieren</b>
 
-*Befehle:*
-/gyroskop - Neues Gyroskop für 15 Minuten öffnen (Standard: Gyros mit Fleisch und Vegetarisch)
+<b>Befehle:</b>
+/gyroskop - Neues Gyroskop für %[1]d Minuten öffnen (Standard: %[2]s mit %[3]s)
 /gyroskop HH:MM - Neues Gyroskop bis zur angegebenen Uhrzeit öffnen
 /gyroskop 30min - Neues Gyroskop für 30 Minuten öffnen
 /gyroskop Pizza, Margherita, Salami, Hawaii - Pizza-Gyroskop mit eigenen Optionen
 /gyroskop 17:00, Burger, Beef, Chicken, Veggie - Burger-Gyroskop bis 17:00 Uhr
 /gyroskop 10min, Döner, Fleisch, Vegetarisch, Dürüm - Döner-Gyroskop für 10min mit 3 Optionen
-/gyroskop (als Antwort) - Gyroskop wiedereröffnen oder Optionen ändern
+/gyroskop 30min, @Luigi - Gyroskop mit der Karte eines Restaurants der Gruppe
+/gyroskop geheim, Pizza, Margherita - Verdecktes Gyroskop: nur der Ersteller sieht, wer was bestellt
+/gyroskop (als Antwort) - Gyroskop wiedereröffnen oder Optionen ändern ("geheim" oder "sichtbar" ändert die Sichtbarkeit)
+/abstimmung [Dauer], Pizza, Döner, @Luigi - Abstimmen, wo bestellt wird; der Gewinner öffnet ein Gyroskop
+/abstimmung - Abstimmung zwischen den letzten Gyroskops der Gruppe, /abstimmung ende beendet sie
 /status - Aktuellen Status anzeigen
-/ende - Gyroskop beenden (nur Ersteller)
+/hochholen - Gyroskop-Nachricht unten neu senden (angepinnt, wenn in den Einstellungen aktiviert)
+/ende - Gyroskop beenden (Ersteller, Mitorganisatoren und Admins)
+/mitorganisator @nutzer - Mitorganisator hinzufügen, der beenden und bearbeiten darf
+/mitorganisator entfernen @nutzer - Mitorganisator entfernen
+/uebergeben @nutzer - Gyroskop an jemand anderen übergeben
+/einstellungen - Einstellungen der Gruppe anzeigen und ändern (nur Admins)
+/vorlage [Name] - Vorlagen der Gyroskop-Nachricht, von /status und der Übersicht anzeigen und ändern (nur Admins)
+/restaurant - Restaurants der Gruppe anzeigen, /restaurant neu Name zum Anlegen
+/restaurant Name telefon|adresse|web|zeiten|karte Wert - Restaurant bearbeiten
+/bestellt [ETA] - Essen als bestellt markieren, z.B. /bestellt 30min (auch per Button unter der Übersicht)
+/angekommen - Essen ist da, alle Besteller werden erwähnt
+/kosten Lieferung 3,50, Trinkgeld 10%%, Rabatt 5 - Zusatzkosten und Rabatte auf alle aufteilen, /kosten zeigt die Beträge pro Person
+/kosten betrag @nutzer 12,50, Name 8 - Beträge pro Person eintragen, /kosten aufteilung gleich|artikel|betrag wählt die Aufteilung
+/einsammeln - Allen ihren Betrag mit QR-Code für die Überweisung und/oder PayPal-Link an dich schicken (IBAN oder PayPal.me vorher privat mit /zahlung hinterlegen)
+/bestellzettel [Restaurant, Telefon] - Bestellzettel mit Summen pro Option und Verteilung (auch per Button unter der Übersicht)
 /stornieren - Eigene Bestellung stornieren
+/statistik [woche|monat|jahr] - Statistik der Gruppe anzeigen
+/statistik ich - Eigene Bestellhistorie anzeigen
+/export [csv|json|md|txt] - Bestellungen als Datei exportieren (als Antwort oder letztes Gyroskop)
+/export csv TT.MM.JJJJ TT.MM.JJJJ - Alle Gyroskops eines Zeitraums exportieren
+/apitoken - API-Token für die REST-API erstellen (nur Admins, kommt privat)
+/apitoken widerrufen - Alle API-Tokens der Gruppe widerrufen
 /help - Diese Hilfe anzeigen
 
-*Format:* /gyroskop [Zeit], [Name], Option1, Option2, ...
+<b>Privat:</b> Über „💬 Privat bestellen“ unter der Gyroskop-Nachricht bestellst du im privaten Chat mit mir. Dort zeigt /meine deine offenen Bestellungen in allen Gruppen, und nach dem Beenden bekommst du die finale Übersicht.
+
+<b>Format:</b> /gyroskop [Zeit], [Name], Option1, Option2, ...
   ⚠️ Wichtig: Komma-getrennt! Zeit und Name müssen durch Komma getrennt sein.
 
-*Bestellen:*
-📱 *Buttons:* Nutze die Buttons 1️⃣-5️⃣ unter jeder Option
-💬 *Text:* Schreibe einfach die Anzahl und Option (z.B. "2 fleisch" oder "3 veggie")
+<b>Bestellen:</b>
+📱 <b>Buttons:</b> Nutze die Buttons 1️⃣-5️⃣ unter jeder Option
+💬 <b>Text:</b> Schreibe einfach die Anzahl und Option (z.B. "2 fleisch" oder "3 veggie")
    - Eine Zeile pro Option, oder alles in einer Zeile
    - Fuzzy Matching: "fleisch", "meat", "fl" funktionieren alle
-❌ *Stornieren:* Schreibe "0" oder nutze den ❌ Stornieren Button
+🔁 <b>Wie immer:</b> Schreibe "wie immer" oder nutze den 🔁 Button, um deine letzte Bestellung bei einem gleichnamigen Gyroskop zu wiederholen
+❌ <b>Stornieren:</b> Schreibe "0" oder nutze den ❌ Stornieren Button
 
-*Beispiele:*
-/gyroskop - Standard Gyros für 15min
+<b>Beispiele:</b>
+/gyroskop - Standard %[2]s für %[1]dmin
 /gyroskop 30min - Gyros für 30min
 /gyroskop Pizza, Margherita, Salami - Pizza-Gyroskop mit 3 Optionen
 /gyroskop 10min, Döner, Fleisch, Vegetarisch - Döner für 10min mit 2 Optionen
@@ -134,84 +440,156 @@ func (b *Bot) handleHelp(message *tgbotapi.Message) {
 2 meat, 3 veggie - Bestellt 2x Fleisch und 3x Vegetarisch (mehrere in einer Zeile)
 0 - Storniert die komplette Bestellung`
 
-	b.sendMessage(message.Chat.ID, helpText)
+	settings := b.chatSettings(ctx, message.Chat.ID)
+	helpText = fmt.Sprintf(helpText,
+		int(settings.Duration.Minutes()),
+		messageFormat.Escape(settings.Name),
+		messageFormat.Escape(strings.Join(settings.FoodOptions, " und ")),
+	)
+
+	b.sendFormatted(ctx, message.Chat.ID, helpText)
 }
 
 // handleNewGyroskop creates a new gyroskop or reopens an existing one
-func (b *Bot) handleNewGyroskop(message *tgbotapi.Message, args string) {
+func (b *Bot) handleNewGyroskop(ctx context.Context, message *tgbotapi.Message, args string) {
 	// Check if this is a reply to a gyroskop message (reopen functionality)
 	if message.ReplyToMessage != nil {
-		b.handleReopenGyroskop(message, args)
+		b.handleReopenGyroskop(ctx, message, args)
 		return
 	}
 
 	// Check if there's already an active gyroskop
-	if existingGyroskop, exists := b.activeGyroskops[message.Chat.ID]; exists {
-		berlin, _ := time.LoadLocation("Europe/Berlin")
-		deadlineInBerlin := existingGyroskop.Deadline.In(berlin)
-		b.sendMessage(message.Chat.ID, fmt.Sprintf("⚠️ Es gibt bereits ein aktives Gyroskop bis %s. Nutze /ende als Antwort auf die Gyroskop-Nachricht um es zu beenden.", deadlineInBerlin.Format("15:04")))
+	if existingGyroskop, exists := b.getActiveGyroskop(message.Chat.ID); exists {
+		deadlineLocal := existingGyroskop.Deadline.In(b.defaults.Location)
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ Es gibt bereits ein aktives Gyroskop bis %s. Nutze /ende als Antwort auf die Gyroskop-Nachricht um es zu beenden.", deadlineLocal.Format("15:04")))
 		return
 	}
 
-	// Parse deadline and food options from args
-	deadline, name, foodOptions, err := b.parseGyroskopArgs(args)
-	if err != nil {
-		b.sendMessage(message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /gyroskop [Zeit], Name, Option1, Option2, ...")
+	settings := b.chatSettings(ctx, message.Chat.ID)
+	if settings.OpenPermission == openByAdmins && !b.isChatAdmin(ctx, message.Chat.ID, message.From.ID) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ In dieser Gruppe können nur Admins ein Gyroskop öffnen!")
 		return
 	}
 
-	// Create new gyroskop
-	gyroskop, err := b.db.CreateGyroskop(message.Chat.ID, int64(message.From.ID), name, foodOptions, deadline)
+	// Parse deadline and food options from args, a restaurant provides the defaults
+	args, hidden := parseGyroskopFlags(args)
+	args, restaurantName := parseRestaurantRef(args)
+	var restaurant *database.Restaurant
+	if restaurantName != "" {
+		var ok bool
+		if restaurant, ok = b.lookupRestaurant(ctx, message.Chat.ID, restaurantName); !ok {
+			return
+		}
+		settings = settings.withRestaurant(restaurant)
+	}
+	deadline, name, foodOptions, err := b.parseGyroskopArgs(args, settings)
 	if err != nil {
-		log.Printf("Fehler beim Erstellen des Gyroskops: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Fehler beim Erstellen des Gyroskops")
+		metrics.ParseFailures.Inc("gyroskop_args")
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /gyroskop [Zeit], Name, Option1, Option2, ...")
 		return
 	}
 
-	b.sendGyroskopMessage(message.Chat.ID, gyroskop, "🥙 *Gyroskop geöffnet!*", message.From)
+	_, err = b.openGyroskop(ctx, message.Chat.ID, message.From, name, foodOptions, deadline, hidden != nil && *hidden, restaurant, "command")
+	if errors.Is(err, ErrGyroskopActive) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Es gibt bereits ein aktives Gyroskop. Nutze /ende als Antwort auf die Gyroskop-Nachricht um es zu beenden.")
+		return
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Erstellen des Gyroskops", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Erstellen des Gyroskops")
+	}
+}
+
+// openGyroskop creates a gyroskop, optionally hidden and ordering from a
+// restaurant, and posts its message. The source (command, vote, ...) is only
+// used for metrics. ErrGyroskopActive is returned if the chat already has one.
+func (b *Bot) openGyroskop(ctx context.Context, chatID int64, user *tgbotapi.User, name string, foodOptions []string, deadline time.Time, hidden bool, restaurant *database.Restaurant, source string) (*database.Gyroskop, error) {
+	unlock := b.opening.lock(chatID)
+	defer unlock()
+
+	if _, exists := b.getActiveGyroskop(chatID); exists {
+		return nil, ErrGyroskopActive
+	}
+	gyroskop, err := b.db.CreateGyroskop(ctx, chatID, user.ID, name, foodOptions, deadline)
+	if errors.Is(err, database.ErrGyroskopOpen) {
+		return nil, ErrGyroskopActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	metrics.GyroskopsOpened.Inc(source)
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if hidden {
+		if err := b.setGyroskopHidden(ctx, gyroskop, true); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Verdecken der Bestellungen", "error", err)
+			b.sendMessage(ctx, chatID, "❌ Fehler beim Verdecken der Bestellungen")
+		}
+	}
+	if restaurant != nil {
+		if err := b.setGyroskopRestaurant(ctx, gyroskop, restaurant); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Speichern des Restaurants", "error", err)
+		}
+	}
+	b.log.InfoContext(ctx, "Gyroskop opened", "name", name, "deadline", deadline, "source", source)
+
+	b.sendGyroskopMessage(ctx, chatID, gyroskop, user, false)
+	b.warnIfClosed(ctx, chatID, restaurant, deadline)
+	return gyroskop, nil
 }
 
 // handleReopenGyroskop reopens a closed gyroskop or updates deadline/options of an active one
-func (b *Bot) handleReopenGyroskop(message *tgbotapi.Message, args string) {
+func (b *Bot) handleReopenGyroskop(ctx context.Context, message *tgbotapi.Message, args string) {
 	// Check if user is the creator by checking the replied message
 	replyMessage := message.ReplyToMessage
 
 	// Try to find the gyroskop by message ID
-	gyroskop, err := b.db.GetGyroskopByMessageID(message.Chat.ID, replyMessage.MessageID)
+	gyroskop, err := b.db.GetGyroskopByMessageID(ctx, message.Chat.ID, replyMessage.MessageID)
 	if err != nil {
-		b.sendMessage(message.Chat.ID, "❌ Das ist keine gültige Gyroskop-Nachricht")
+		b.sendMessage(ctx, message.Chat.ID, "❌ Das ist keine gültige Gyroskop-Nachricht")
 		return
 	}
+	ctx = withGyroskop(ctx, gyroskop)
 
-	// Check if user is the creator
-	if gyroskop.CreatedBy != int64(message.From.ID) {
-		b.sendMessage(message.Chat.ID, "⚠️ Nur der Ersteller kann das Gyroskop bearbeiten!")
+	if !b.authorize(ctx, message, gyroskop, actionEdit, "das Gyroskop bearbeiten") {
 		return
 	}
 
 	// Parse new deadline and options
-	deadline, name, foodOptions, err := b.parseGyroskopArgs(args)
+	args, hidden := parseGyroskopFlags(args)
+	args, restaurantName := parseRestaurantRef(args)
+	settings := b.chatSettings(ctx, message.Chat.ID)
+	var restaurant *database.Restaurant
+	if restaurantName != "" {
+		var ok bool
+		if restaurant, ok = b.lookupRestaurant(ctx, message.Chat.ID, restaurantName); !ok {
+			return
+		}
+		settings = settings.withRestaurant(restaurant)
+	}
+	deadline, name, foodOptions, err := b.parseGyroskopArgs(args, settings)
 	if err != nil {
-		b.sendMessage(message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /gyroskop [Zeit], Name, Option1, Option2, ...")
+		metrics.ParseFailures.Inc("gyroskop_args")
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /gyroskop [Zeit], Name, Option1, Option2, ...")
 		return
 	}
 
 	// Check if this is the currently active gyroskop
-	if existingGyroskop, exists := b.activeGyroskops[message.Chat.ID]; exists && existingGyroskop.ID == gyroskop.ID {
+	if existingGyroskop, exists := b.getActiveGyroskop(message.Chat.ID); exists && existingGyroskop.ID == gyroskop.ID {
 		// Update deadline of active gyroskop
-		err = b.db.UpdateGyroskopDeadline(gyroskop.ID, deadline)
+		err = b.db.UpdateGyroskopDeadline(ctx, gyroskop.ID, deadline)
 		if err != nil {
-			log.Printf("Fehler beim Aktualisieren der Deadline: %v", err)
-			b.sendMessage(message.Chat.ID, "❌ Fehler beim Aktualisieren der Deadline")
+			b.log.ErrorContext(ctx, "Fehler beim Aktualisieren der Deadline", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Aktualisieren der Deadline")
 			return
 		}
 
 		// Update name and options if provided
-		if args != "" {
-			err = b.db.UpdateGyroskopOptions(gyroskop.ID, name, foodOptions)
+		if args != "" || restaurant != nil {
+			err = b.db.UpdateGyroskopOptions(ctx, gyroskop.ID, name, foodOptions)
 			if err != nil {
-				log.Printf("Fehler beim Aktualisieren der Optionen: %v", err)
-				b.sendMessage(message.Chat.ID, "❌ Fehler beim Aktualisieren der Optionen")
+				b.log.ErrorContext(ctx, "Fehler beim Aktualisieren der Optionen", "error", err)
+				b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Aktualisieren der Optionen")
 				return
 			}
 		}
@@ -221,185 +599,205 @@ func (b *Bot) handleReopenGyroskop(message *tgbotapi.Message, args string) {
 		existingGyroskop.Name = name
 		existingGyroskop.FoodOptions = foodOptions
 
-		berlin, _ := time.LoadLocation("Europe/Berlin")
-		deadlineInBerlin := deadline.In(berlin)
+		if hidden != nil {
+			if err := b.setGyroskopHidden(ctx, existingGyroskop, *hidden); err != nil {
+				b.log.ErrorContext(ctx, "Fehler beim Verdecken der Bestellungen", "error", err)
+				b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Verdecken der Bestellungen")
+				return
+			}
+		}
+		if restaurant != nil {
+			if err := b.setGyroskopRestaurant(ctx, existingGyroskop, restaurant); err != nil {
+				b.log.ErrorContext(ctx, "Fehler beim Speichern des Restaurants", "error", err)
+			}
+		}
+
+		deadlineLocal := deadline.In(b.defaults.Location)
 
-		b.sendMessage(message.Chat.ID, fmt.Sprintf("⏰ *Aktualisiert!*\n\nName: %s\nDeadline: %s Uhr\nOptionen: %s", name, deadlineInBerlin.Format("15:04"), strings.Join(foodOptions, ", ")))
+		visibility := "sichtbar"
+		if existingGyroskop.Hidden {
+			visibility = "verdeckt"
+		}
+		b.sendFormatted(ctx, message.Chat.ID, messageFormat.Lines(
+			render.Line{render.Plain("⏰ "), render.Bold("Aktualisiert!")},
+			nil,
+			render.Line{render.Plain("Name: " + name)},
+			render.Line{render.Plain(fmt.Sprintf("Deadline: %s Uhr", deadlineLocal.Format("15:04")))},
+			render.Line{render.Plain("Optionen: " + strings.Join(foodOptions, ", "))},
+			render.Line{render.Plain("Bestellungen: " + visibility)},
+		))
 
 		// Update the gyroskop message with new deadline
-		b.updateGyroskopMessage(existingGyroskop, replyMessage)
+		b.updateGyroskopMessage(ctx, existingGyroskop, replyMessage)
+		b.warnIfClosed(ctx, message.Chat.ID, b.gyroskopRestaurant(ctx, existingGyroskop), deadline)
 		return
 	}
 
+	unlock := b.opening.lock(message.Chat.ID)
+	defer unlock()
+
 	// Check if there's a different active gyroskop
-	if existingGyroskop, exists := b.activeGyroskops[message.Chat.ID]; exists && existingGyroskop.ID != gyroskop.ID {
-		berlin, _ := time.LoadLocation("Europe/Berlin")
-		deadlineInBerlin := existingGyroskop.Deadline.In(berlin)
-		b.sendMessage(message.Chat.ID, fmt.Sprintf("⚠️ Es gibt bereits ein anderes aktives Gyroskop bis %s. Beende es zuerst.", deadlineInBerlin.Format("15:04")))
+	if existingGyroskop, exists := b.getActiveGyroskop(message.Chat.ID); exists && existingGyroskop.ID != gyroskop.ID {
+		deadlineLocal := existingGyroskop.Deadline.In(b.defaults.Location)
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ Es gibt bereits ein anderes aktives Gyroskop bis %s. Beende es zuerst.", deadlineLocal.Format("15:04")))
 		return
 	}
 
 	// This is a closed gyroskop, reopen it
-	err = b.db.ReopenGyroskop(gyroskop.ID, deadline)
+	err = b.db.ReopenGyroskop(ctx, gyroskop.ID, deadline)
+	if errors.Is(err, database.ErrGyroskopOpen) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Es gibt bereits ein anderes aktives Gyroskop. Beende es zuerst.")
+		return
+	}
+	if errors.Is(err, database.ErrInvalidTransition) {
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ Das Gyroskop ist schon %s und kann nicht wiedereröffnet werden", stateLabels[gyroskop.State]))
+		return
+	}
 	if err != nil {
-		log.Printf("Fehler beim Wiedereröffnen des Gyroskops: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Fehler beim Wiedereröffnen des Gyroskops")
+		b.log.ErrorContext(ctx, "Fehler beim Wiedereröffnen des Gyroskops", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Wiedereröffnen des Gyroskops")
 		return
 	}
+	metrics.GyroskopsOpened.Inc("reopen")
+	b.log.InfoContext(ctx, "Gyroskop reopened", "deadline", deadline)
 
 	// Update gyroskop data
 	gyroskop.Deadline = deadline
 	gyroskop.Name = name
 	gyroskop.FoodOptions = foodOptions
 	gyroskop.IsOpen = true
+	gyroskop.State = database.StateOpen
+
+	// The summary of the previous close and its buttons are outdated now
+	b.updateSummaryMessage(ctx, gyroskop, false)
 
-	b.sendGyroskopMessage(message.Chat.ID, gyroskop, "🔄 *Gyroskop wiedereröffnet!*", message.From)
+	if hidden != nil {
+		if err := b.setGyroskopHidden(ctx, gyroskop, *hidden); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Verdecken der Bestellungen", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Verdecken der Bestellungen")
+		}
+	}
+	if restaurant != nil {
+		if err := b.setGyroskopRestaurant(ctx, gyroskop, restaurant); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Speichern des Restaurants", "error", err)
+		}
+	}
+
+	b.sendGyroskopMessage(ctx, message.Chat.ID, gyroskop, message.From, true)
+	b.warnIfClosed(ctx, message.Chat.ID, b.gyroskopRestaurant(ctx, gyroskop), deadline)
 }
 
 // sendGyroskopMessage sends the gyroskop message with proper formatting
-func (b *Bot) sendGyroskopMessage(chatID int64, gyroskop *database.Gyroskop, title string, user *tgbotapi.User) {
-	userName := b.getUserName(user)
-
-	// Convert deadline to Berlin timezone for display
-	berlin, _ := time.LoadLocation("Europe/Berlin")
-	deadlineInBerlin := gyroskop.Deadline.In(berlin)
-
-	// Generate example orders
-	var examples []string
-	for _, option := range gyroskop.FoodOptions {
-		examples = append(examples, fmt.Sprintf("'2 %s'", strings.ToLower(option)))
-	}
-
-	text := fmt.Sprintf("%s\n\n"+
-		"👤 Erstellt von: %s\n"+
-		"⏰ Deadline: %s Uhr\n\n"+
-		"Zum Bestellen schreibt %s oder nutzt die Buttons unten.\n\n"+
-		"Zum Beenden: /ende",
-		title,
-		userName,
-		deadlineInBerlin.Format("15:04"),
-		strings.Join(examples, ", "),
-	)
+func (b *Bot) sendGyroskopMessage(ctx context.Context, chatID int64, gyroskop *database.Gyroskop, user *tgbotapi.User, reopened bool) {
+	// A reopened gyroskop already has orders
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+	}
+	text := b.liveMessage(ctx, gyroskop, orders, b.getUserName(user), reopened)
 
 	// Add gyroskop to cache
-	b.activeGyroskops[chatID] = gyroskop
+	b.setActiveGyroskop(gyroskop)
 
 	// Send message with reaction buttons and save message ID
-	sentMessage := b.sendMessageWithReactions(chatID, text, gyroskop.FoodOptions)
+	sentMessage := b.sendMessageWithReactions(ctx, chatID, text, gyroskop)
 	if sentMessage != nil {
 		// Update message ID in database
-		err := b.db.UpdateGyroskopMessageID(gyroskop.ID, sentMessage.MessageID)
+		err := b.db.UpdateGyroskopMessageID(ctx, gyroskop.ID, sentMessage.MessageID)
 		if err != nil {
-			log.Printf("Error saving message ID: %v", err)
+			b.log.ErrorContext(ctx, "Error saving message ID", "message_id", sentMessage.MessageID, "error", err)
 		}
 		gyroskop.MessageID = sentMessage.MessageID
+		b.pinGyroskopMessage(ctx, gyroskop)
 	}
 }
 
 // handleStatus shows the current status
-func (b *Bot) handleStatus(message *tgbotapi.Message) {
-	gyroskop, exists := b.activeGyroskops[message.Chat.ID]
+func (b *Bot) handleStatus(ctx context.Context, message *tgbotapi.Message) {
+	gyroskop, exists := b.getActiveGyroskop(message.Chat.ID)
 	if !exists {
-		b.sendMessage(message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
-		return
-	}
-
-	orders, err := b.db.GetOrdersByGyroskop(gyroskop.ID)
-	if err != nil {
-		log.Printf("Fehler beim Laden der Bestellungen: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Fehler beim Laden der Bestellungen")
-		return
-	}
-
-	text := b.formatCurrentStatus(gyroskop, orders)
-	b.sendMessage(message.Chat.ID, text)
-}
-
-// handleCloseGyroskop schließt das aktive Gyroskop
-func (b *Bot) handleCloseGyroskop(message *tgbotapi.Message) {
-	gyroskop, err := b.db.GetActiveGyroskop(message.Chat.ID)
-	if err != nil {
-		b.sendMessage(message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
-		return
-	}
-
-	// Check if the user is the creator
-	if gyroskop.CreatedBy != int64(message.From.ID) {
-		b.sendMessage(message.Chat.ID, "⚠️ Nur der Ersteller kann das Gyroskop schließen!")
-		return
-	}
-
-	orders, err := b.db.GetOrdersByGyroskop(gyroskop.ID)
-	if err != nil {
-		log.Printf("Fehler beim Laden der Bestellungen: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Fehler beim Laden der Bestellungen")
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
 		return
 	}
+	ctx = withGyroskop(ctx, gyroskop)
 
-	err = b.db.CloseGyroskop(gyroskop.ID)
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
 	if err != nil {
-		log.Printf("Fehler beim Schließen des Gyroskops: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Fehler beim Schließen des Gyroskops")
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Bestellungen")
 		return
 	}
 
-	text := "🔒 *Gyroskop geschlossen!*\n\n" + b.formatOrderSummary(gyroskop, orders)
-	b.sendMessage(message.Chat.ID, text)
+	b.sendFormatted(ctx, message.Chat.ID, b.statusMessage(ctx, gyroskop, orders))
 }
 
 // handleCancelOrder storniert eine Bestellung
-func (b *Bot) handleCancelOrder(message *tgbotapi.Message) {
-	gyroskop, exists := b.activeGyroskops[message.Chat.ID]
+func (b *Bot) handleCancelOrder(ctx context.Context, message *tgbotapi.Message) {
+	gyroskop, exists := b.getActiveGyroskop(message.Chat.ID)
 	if !exists {
-		b.sendMessage(message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
 		return
 	}
+	ctx = withGyroskop(ctx, gyroskop)
 
-	err := b.db.RemoveOrder(gyroskop.ID, int64(message.From.ID))
+	err := b.db.RemoveOrder(ctx, gyroskop.ID, int64(message.From.ID))
 	if err != nil {
-		log.Printf("Error canceling order: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Error canceling order")
+		b.log.ErrorContext(ctx, "Error canceling order", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Error canceling order")
 		return
 	}
 
-	userName := b.getUserName(message.From)
-	b.sendMessage(message.Chat.ID, fmt.Sprintf("✅ Bestellung von %s wurde storniert", userName))
+	if b.chatSettings(ctx, message.Chat.ID).Confirmations && !gyroskop.Hidden {
+		userName := b.getUserName(message.From)
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("✅ Bestellung von %s wurde storniert", userName))
+	}
 }
 
 // handleTextMessage processes text messages (Bestellungen)
-func (b *Bot) handleTextMessage(message *tgbotapi.Message) {
+func (b *Bot) handleTextMessage(ctx context.Context, message *tgbotapi.Message) {
 	text := strings.TrimSpace(strings.ToLower(message.Text))
 
 	// Check if there's an active gyroskop
-	gyroskop, exists := b.activeGyroskops[message.Chat.ID]
+	gyroskop, exists := b.getActiveGyroskop(message.Chat.ID)
 	if !exists {
 		return // Ignore if no active gyroskop
 	}
+	ctx = withGyroskop(ctx, gyroskop)
 
 	// Check if the gyroskop is still open
 	if time.Now().After(gyroskop.Deadline) {
-		b.sendMessage(message.Chat.ID, "⏰ Das Gyroskop ist bereits abgelaufen!")
+		b.sendMessage(ctx, message.Chat.ID, "⏰ Das Gyroskop ist bereits abgelaufen!")
 		return
 	}
 
 	userName := b.getUserName(message.From)
+	settings := b.chatSettings(ctx, message.Chat.ID)
 
 	// Handle cancellation (0)
 	if text == "0" {
-		err := b.db.RemoveOrder(gyroskop.ID, int64(message.From.ID))
+		err := b.db.RemoveOrder(ctx, gyroskop.ID, int64(message.From.ID))
 		if err != nil {
-			log.Printf("Error canceling order: %v", err)
+			b.log.ErrorContext(ctx, "Error canceling order", "error", err)
 			return
 		}
-		b.sendMessage(message.Chat.ID, fmt.Sprintf("❌ %s hat die Bestellung storniert", userName))
+		if settings.Confirmations && !gyroskop.Hidden {
+			b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("❌ %s hat die Bestellung storniert", userName))
+		}
 		// Update the gyroskop message with current orders
-		b.updateGyroskopMessage(gyroskop, message)
+		b.updateGyroskopMessage(ctx, gyroskop, message)
+		return
+	}
+
+	if text == reorderText {
+		b.handleReorderText(ctx, message, gyroskop)
 		return
 	}
 
 	// Parse order syntax using shortcodes generated from food options
-	quantities := b.parseOrderText(text, gyroskop.FoodOptions)
+	quantities := b.parseOrderText(text, gyroskop.FoodOptions, settings.MaxQuantity)
 	if quantities == nil {
+		metrics.ParseFailures.Inc("order")
+		b.log.DebugContext(ctx, "Ignoring message that is not an order")
 		return // Ignore invalid formats
 	}
 
@@ -415,27 +813,32 @@ func (b *Bot) handleTextMessage(message *tgbotapi.Message) {
 		return
 	}
 
-	// Add or update order
-	err := b.db.AddOrUpdateOrder(
+	// Add or update order, remembering the message to handle edits of it
+	err := b.db.AddOrUpdateOrderFromMessage(ctx,
 		gyroskop.ID,
 		int64(message.From.ID),
 		message.From.UserName,
 		message.From.FirstName,
 		message.From.LastName,
 		quantities,
+		database.OrderMessage{ChatID: message.Chat.ID, MessageID: message.MessageID},
 	)
 	if err != nil {
-		log.Printf("Error adding order: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Fehler beim Bestellen")
+		b.log.ErrorContext(ctx, "Error adding order", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Bestellen")
 		return
 	}
+	metrics.OrdersPlaced.Inc("text")
+	b.log.InfoContext(ctx, "Order placed", "source", "text", "quantities", quantities)
 
 	// Format response message
-	orderText := b.formatOrderQuantities(quantities, gyroskop.FoodOptions)
-	b.sendMessage(message.Chat.ID, fmt.Sprintf("✅ %s: %s", userName, orderText))
+	if settings.Confirmations && !gyroskop.Hidden {
+		orderText := b.formatOrderQuantities(quantities, gyroskop.FoodOptions)
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("✅ %s: %s", userName, orderText))
+	}
 
 	// Update the gyroskop message with current orders
-	b.updateGyroskopMessage(gyroskop, message)
+	b.updateGyroskopMessage(ctx, gyroskop, message)
 }
 
 // parseOrderText parses order text using fuzzy matching
@@ -445,8 +848,9 @@ func (b *Bot) handleTextMessage(message *tgbotapi.Message) {
 //	"2 fleisch, 3 veggie" - multiple orders in one line (comma separated)
 //	"2 meat\n3 veggie" - multiple orders on separate lines
 //
+// Quantities above maxQuantity are ignored.
 // Returns map of food option to quantity, or nil if invalid format
-func (b *Bot) parseOrderText(text string, foodOptions []string) map[string]int {
+func (b *Bot) parseOrderText(text string, foodOptions []string, maxQuantity int) map[string]int {
 	quantities := make(map[string]int)
 
 	// Split by newlines and commas to handle both formats
@@ -473,7 +877,7 @@ func (b *Bot) parseOrderText(text string, foodOptions []string) map[string]int {
 		}
 
 		quantity, err := strconv.Atoi(matches[1])
-		if err != nil || quantity < 0 || quantity > 10 {
+		if err != nil || quantity < 0 || quantity > maxQuantity {
 			continue // Invalid quantity, skip it
 		}
 
@@ -499,37 +903,20 @@ func (b *Bot) parseOrderText(text string, foodOptions []string) map[string]int {
 
 // formatOrderQuantities formats quantities map into a readable string
 func (b *Bot) formatOrderQuantities(quantities map[string]int, foodOptions []string) string {
-	var parts []string
-
-	for _, option := range foodOptions {
-		if qty, ok := quantities[option]; ok && qty > 0 {
-			if qty == 1 {
-				parts = append(parts, fmt.Sprintf("1 %s", option))
-			} else {
-				parts = append(parts, fmt.Sprintf("%d %s", qty, option))
-			}
-		}
-	}
-
-	return strings.Join(parts, ", ")
+	return render.Items(orderItems(quantities, foodOptions))
 }
 
-// parseDeadline parses a deadline from various formats or returns default (15 minutes from now)
-func (b *Bot) parseDeadline(input string) (time.Time, error) {
+// parseDeadline parses a deadline from various formats or returns defaultDuration from now
+func (b *Bot) parseDeadline(input string, defaultDuration time.Duration) (time.Time, error) {
 	input = strings.TrimSpace(input)
 
-	// If no input, default to 15 minutes from now
+	// If no input, use the default duration
 	if input == "" {
-		return time.Now().Add(15 * time.Minute), nil
+		return time.Now().Add(defaultDuration), nil
 	}
 
-	// Load Berlin timezone
-	berlin, err := time.LoadLocation("Europe/Berlin")
-	if err != nil {
-		return time.Time{}, err
-	}
-
-	now := time.Now().In(berlin)
+	loc := b.defaults.Location
+	now := time.Now().In(loc)
 
 	// Check for duration format (e.g., "15min", "30min", "1h")
 	durationRegex := regexp.MustCompile(`^(\d+)(min|m|h|hour|hours)$`)
@@ -558,8 +945,8 @@ func (b *Bot) parseDeadline(input string) (time.Time, error) {
 	// Check for time format (HH:MM)
 	timeRegex := regexp.MustCompile(`^\d{1,2}:\d{2}$`)
 	if timeRegex.MatchString(input) {
-		// Parse as time today in Berlin timezone
-		parsedTime, err := time.ParseInLocation("15:04", input, berlin)
+		// Parse as time today in the configured timezone
+		parsedTime, err := time.ParseInLocation("15:04", input, loc)
 		if err != nil {
 			return time.Time{}, err
 		}
@@ -568,7 +955,7 @@ func (b *Bot) parseDeadline(input string) (time.Time, error) {
 		deadline := time.Date(
 			now.Year(), now.Month(), now.Day(),
 			parsedTime.Hour(), parsedTime.Minute(), 0, 0,
-			berlin,
+			loc,
 		)
 
 		// If the time is in the past, use tomorrow
@@ -586,18 +973,18 @@ func (b *Bot) parseDeadline(input string) (time.Time, error) {
 // Format (comma-separated): [time], [name], option1, option2, ...
 // Examples:
 //
-//	/gyroskop -> default (15min, "Gyros", ["Fleisch", "Vegetarisch"])
+//	/gyroskop -> defaults of the chat (15min, "Gyros", ["Fleisch", "Vegetarisch"] unless configured)
 //	/gyroskop 17:00 -> until 17:00, default name and options
 //	/gyroskop Pizza, Margherita, Salami, Hawaii -> default time (15min), Pizza with 3 options
 //	/gyroskop 30min, Burger, Beef, Chicken, Veggie -> 30min, Burger with 3 options
 //	/gyroskop 10min, Döner, Fleisch, Vegetarisch, Dürüm -> 10min, Döner with 3 options
-func (b *Bot) parseGyroskopArgs(args string) (time.Time, string, []string, error) {
+func (b *Bot) parseGyroskopArgs(args string, settings Settings) (time.Time, string, []string, error) {
 	args = strings.TrimSpace(args)
 
 	// Default values
-	name := "Gyros"
-	foodOptions := []string{"Fleisch", "Vegetarisch"}
-	deadline := time.Now().Add(15 * time.Minute)
+	name := settings.Name
+	foodOptions := append([]string(nil), settings.FoodOptions...)
+	deadline := time.Now().Add(settings.Duration)
 
 	// If no args, return defaults
 	if args == "" {
@@ -611,7 +998,7 @@ func (b *Bot) parseGyroskopArgs(args string) (time.Time, string, []string, error
 	}
 
 	// Try to parse first part as deadline
-	firstPartDeadline, err := b.parseDeadline(parts[0])
+	firstPartDeadline, err := b.parseDeadline(parts[0], settings.Duration)
 	startIdx := 0
 	if err == nil {
 		// First part is a deadline
@@ -646,58 +1033,95 @@ func (b *Bot) parseGyroskopArgs(args string) (time.Time, string, []string, error
 	return deadline, name, foodOptions, nil
 }
 
-// handleEndGyroskop ends the gyroskop created by the user
-func (b *Bot) handleEndGyroskop(message *tgbotapi.Message) {
-	gyroskop, exists := b.activeGyroskops[message.Chat.ID]
+// handleEndGyroskop ends the active gyroskop (creator, co-organisers and admins only)
+func (b *Bot) handleEndGyroskop(ctx context.Context, message *tgbotapi.Message) {
+	gyroskop, exists := b.getActiveGyroskop(message.Chat.ID)
 	if !exists {
-		b.sendMessage(message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
 		return
 	}
+	ctx = withGyroskop(ctx, gyroskop)
 
-	// Check if the user is the creator
-	if gyroskop.CreatedBy != int64(message.From.ID) {
-		b.sendMessage(message.Chat.ID, "⚠️ Nur der Ersteller kann das Gyroskop beenden!")
+	if !b.authorize(ctx, message, gyroskop, actionEdit, "das Gyroskop beenden") {
 		return
 	}
 
-	b.closeGyroskop(gyroskop)
+	b.closeGyroskop(ctx, gyroskop, "manual")
 }
 
 // autoCloseGyroskop automatically closes an expired gyroskop
-func (b *Bot) autoCloseGyroskop(gyroskop *database.Gyroskop) {
-	log.Printf("Auto-closing gyroskop %d for chat %d", gyroskop.ID, gyroskop.ChatID)
-	b.closeGyroskop(gyroskop)
+func (b *Bot) autoCloseGyroskop(ctx context.Context, gyroskop *database.Gyroskop) {
+	ctx = withGyroskop(logging.With(ctx, "chat_id", gyroskop.ChatID), gyroskop)
+	b.log.InfoContext(ctx, "Auto-closing gyroskop")
+	b.closeGyroskop(ctx, gyroskop, "expired")
 }
 
-// closeGyroskop schließt ein Gyroskop und sendet Übersicht
-func (b *Bot) closeGyroskop(gyroskop *database.Gyroskop) {
-	orders, err := b.db.GetOrdersByGyroskop(gyroskop.ID)
+// closeGyroskop schließt ein Gyroskop und sendet Übersicht.
+// The reason (manual, expired, api) is only used for metrics. If the gyroskop
+// was already closed, e.g. by the expiry checker, nothing is sent and
+// database.ErrInvalidTransition is returned.
+func (b *Bot) closeGyroskop(ctx context.Context, gyroskop *database.Gyroskop, reason string) error {
+	closedAt := time.Now()
+	err := b.db.CloseGyroskop(ctx, gyroskop.ID)
+	if errors.Is(err, database.ErrInvalidTransition) {
+		b.log.InfoContext(ctx, "Gyroskop already closed", "reason", reason)
+		b.removeActiveGyroskop(gyroskop)
+		return err
+	}
 	if err != nil {
-		log.Printf("Fehler beim Laden der Bestellungen: %v", err)
-		b.sendMessage(gyroskop.ChatID, "❌ Fehler beim Laden der Bestellungen")
-		return
+		b.log.ErrorContext(ctx, "Fehler beim Schließen des Gyroskops", "error", err)
+		b.sendMessage(ctx, gyroskop.ChatID, "❌ Fehler beim Schließen des Gyroskops")
+		return err
 	}
+	metrics.GyroskopsClosed.Inc(reason)
+
+	// Aus Cache entfernen
+	b.removeActiveGyroskop(gyroskop)
+	b.unpinGyroskopMessage(ctx, gyroskop)
 
-	err = b.db.CloseGyroskop(gyroskop.ID)
+	// Loaded after closing, so no order placed in the meantime is missing
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
 	if err != nil {
-		log.Printf("Fehler beim Schließen des Gyroskops: %v", err)
-		b.sendMessage(gyroskop.ChatID, "❌ Fehler beim Schließen des Gyroskops")
-		return
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+		b.sendMessage(ctx, gyroskop.ChatID, "❌ Das Gyroskop wurde beendet, aber die Bestellungen konnten nicht geladen werden")
+		return err
 	}
+	b.log.InfoContext(ctx, "Gyroskop closed", "reason", reason, "orders", len(orders))
 
-	// Aus Cache entfernen
-	delete(b.activeGyroskops, gyroskop.ChatID)
+	gyroskop.IsOpen = false
+	gyroskop.State = database.StateClosed
+	b.sendSummaryMessage(ctx, gyroskop, orders)
+	b.finaliseGyroskopMessage(ctx, gyroskop, orders, closedAt)
 
-	text := "🔒 *Gyroskop beendet!*\n\n" + b.formatOrderSummary(gyroskop, orders)
-	b.sendMessage(gyroskop.ChatID, text)
+	if gyroskop.Hidden {
+		b.sendCreatorSummary(ctx, gyroskop, orders)
+	}
+	b.sendPrivateSummaries(ctx, gyroskop, orders)
+	return nil
 }
 
 // handleCallbackQuery verarbeitet Reactions/Inline-Button Klicks
-func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
+func (b *Bot) handleCallbackQuery(ctx context.Context, query *tgbotapi.CallbackQuery) {
 	// Parse Callback Data
 	data := query.Data
+	if strings.HasPrefix(data, settingsCallbackPrefix) {
+		b.handleSettingsCallback(ctx, query)
+		return
+	}
+	if strings.HasPrefix(data, lifecycleCallbackPrefix) {
+		b.handleLifecycleCallback(ctx, query)
+		return
+	}
+	if strings.HasPrefix(data, voteCallbackPrefix) {
+		b.handleVoteCallback(ctx, query)
+		return
+	}
+	if strings.HasPrefix(data, privateCallbackPrefix) {
+		b.handlePrivateCallback(ctx, query)
+		return
+	}
 	if !strings.HasPrefix(data, "g") {
-		b.answerCallbackQuery(query.ID, "❌ Ungültige Callback-Daten")
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Callback-Daten")
 		return
 	}
 
@@ -707,44 +1131,49 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 
 	// Special case: g0 means cancel
 	if parts == "0" {
-		b.handleCancelOrderCallback(query)
+		b.handleCancelOrderCallback(ctx, query)
+		return
+	}
+	if parts == reorderAction {
+		b.handleReorderCallback(ctx, query)
 		return
 	}
 
 	// Parse format: <index>_<quantity>
 	splitParts := strings.Split(parts, "_")
 	if len(splitParts) != 2 {
-		b.answerCallbackQuery(query.ID, "❌ Ungültiges Format")
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültiges Format")
 		return
 	}
 
 	optionIndex, err := strconv.Atoi(splitParts[0])
 	if err != nil {
-		b.answerCallbackQuery(query.ID, "❌ Ungültiger Index")
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültiger Index")
 		return
 	}
 
 	quantity, err := strconv.Atoi(splitParts[1])
-	if err != nil || quantity < 0 || quantity > 10 {
-		b.answerCallbackQuery(query.ID, "❌ Ungültige Anzahl")
+	if err != nil || quantity < 0 || quantity > b.chatSettings(ctx, query.Message.Chat.ID).MaxQuantity {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Anzahl")
 		return
 	}
 
-	gyroskop, exists := b.activeGyroskops[query.Message.Chat.ID]
+	gyroskop, exists := b.getActiveGyroskop(query.Message.Chat.ID)
 	if !exists {
-		b.answerCallbackQuery(query.ID, "❌ Kein aktives Gyroskop")
+		b.answerCallbackQuery(ctx, query.ID, "❌ Kein aktives Gyroskop")
 		return
 	}
+	ctx = withGyroskop(ctx, gyroskop)
 
 	// Check if the gyroskop is still open
 	if !gyroskop.IsOpen {
-		b.answerCallbackQuery(query.ID, "❌ Gyroskop ist bereits geschlossen")
+		b.answerCallbackQuery(ctx, query.ID, "❌ Gyroskop ist bereits geschlossen")
 		return
 	}
 
 	// Validate option index
 	if optionIndex < 0 || optionIndex >= len(gyroskop.FoodOptions) {
-		b.answerCallbackQuery(query.ID, "❌ Ungültige Option")
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Option")
 		return
 	}
 
@@ -752,7 +1181,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 
 	// Load current order
 	currentQuantities := make(map[string]int)
-	existingOrder, err := b.db.GetOrder(gyroskop.ID, int64(query.From.ID))
+	existingOrder, err := b.db.GetOrder(ctx, gyroskop.ID, int64(query.From.ID))
 	if err == nil {
 		currentQuantities = existingOrder.Quantities
 	}
@@ -761,7 +1190,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 	currentQuantities[selectedOption] = quantity
 
 	// Add or update order
-	err = b.db.AddOrUpdateOrder(
+	err = b.db.AddOrUpdateOrder(ctx,
 		gyroskop.ID,
 		int64(query.From.ID),
 		query.From.UserName,
@@ -770,8 +1199,21 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 		currentQuantities,
 	)
 	if err != nil {
-		log.Printf("Error adding order: %v", err)
-		b.answerCallbackQuery(query.ID, "❌ Fehler beim Bestellen")
+		b.log.ErrorContext(ctx, "Error adding order", "error", err)
+		b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Bestellen")
+		return
+	}
+	metrics.OrdersPlaced.Inc("button")
+	b.log.InfoContext(ctx, "Order placed", "source", "button", "quantities", currentQuantities)
+
+	// Orders of hidden gyroskops are only confirmed to the user
+	if gyroskop.Hidden {
+		orderText := b.formatOrderQuantities(currentQuantities, gyroskop.FoodOptions)
+		if orderText == "" {
+			orderText = "nichts"
+		}
+		b.answerCallbackAlert(ctx, query.ID, fmt.Sprintf("🙈 Deine Bestellung: %s", orderText))
+		b.updateGyroskopMessage(ctx, gyroskop, query.Message)
 		return
 	}
 
@@ -782,126 +1224,39 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 		responseText = fmt.Sprintf("✅ %d %s", quantity, selectedOption)
 	}
 
-	b.answerCallbackQuery(query.ID, responseText)
+	b.answerCallbackQuery(ctx, query.ID, responseText)
 
 	// Nach jeder Änderung die Gyroskop-Nachricht mit aktuellem Status aktualisieren
-	b.updateGyroskopMessage(gyroskop, query.Message)
+	b.updateGyroskopMessage(ctx, gyroskop, query.Message)
 }
 
 // handleCancelOrderCallback behandelt das Stornieren einer Bestellung über Callback
-func (b *Bot) handleCancelOrderCallback(query *tgbotapi.CallbackQuery) {
-	gyroskop, exists := b.activeGyroskops[query.Message.Chat.ID]
+func (b *Bot) handleCancelOrderCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	gyroskop, exists := b.getActiveGyroskop(query.Message.Chat.ID)
 	if !exists {
-		b.answerCallbackQuery(query.ID, "❌ Kein aktives Gyroskop")
+		b.answerCallbackQuery(ctx, query.ID, "❌ Kein aktives Gyroskop")
 		return
 	}
+	ctx = withGyroskop(ctx, gyroskop)
 
 	// Check if the gyroskop is still open
 	if time.Now().After(gyroskop.Deadline) {
-		b.answerCallbackQuery(query.ID, "⏰ Das Gyroskop ist bereits abgelaufen!")
+		b.answerCallbackQuery(ctx, query.ID, "⏰ Das Gyroskop ist bereits abgelaufen!")
 		return
 	}
 
 	// Bestellung stornieren
-	err := b.db.RemoveOrder(gyroskop.ID, int64(query.From.ID))
+	err := b.db.RemoveOrder(ctx, gyroskop.ID, int64(query.From.ID))
 	if err != nil {
-		log.Printf("Error canceling order: %v", err)
-		b.answerCallbackQuery(query.ID, "❌ Fehler beim Stornieren")
+		b.log.ErrorContext(ctx, "Error canceling order", "error", err)
+		b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Stornieren")
 		return
 	}
 
-	b.answerCallbackQuery(query.ID, "❌ Bestellung storniert")
+	b.answerCallbackQuery(ctx, query.ID, "❌ Bestellung storniert")
 
 	// Nach jeder Änderung die Gyroskop-Nachricht mit aktuellem Status aktualisieren
-	b.updateGyroskopMessage(gyroskop, query.Message)
-}
-
-// formatCurrentStatus formatiert den aktuellen Status (während Gyroskop läuft)
-func (b *Bot) formatCurrentStatus(gyroskop *database.Gyroskop, orders []database.Order) string {
-	var text strings.Builder
-
-	// Convert deadline to Berlin timezone for display
-	berlin, _ := time.LoadLocation("Europe/Berlin")
-	deadlineInBerlin := gyroskop.Deadline.In(berlin)
-
-	text.WriteString("📊 *Aktueller Status*\n")
-	text.WriteString(fmt.Sprintf("⏰ Deadline: %s Uhr\n\n", deadlineInBerlin.Format("15:04")))
-
-	if len(orders) == 0 {
-		text.WriteString("Noch keine Bestellungen 😢")
-		return text.String()
-	}
-
-	totals := make(map[string]int)
-
-	for _, order := range orders {
-		name := b.formatUserName(&order)
-		orderText := b.formatOrderQuantities(order.Quantities, gyroskop.FoodOptions)
-
-		text.WriteString(fmt.Sprintf("• %s: %s\n", name, orderText))
-
-		// Add to totals
-		for option, qty := range order.Quantities {
-			totals[option] += qty
-		}
-	}
-
-	// Format totals
-	var totalItems int
-	var totalParts []string
-	for _, option := range gyroskop.FoodOptions {
-		if qty, ok := totals[option]; ok && qty > 0 {
-			totalItems += qty
-			totalParts = append(totalParts, fmt.Sprintf("%d %s", qty, option))
-		}
-	}
-
-	text.WriteString(fmt.Sprintf("\n🥙 *Gesamt: %d* (%s)", totalItems, strings.Join(totalParts, ", ")))
-	return text.String()
-}
-
-// formatOrderSummary formatiert die finale Bestellübersicht
-func (b *Bot) formatOrderSummary(gyroskop *database.Gyroskop, orders []database.Order) string {
-	var text strings.Builder
-
-	// Convert deadline to Berlin timezone for display
-	berlin, _ := time.LoadLocation("Europe/Berlin")
-	deadlineInBerlin := gyroskop.Deadline.In(berlin)
-
-	text.WriteString(fmt.Sprintf("📊 *%s - Finale Bestellübersicht*\n", gyroskop.Name))
-	text.WriteString(fmt.Sprintf("⏰ Deadline war: %s Uhr\n\n", deadlineInBerlin.Format("15:04")))
-
-	if len(orders) == 0 {
-		text.WriteString("Keine Bestellungen eingegangen 😢")
-		return text.String()
-	}
-
-	totals := make(map[string]int)
-
-	for _, order := range orders {
-		name := b.formatUserName(&order)
-		orderText := b.formatOrderQuantities(order.Quantities, gyroskop.FoodOptions)
-
-		text.WriteString(fmt.Sprintf("• %s: %s\n", name, orderText))
-
-		// Add to totals
-		for option, qty := range order.Quantities {
-			totals[option] += qty
-		}
-	}
-
-	// Format totals
-	var totalItems int
-	var totalParts []string
-	for _, option := range gyroskop.FoodOptions {
-		if qty, ok := totals[option]; ok && qty > 0 {
-			totalItems += qty
-			totalParts = append(totalParts, fmt.Sprintf("%d %s", qty, option))
-		}
-	}
-
-	text.WriteString(fmt.Sprintf("\n🥙 *Gesamt: %d* (%s)", totalItems, strings.Join(totalParts, ", ")))
-	return text.String()
+	b.updateGyroskopMessage(ctx, gyroskop, query.Message)
 }
 
 // getUserName holt den Anzeigenamen eines Users
@@ -932,57 +1287,87 @@ func (b *Bot) formatUserName(order *database.Order) string {
 	return fmt.Sprintf("User %d", order.UserID)
 }
 
-// sendMessage sendet eine Nachricht
-func (b *Bot) sendMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = tgbotapi.ModeMarkdown
-	_, err := b.api.Send(msg)
-	if err != nil {
-		log.Printf("Fehler beim Senden der Nachricht: %v", err)
-	}
+// sendMessage sendet eine Nachricht als reinen Text. Formatted messages are
+// sent with sendFormatted.
+func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string) {
+	b.sendFormatted(ctx, chatID, messageFormat.Escape(text))
 }
 
 // createFoodOptionsKeyboard creates an inline keyboard based on food options
-func (b *Bot) createFoodOptionsKeyboard(foodOptions []string) tgbotapi.InlineKeyboardMarkup {
+// with a button per quantity
+func (b *Bot) createFoodOptionsKeyboard(foodOptions []string, quantities []int) tgbotapi.InlineKeyboardMarkup {
+	return b.foodOptionsKeyboard(foodOptions, quantities, "g")
+}
+
+// foodOptionsKeyboard creates the order keyboard with callback data
+// <prefix><index>_<quantity>, <prefix>0 for cancelling and <prefix>r for
+// repeating the previous order
+func (b *Bot) foodOptionsKeyboard(foodOptions []string, quantities []int, prefix string) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	// Create rows for each food option
 	for i, option := range foodOptions {
+		if len(quantities) == 0 {
+			break
+		}
+
 		// Add header row
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(option+":", "noop"),
 		))
 
-		// Add button row for quantities 1-5
-		// Format: g<index>_<quantity>
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("1️⃣", fmt.Sprintf("g%d_1", i)),
-			tgbotapi.NewInlineKeyboardButtonData("2️⃣", fmt.Sprintf("g%d_2", i)),
-			tgbotapi.NewInlineKeyboardButtonData("3️⃣", fmt.Sprintf("g%d_3", i)),
-			tgbotapi.NewInlineKeyboardButtonData("4️⃣", fmt.Sprintf("g%d_4", i)),
-			tgbotapi.NewInlineKeyboardButtonData("5️⃣", fmt.Sprintf("g%d_5", i)),
-		))
+		// Add button row for the quantities
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, qty := range quantities {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(quantityEmoji(qty), fmt.Sprintf("%s%d_%d", prefix, i, qty)))
+		}
+		rows = append(rows, buttons)
 	}
 
-	// Add cancel button
+	// Add cancel and reorder buttons
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("❌ Stornieren", "g0"),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Stornieren", prefix+"0"),
+		tgbotapi.NewInlineKeyboardButtonData("🔁 Wie immer", prefix+reorderAction),
 	))
 
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
+// gyroskopKeyboard creates the keyboard of the gyroskop message: the order
+// buttons and a deep link to order privately
+func (b *Bot) gyroskopKeyboard(ctx context.Context, gyroskop *database.Gyroskop) tgbotapi.InlineKeyboardMarkup {
+	keyboard := b.createFoodOptionsKeyboard(gyroskop.FoodOptions, b.chatSettings(ctx, gyroskop.ChatID).quantityButtons())
+	if link := b.deepLink(gyroskop.ID); link != "" {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("💬 Privat bestellen", link),
+		))
+	}
+	return keyboard
+}
+
+// quantityEmoji returns the keycap emoji of a quantity, e.g. 1️⃣
+func quantityEmoji(qty int) string {
+	switch {
+	case qty >= 0 && qty <= 9:
+		return fmt.Sprintf("%d\ufe0f\u20e3", qty)
+	case qty == 10:
+		return "🔟"
+	default:
+		return strconv.Itoa(qty)
+	}
+}
+
 // sendMessageWithReactions sendet eine Nachricht mit Reaction-Buttons
-func (b *Bot) sendMessageWithReactions(chatID int64, text string, foodOptions []string) *tgbotapi.Message {
-	keyboard := b.createFoodOptionsKeyboard(foodOptions)
+func (b *Bot) sendMessageWithReactions(ctx context.Context, chatID int64, text string, gyroskop *database.Gyroskop) *tgbotapi.Message {
+	keyboard := b.gyroskopKeyboard(ctx, gyroskop)
 
 	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ParseMode = messageFormat.ParseMode()
 	msg.ReplyMarkup = keyboard
 
-	sentMessage, err := b.api.Send(msg)
+	sentMessage, err := b.send(ctx, chatID, msg)
 	if err != nil {
-		log.Printf("Fehler beim Senden der Nachricht mit Reactions: %v", err)
+		b.log.ErrorContext(ctx, "Fehler beim Senden der Nachricht mit Reactions", "error", err)
 		return nil
 	}
 
@@ -990,184 +1375,255 @@ func (b *Bot) sendMessageWithReactions(chatID int64, text string, foodOptions []
 }
 
 // answerCallbackQuery antwortet auf eine Callback Query
-func (b *Bot) answerCallbackQuery(callbackQueryID, text string) {
+func (b *Bot) answerCallbackQuery(ctx context.Context, callbackQueryID, text string) {
 	callback := tgbotapi.NewCallback(callbackQueryID, text)
-	_, err := b.api.Request(callback)
+	_, err := b.request(ctx, 0, callback)
 	if err != nil {
-		log.Printf("Fehler beim Antworten auf Callback Query: %v", err)
+		b.log.ErrorContext(ctx, "Fehler beim Antworten auf Callback Query", "error", err)
 	}
 }
 
-// updateGyroskopMessage aktualisiert die Gyroskop-Nachricht mit aktuellen Bestellungen
-func (b *Bot) updateGyroskopMessage(gyroskop *database.Gyroskop, originalMessage *tgbotapi.Message) {
+// updateGyroskopMessage aktualisiert die Gyroskop-Nachricht mit aktuellen Bestellungen.
+// The edit is queued and built when it is sent, so several updates in quick
+// succession result in a single edit showing the latest orders.
+func (b *Bot) updateGyroskopMessage(ctx context.Context, gyroskop *database.Gyroskop, originalMessage *tgbotapi.Message) {
 	// MessageID verwenden - falls nicht gesetzt, die von der Callback-Query nehmen
 	messageID := gyroskop.MessageID
 	if messageID == 0 {
+		if originalMessage == nil {
+			return
+		}
 		messageID = originalMessage.MessageID
 	}
 
-	// Aktuelle Bestellungen laden
-	orders, err := b.db.GetOrdersByGyroskop(gyroskop.ID)
-	if err != nil {
-		log.Printf("Fehler beim Laden der Bestellungen für Update: %v", err)
-		return
-	}
+	b.outbox.Edit(ctx, gyroskop.ChatID, messageID, func(ctx context.Context) (tgbotapi.Chattable, error) {
+		return b.buildGyroskopMessageEdit(ctx, gyroskop, messageID)
+	})
+}
 
-	// Ersteller-Name laden
-	var creatorName string
-	for _, order := range orders {
-		if order.UserID == gyroskop.CreatedBy {
-			creatorName = b.formatUserName(&order)
-			break
-		}
-	}
-	if creatorName == "" {
-		creatorName = fmt.Sprintf("User %d", gyroskop.CreatedBy)
+// buildGyroskopMessageEdit builds the edit of the gyroskop message with the current orders
+func (b *Bot) buildGyroskopMessageEdit(ctx context.Context, gyroskop *database.Gyroskop, messageID int) (tgbotapi.Chattable, error) {
+	text, err := b.gyroskopMessageText(ctx, gyroskop)
+	if err != nil {
+		return nil, err
 	}
 
-	// Convert deadline to Berlin timezone for display
-	berlin, _ := time.LoadLocation("Europe/Berlin")
-	deadlineInBerlin := gyroskop.Deadline.In(berlin)
-
-	// Neue Nachricht zusammenstellen
-	text := fmt.Sprintf("🥙 *%s geöffnet!*\n\n"+
-		"👤 Erstellt von: %s\n"+
-		"⏰ Deadline: %s Uhr\n\n",
-		gyroskop.Name,
-		creatorName,
-		deadlineInBerlin.Format("15:04"))
-
-	// Aktuelle Bestellungen hinzufügen
-	if len(orders) > 0 {
-		text += "📋 *Aktuelle Bestellungen:*\n"
-		totals := make(map[string]int)
-
-		for _, order := range orders {
-			name := b.formatUserName(&order)
-			orderText := b.formatOrderQuantities(order.Quantities, gyroskop.FoodOptions)
-
-			text += fmt.Sprintf("• %s: %s\n", name, orderText)
-
-			// Add to totals
-			for option, qty := range order.Quantities {
-				totals[option] += qty
-			}
-		}
-
-		// Format totals
-		var totalItems int
-		var totalParts []string
-		for _, option := range gyroskop.FoodOptions {
-			if qty, ok := totals[option]; ok && qty > 0 {
-				totalItems += qty
-				totalParts = append(totalParts, fmt.Sprintf("%d %s", qty, option))
-			}
-		}
+	// Nachricht editieren
+	keyboard := b.gyroskopKeyboard(ctx, gyroskop)
+	edit := tgbotapi.NewEditMessageText(gyroskop.ChatID, messageID, text)
+	edit.ParseMode = messageFormat.ParseMode()
+	edit.ReplyMarkup = &keyboard
 
-		text += fmt.Sprintf("\n🥙 *Aktuell: %d* (%s)\n\n", totalItems, strings.Join(totalParts, ", "))
-	} else {
-		text += "📋 *Noch keine Bestellungen*\n\n"
-	}
+	return edit, nil
+}
 
-	// Generate example orders
-	var examples []string
-	for _, option := range gyroskop.FoodOptions {
-		examples = append(examples, fmt.Sprintf("'2 %s'", strings.ToLower(option)))
+// gyroskopMessageText formats the live gyroskop message with the current orders
+func (b *Bot) gyroskopMessageText(ctx context.Context, gyroskop *database.Gyroskop) (string, error) {
+	// Aktuelle Bestellungen laden
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+	if err != nil {
+		return "", fmt.Errorf("loading orders: %w", err)
 	}
 
-	text += fmt.Sprintf("Zum Bestellen schreibt %s oder nutzt die Buttons unten.\n\n", strings.Join(examples, ", "))
-	text += "Zum Beenden: /ende"
-
-	// Inline Keyboard mit Reaction-Buttons dynamisch erstellen
-	keyboard := b.createFoodOptionsKeyboard(gyroskop.FoodOptions)
-
-	// Nachricht editieren
-	edit := tgbotapi.NewEditMessageText(originalMessage.Chat.ID, messageID, text)
-	edit.ParseMode = tgbotapi.ModeMarkdown
-	edit.ReplyMarkup = &keyboard
+	return b.liveMessage(ctx, gyroskop, orders, b.creatorName(gyroskop, orders), false), nil
+}
 
-	_, err = b.api.Send(edit)
-	if err != nil {
-		log.Printf("Fehler beim Editieren der Gyroskop-Nachricht: %v", err)
+// creatorName returns the name of the creator of a gyroskop as known from their order
+func (b *Bot) creatorName(gyroskop *database.Gyroskop, orders []database.Order) string {
+	for _, order := range orders {
+		if order.UserID == gyroskop.CreatedBy {
+			return b.formatUserName(&order)
+		}
 	}
+	return fmt.Sprintf("User %d", gyroskop.CreatedBy)
 }
 
 // loadActiveGyroskops lädt alle aktiven Gyroskops beim Bot-Start
-func (b *Bot) loadActiveGyroskops() {
-	gyroskops, err := b.db.GetAllActiveGyroskops()
+func (b *Bot) loadActiveGyroskops(ctx context.Context) {
+	gyroskops, err := b.db.GetAllActiveGyroskops(ctx)
 	if err != nil {
-		log.Printf("Error loading active gyroskops: %v", err)
+		b.log.Error("Error loading active gyroskops", "error", err)
 		return
 	}
 
 	now := time.Now()
-	for _, gyroskop := range gyroskops {
+	loaded := 0
+	for i := range gyroskops {
+		gyroskop := &gyroskops[i]
+
 		// Prüfen ob das Gyroskop bereits abgelaufen ist
 		if gyroskop.Deadline.Before(now) {
 			// Automatisch schließen
-			go b.autoCloseGyroskop(&gyroskop)
+			b.spawn(func() { b.autoCloseGyroskop(ctx, gyroskop) })
 			continue
 		}
 
 		// In Cache laden
-		b.activeGyroskops[gyroskop.ChatID] = &gyroskop
+		b.setActiveGyroskop(gyroskop)
+		loaded++
 	}
 
-	log.Printf("Bot started - %d active gyroskops loaded", len(b.activeGyroskops))
+	b.log.Info("Bot started", "active_gyroskops", loaded)
 }
 
 // backgroundExpiryChecker runs in a background goroutine and checks for expired gyroskops every minute
-func (b *Bot) backgroundExpiryChecker() {
+// until ctx is cancelled. Expired gyroskops are closed with workCtx.
+func (b *Bot) backgroundExpiryChecker(ctx, workCtx context.Context) {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
-	log.Println("Background expiry checker started")
+	b.log.Debug("Background expiry checker started")
 
 	for {
 		select {
-		case <-b.stopChan:
-			log.Println("Background expiry checker stopping...")
+		case <-ctx.Done():
+			b.log.Debug("Background expiry checker stopping...")
 			return
 		case <-ticker.C:
-			b.checkExpiredGyroskops()
+			b.checkExpiredGyroskops(workCtx)
+			b.checkExpiredVotes(workCtx)
 		}
 	}
 }
 
 // checkExpiredGyroskops checks for and closes any expired gyroskops
-func (b *Bot) checkExpiredGyroskops() {
+func (b *Bot) checkExpiredGyroskops(ctx context.Context) {
 	now := time.Now()
 
 	// Check all active gyroskops in cache
-	for chatID, gyroskop := range b.activeGyroskops {
+	cached := b.getActiveGyroskops()
+	for _, gyroskop := range cached {
 		if gyroskop.Deadline.Before(now) {
-			log.Printf("Found expired gyroskop %d in chat %d, closing...", gyroskop.ID, chatID)
-			go b.autoCloseGyroskop(gyroskop)
+			b.log.Debug("Found expired gyroskop, closing...", "chat_id", gyroskop.ChatID, "gyroskop_id", gyroskop.ID)
+			gyroskop := gyroskop
+			b.spawn(func() { b.autoCloseGyroskop(ctx, gyroskop) })
 		}
 	}
 
 	// Also check database directly in case cache is out of sync
-	gyroskops, err := b.db.GetAllActiveGyroskops()
+	gyroskops, err := b.db.GetAllActiveGyroskops(ctx)
 	if err != nil {
-		log.Printf("Error checking active gyroskops from database: %v", err)
+		b.log.Error("Error checking active gyroskops from database", "error", err)
 		return
 	}
 
-	for _, gyroskop := range gyroskops {
+	for i := range gyroskops {
+		gyroskop := &gyroskops[i]
 		if gyroskop.Deadline.Before(now) {
 			// Check if we already have this in cache (to avoid double processing)
-			if cachedGyroskop, exists := b.activeGyroskops[gyroskop.ChatID]; exists && cachedGyroskop.ID == gyroskop.ID {
+			if cachedGyroskop, exists := cached[gyroskop.ChatID]; exists && cachedGyroskop.ID == gyroskop.ID {
 				// Already handled above
 				continue
 			}
 
-			log.Printf("Found expired gyroskop %d in database (not in cache), closing...", gyroskop.ID)
-			go b.autoCloseGyroskop(&gyroskop)
+			b.log.Warn("Found expired gyroskop in database (not in cache), closing...", "chat_id", gyroskop.ChatID, "gyroskop_id", gyroskop.ID)
+			b.spawn(func() { b.autoCloseGyroskop(ctx, gyroskop) })
+		}
+	}
+}
+
+// getActiveGyroskop returns the cached active gyroskop of a chat
+func (b *Bot) getActiveGyroskop(chatID int64) (*database.Gyroskop, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	gyroskop, exists := b.activeGyroskops[chatID]
+	return gyroskop, exists
+}
+
+// findActiveGyroskop returns the cached active gyroskop with the given ID
+func (b *Bot) findActiveGyroskop(gyroskopID int) (*database.Gyroskop, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, gyroskop := range b.activeGyroskops {
+		if gyroskop.ID == gyroskopID {
+			return gyroskop, true
 		}
 	}
+	return nil, false
+}
+
+// getActiveGyroskops returns a snapshot of all cached active gyroskops by chat
+func (b *Bot) getActiveGyroskops() map[int64]*database.Gyroskop {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snapshot := make(map[int64]*database.Gyroskop, len(b.activeGyroskops))
+	for chatID, gyroskop := range b.activeGyroskops {
+		snapshot[chatID] = gyroskop
+	}
+	return snapshot
+}
+
+// setActiveGyroskop stores a gyroskop as the active one of its chat
+func (b *Bot) setActiveGyroskop(gyroskop *database.Gyroskop) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.activeGyroskops[gyroskop.ChatID] = gyroskop
+}
+
+// removeActiveGyroskop removes a gyroskop from the cache if it is still the active one of its chat
+func (b *Bot) removeActiveGyroskop(gyroskop *database.Gyroskop) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cached, exists := b.activeGyroskops[gyroskop.ChatID]; exists && cached.ID == gyroskop.ID {
+		delete(b.activeGyroskops, gyroskop.ChatID)
+	}
+}
+
+// send queues a message for a chat and waits until it was sent
+func (b *Bot) send(ctx context.Context, chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	resp, err := b.outbox.Send(ctx, chatID, c)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	var message tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &message)
+	return message, err
+}
+
+// request queues a request that does not return a message and waits until it was made.
+// Requests that do not post to a chat use chatID 0.
+func (b *Bot) request(ctx context.Context, chatID int64, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return b.outbox.Send(ctx, chatID, c)
+}
+
+// Ping checks that the Telegram Bot API is reachable with the configured token.
+// The request is cancelled with ctx, as the client of the library has no timeout.
+func (b *Bot) Ping(ctx context.Context) error {
+	endpoint := fmt.Sprintf(tgbotapi.APIEndpoint, b.api.Token, "getMe")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return errors.New(logging.Redact(err.Error()))
+	}
+
+	resp, err := b.api.Client.Do(req)
+	if err != nil {
+		metrics.TelegramAPIErrors.Inc("getMe")
+		return errors.New(logging.Redact(err.Error()))
+	}
+	defer resp.Body.Close()
+
+	var apiResp tgbotapi.APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		metrics.TelegramAPIErrors.Inc("getMe")
+		return fmt.Errorf("decoding getMe response: %w", err)
+	}
+	if !apiResp.Ok {
+		metrics.TelegramAPIErrors.Inc("getMe")
+		return &tgbotapi.Error{Code: apiResp.ErrorCode, Message: apiResp.Description}
+	}
+	return nil
+}
+
+// apiLogger passes log output of the Telegram library to slog
+type apiLogger struct {
+	log *slog.Logger
+}
+
+func (l apiLogger) Println(v ...interface{}) {
+	l.log.Warn(strings.TrimSpace(fmt.Sprintln(v...)), "component", "telegram")
 }
 
-// Stop gracefully stops the bot
-func (b *Bot) Stop() {
-	close(b.stopChan)
+func (l apiLogger) Printf(format string, v ...interface{}) {
+	l.log.Warn(fmt.Sprintf(format, v...), "component", "telegram")
 }
@@ -1,14 +1,35 @@
 package bot
 
 import (
+	"context"
+	"io"
+	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// newTestBot returns a bot without Telegram API and database using the built-in defaults
+func newTestBot() *Bot {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		panic(err)
+	}
+	return &Bot{defaults: Defaults{
+		Duration:    15 * time.Minute,
+		Name:        "Gyros",
+		FoodOptions: []string{"Fleisch", "Vegetarisch"},
+		MaxQuantity: 10,
+		Location:    berlin,
+	}}
+}
+
 func TestParseDeadline(t *testing.T) {
 	// Create a bot instance (db can be nil for these tests)
-	b := &Bot{}
+	b := newTestBot()
 
 	// Get current time for reference
 	now := time.Now()
@@ -143,7 +164,7 @@ func TestParseDeadline(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := b.parseDeadline(tt.input)
+			result, err := b.parseDeadline(tt.input, b.defaults.Duration)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseDeadline() error = %v, wantErr %v", err, tt.wantErr)
@@ -159,7 +180,7 @@ func TestParseDeadline(t *testing.T) {
 }
 
 func TestParseGyroskopArgs(t *testing.T) {
-	b := &Bot{}
+	b := newTestBot()
 	now := time.Now()
 
 	tests := []struct {
@@ -383,7 +404,7 @@ func TestParseGyroskopArgs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			deadline, name, options, err := b.parseGyroskopArgs(tt.args)
+			deadline, name, options, err := b.parseGyroskopArgs(tt.args, b.defaults.settings(nil))
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseGyroskopArgs() error = %v, wantErr %v", err, tt.wantErr)
@@ -407,7 +428,7 @@ func TestParseGyroskopArgs(t *testing.T) {
 }
 
 func TestParseOrderText(t *testing.T) {
-	b := &Bot{}
+	b := newTestBot()
 
 	tests := []struct {
 		name        string
@@ -539,7 +560,7 @@ func TestParseOrderText(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := b.parseOrderText(tt.text, tt.foodOptions)
+			got := b.parseOrderText(tt.text, tt.foodOptions, b.defaults.MaxQuantity)
 
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("parseOrderText() = %v, want %v\nDescription: %s",
@@ -550,7 +571,7 @@ func TestParseOrderText(t *testing.T) {
 }
 
 func TestFormatOrderQuantities(t *testing.T) {
-	b := &Bot{}
+	b := newTestBot()
 
 	tests := []struct {
 		name        string
@@ -605,3 +626,73 @@ func TestFormatOrderQuantities(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateQueues(t *testing.T) {
+	var q updateQueues
+
+	if !q.push(1, tgbotapi.Update{UpdateID: 1}) {
+		t.Fatal("first update of a chat should start a worker")
+	}
+	if q.push(1, tgbotapi.Update{UpdateID: 2}) {
+		t.Error("second update of a busy chat should not start a worker")
+	}
+	if !q.push(2, tgbotapi.Update{UpdateID: 3}) {
+		t.Error("first update of another chat should start a worker")
+	}
+
+	for _, want := range []int{1, 2} {
+		update, ok := q.pop(1)
+		if !ok || update.UpdateID != want {
+			t.Fatalf("pop() = %d, %v, want %d", update.UpdateID, ok, want)
+		}
+	}
+	if _, ok := q.pop(1); ok {
+		t.Fatal("pop() of an empty queue should stop the worker")
+	}
+	if !q.push(1, tgbotapi.Update{UpdateID: 4}) {
+		t.Error("update after the worker stopped should start a new one")
+	}
+}
+
+// pingClient answers getMe with body, or blocks until the request is cancelled if body is empty
+type pingClient struct {
+	body string
+}
+
+func (c pingClient) Do(req *http.Request) (*http.Response, error) {
+	if c.body == "" {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(c.body))}, nil
+}
+
+func TestPing(t *testing.T) {
+	token := "123456:ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghij"
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"reachable", `{"ok": true, "result": {"id": 1, "is_bot": true}}`, false},
+		{"invalid token", `{"ok": false, "error_code": 401, "description": "Unauthorized"}`, true},
+		{"timeout", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBot()
+			b.api = &tgbotapi.BotAPI{Token: token, Client: pingClient{body: tt.body}}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := b.Ping(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), token) {
+				t.Errorf("Ping() error contains the token: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,558 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Strategies to split the extra costs of a gyroskop
+const (
+	splitEqual   = "gleich"  // The same part for everyone who ordered
+	splitItems   = "artikel" // Proportional to the number of items ordered
+	splitAmounts = "betrag"  // Proportional to the amounts entered per person
+)
+
+// splitStrategies are all strategies in the order of the settings button
+var splitStrategies = []string{splitEqual, splitItems, splitAmounts}
+
+// splitLabels describe the strategies
+var splitLabels = map[string]string{
+	splitEqual:   "gleich pro Person",
+	splitItems:   "nach Anzahl der Artikel",
+	splitAmounts: "nach Betrag",
+}
+
+// nextSplit returns the strategy after split, for the settings button
+func nextSplit(split string) string {
+	i := slices.Index(splitStrategies, split)
+	return splitStrategies[(i+1)%len(splitStrategies)]
+}
+
+// discountLabels are labels of extra costs that are always discounts
+var discountLabels = []string{"rabatt", "gutschein", "coupon", "gutschrift"}
+
+// percentPattern matches percentages like 10% or -15 %
+var percentPattern = regexp.MustCompile(`^(-?)(\d{1,2})\s?%$`)
+
+var (
+	errInvalidAmount = errors.New("invalid amount")
+	errNoAmounts     = errors.New("no amounts entered")
+	errNoOrders      = errors.New("no orders")
+)
+
+// parseCostValue parses the value of an extra cost, an amount or a percentage
+func parseCostValue(input string) (amount, percent int, err error) {
+	if m := percentPattern.FindStringSubmatch(input); m != nil {
+		percent, _ = strconv.Atoi(m[2])
+		if m[1] == "-" {
+			percent = -percent
+		}
+		return 0, percent, nil
+	}
+	amount, ok := parsePrice(input, true)
+	if !ok {
+		return 0, 0, errInvalidAmount
+	}
+	return amount, 0, nil
+}
+
+// splitLabelValue splits "Label Wert" at the last space. A trailing € or %
+// written apart belongs to the value.
+func splitLabelValue(entry string) (label, value string, ok bool) {
+	fields := strings.Fields(entry)
+	if n := len(fields); n > 2 && (fields[n-1] == "€" || fields[n-1] == "%") {
+		fields = append(fields[:n-2], fields[n-2]+fields[n-1])
+	}
+	if len(fields) < 2 {
+		return "", "", false
+	}
+	return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1], true
+}
+
+// capitalize upper-cases the first letter of a label
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// parseCosts parses extra costs and discounts
+// Format: Lieferung 3,50, Trinkgeld 10%, Rabatt 5 (0 removes a cost)
+func parseCosts(input string) ([]database.Cost, error) {
+	var costs []database.Cost
+	for _, entry := range splitMenu(input) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		label, value, ok := splitLabelValue(entry)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errInvalidAmount, strings.TrimSpace(entry))
+		}
+		amount, percent, err := parseCostValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidAmount, value)
+		}
+		if slices.Contains(discountLabels, strings.ToLower(label)) {
+			amount, percent = -abs(amount), -abs(percent)
+		}
+		costs = append(costs, database.Cost{Label: capitalize(label), Amount: amount, Percent: percent})
+	}
+	if len(costs) == 0 {
+		return nil, errInvalidAmount
+	}
+	return costs, nil
+}
+
+// mergeCosts adds changed costs to the existing ones, replacing costs with
+// the same label. Costs of 0 are removed.
+func mergeCosts(existing, changes []database.Cost) []database.Cost {
+	merged := append([]database.Cost(nil), existing...)
+	for _, change := range changes {
+		i := slices.IndexFunc(merged, func(c database.Cost) bool { return strings.EqualFold(c.Label, change.Label) })
+		switch {
+		case change.Amount == 0 && change.Percent == 0:
+			if i >= 0 {
+				merged = slices.Delete(merged, i, i+1)
+			}
+		case i >= 0:
+			merged[i] = change
+		default:
+			merged = append(merged, change)
+		}
+	}
+	return merged
+}
+
+// personAmount is an amount entered for a person by name
+type personAmount struct {
+	Name   string
+	Amount int // In cents, 0 removes the amount
+}
+
+// parsePersonAmounts parses the amounts per person
+// Format: @anna 12,50, Ben Müller 8
+func parsePersonAmounts(input string) ([]personAmount, error) {
+	var amounts []personAmount
+	for _, entry := range splitMenu(input) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, value, ok := splitLabelValue(entry)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errInvalidAmount, strings.TrimSpace(entry))
+		}
+		amount, ok := parsePrice(value, true)
+		if !ok || amount < 0 {
+			return nil, fmt.Errorf("%w: %s", errInvalidAmount, value)
+		}
+		amounts = append(amounts, personAmount{Name: name, Amount: amount})
+	}
+	if len(amounts) == 0 {
+		return nil, errInvalidAmount
+	}
+	return amounts, nil
+}
+
+// findOrderByName finds the order of a person by @username, full name or
+// unambiguous first name
+func (b *Bot) findOrderByName(orders []database.Order, name string) *database.Order {
+	if username, ok := strings.CutPrefix(name, "@"); ok {
+		for i := range orders {
+			if strings.EqualFold(orders[i].Username, username) {
+				return &orders[i]
+			}
+		}
+		return nil
+	}
+
+	var byFirstName []*database.Order
+	for i := range orders {
+		if strings.EqualFold(b.formatUserName(&orders[i]), name) {
+			return &orders[i]
+		}
+		if strings.EqualFold(orders[i].FirstName, name) {
+			byFirstName = append(byFirstName, &orders[i])
+		}
+	}
+	if len(byFirstName) == 1 {
+		return byFirstName[0]
+	}
+	return nil
+}
+
+// share is the part of the costs one person pays, in cents
+type share struct {
+	Order  *database.Order
+	Amount int // As entered by the collector
+	Extra  int // Part of the extra costs
+}
+
+// Total is what the person pays
+func (s share) Total() int {
+	return s.Amount + s.Extra
+}
+
+// costSplit is the split of the costs of a gyroskop. The totals of the shares
+// add up to Total exactly.
+type costSplit struct {
+	Strategy string
+	Subtotal int             // Sum of the entered amounts
+	FromMenu bool            // The amounts are calculated from the menu prices
+	Extras   []database.Cost // Percentages are resolved into amounts
+	Total    int
+	Shares   []share // In the order of the orders
+}
+
+// percentOf returns percent of cents, rounded half away from zero
+func percentOf(cents, percent int) int {
+	value := (abs(cents)*abs(percent) + 50) / 100
+	if (cents < 0) != (percent < 0) {
+		return -value
+	}
+	return value
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// menuAmounts calculates the amount of every order from the menu prices. It
+// fails if an ordered option has no price.
+func menuAmounts(orders []database.Order, menu []database.MenuItem) (map[int64]int, bool) {
+	prices := make(map[string]int, len(menu))
+	for _, item := range menu {
+		if item.Price > 0 {
+			prices[item.Name] = item.Price
+		}
+	}
+
+	amounts := make(map[int64]int, len(orders))
+	for _, order := range orders {
+		for option, qty := range order.Quantities {
+			if qty <= 0 {
+				continue
+			}
+			price, ok := prices[option]
+			if !ok {
+				return nil, false
+			}
+			amounts[order.UserID] += qty * price
+		}
+	}
+	return amounts, true
+}
+
+// splitCosts splits the extra costs among the orders. Percentages are of the
+// entered amounts, so they and splitAmounts need amounts. If none were
+// entered, the amounts are calculated from the prices of the menu, if any.
+func splitCosts(orders []database.Order, costs *database.Costs, strategy string, menu []database.MenuItem) (*costSplit, error) {
+	if len(orders) == 0 {
+		return nil, errNoOrders
+	}
+
+	s := &costSplit{Strategy: strategy}
+	amounts := costs.Amounts
+	if len(amounts) == 0 && len(menu) > 0 {
+		amounts, s.FromMenu = menuAmounts(orders, menu)
+	}
+	s.Shares = make([]share, len(orders))
+	for i := range orders {
+		s.Shares[i] = share{Order: &orders[i], Amount: amounts[orders[i].UserID]}
+		s.Subtotal += s.Shares[i].Amount
+	}
+
+	extras := 0
+	for _, cost := range costs.Extras {
+		if cost.Percent != 0 {
+			if s.Subtotal == 0 {
+				return nil, errNoAmounts
+			}
+			cost.Amount = percentOf(s.Subtotal, cost.Percent)
+		}
+		s.Extras = append(s.Extras, cost)
+		extras += cost.Amount
+	}
+	s.Total = s.Subtotal + extras
+
+	weights := make([]int, len(orders))
+	for i, order := range orders {
+		switch strategy {
+		case splitItems:
+			for _, qty := range order.Quantities {
+				weights[i] += qty
+			}
+		case splitAmounts:
+			weights[i] = s.Shares[i].Amount
+		default:
+			weights[i] = 1
+		}
+	}
+	if strategy == splitAmounts && s.Subtotal == 0 && extras != 0 {
+		return nil, errNoAmounts
+	}
+
+	for i, part := range distribute(extras, weights) {
+		s.Shares[i].Extra = part
+	}
+	return s, nil
+}
+
+// distribute splits cents proportionally to the weights so that the parts
+// add up to cents exactly. The cents left over by rounding down go to the
+// largest remainders, ties to the earlier parts. Without weights the cents
+// are split equally.
+func distribute(cents int, weights []int) []int {
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum == 0 {
+		weights = make([]int, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		sum = len(weights)
+	}
+
+	sign := 1
+	if cents < 0 {
+		sign, cents = -1, -cents
+	}
+
+	parts := make([]int, len(weights))
+	remainders := make([]int, len(weights))
+	left := cents
+	for i, w := range weights {
+		parts[i] = cents * w / sum
+		remainders[i] = cents * w % sum
+		left -= parts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:left] {
+		parts[i]++
+	}
+
+	for i := range parts {
+		parts[i] *= sign
+	}
+	return parts
+}
+
+// costLines formats a split for the summary. The shares per person are only
+// listed if reveal is set.
+func (b *Bot) costLines(s *costSplit, reveal bool) []render.Line {
+	lines := []render.Line{{render.Plain("💶 "), render.Bold("Kosten"), render.Plain(" (Aufteilung " + splitLabels[s.Strategy] + ")")}}
+	switch {
+	case s.Subtotal != 0 && s.FromMenu:
+		lines = append(lines, render.Line{render.Plain("Bestellungen (laut Karte): " + formatPrice(s.Subtotal))})
+	case s.Subtotal != 0:
+		lines = append(lines, render.Line{render.Plain("Bestellungen: " + formatPrice(s.Subtotal))})
+	}
+	for _, cost := range s.Extras {
+		label := cost.Label
+		if cost.Percent != 0 {
+			label += fmt.Sprintf(" (%d %%)", cost.Percent)
+		}
+		lines = append(lines, render.Line{render.Plain(label + ": " + formatPrice(cost.Amount))})
+	}
+	lines = append(lines, render.Line{render.Bold("Gesamt: " + formatPrice(s.Total))})
+	if !reveal {
+		return lines
+	}
+
+	lines = append(lines, nil)
+	for _, sh := range s.Shares {
+		text := fmt.Sprintf("• %s: %s", b.formatUserName(sh.Order), formatPrice(sh.Total()))
+		if sh.Amount != 0 && sh.Extra != 0 {
+			text += fmt.Sprintf(" (%s + %s)", formatPrice(sh.Amount), formatPrice(sh.Extra))
+		}
+		lines = append(lines, render.Line{render.Plain(text)})
+	}
+	return lines
+}
+
+// splitError explains why costs cannot be split
+func splitError(err error) string {
+	switch {
+	case errors.Is(err, errNoOrders):
+		return "⚠️ Niemand hat bestellt, es gibt nichts aufzuteilen."
+	case errors.Is(err, errNoAmounts):
+		return "⚠️ Prozente und die Aufteilung nach Betrag brauchen Preise für alle bestellten Gerichte in der Karte des Restaurants oder die Beträge pro Person: /kosten betrag Name 12,50, ..."
+	}
+	return "❌ Die Kosten können nicht aufgeteilt werden."
+}
+
+// gyroskopCosts loads the costs of a gyroskop and splits them. The split is
+// nil if no costs were entered.
+func (b *Bot) gyroskopCosts(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order) (*costSplit, error) {
+	costs, err := b.db.GetGyroskopCosts(ctx, gyroskop.ID)
+	if err != nil {
+		return nil, err
+	}
+	if costs.IsEmpty() {
+		return nil, nil
+	}
+	strategy := costs.Split
+	if strategy == "" {
+		strategy = b.chatSettings(ctx, gyroskop.ChatID).Split
+	}
+	var menu []database.MenuItem
+	if r := b.gyroskopRestaurant(ctx, gyroskop); r != nil {
+		menu = r.Menu
+	}
+	return splitCosts(orders, costs, strategy, menu)
+}
+
+// summaryCosts returns the costs paragraph of the summary, "" if there are none
+func (b *Bot) summaryCosts(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order) string {
+	split, err := b.gyroskopCosts(ctx, gyroskop, orders)
+	if errors.Is(err, errNoOrders) || errors.Is(err, errNoAmounts) {
+		return messageFormat.Line(render.Line{render.Plain(splitError(err))})
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Kosten", "error", err)
+		return ""
+	}
+	if split == nil {
+		return ""
+	}
+	return messageFormat.Lines(b.costLines(split, !gyroskop.Hidden)...)
+}
+
+// handleCosts enters the extra costs of the replied-to or latest gyroskop and
+// shows how they are split
+// Format:
+//
+//	/kosten -> show the split
+//	/kosten Lieferung 3,50, Trinkgeld 10%, Rabatt 5 -> add or change costs, 0 removes one
+//	/kosten betrag @anna 12,50, Ben 8 -> enter the amounts per person
+//	/kosten aufteilung gleich|artikel|betrag -> choose how the costs are split
+//	/kosten zurücksetzen -> remove all costs and amounts
+func (b *Bot) handleCosts(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+	gyroskop, err := b.targetGyroskop(ctx, message)
+	if err != nil {
+		b.sendMessage(ctx, chatID, "❌ Kein Gyroskop gefunden")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Bestellungen")
+		return
+	}
+
+	args = strings.TrimSpace(args)
+	if args == "" {
+		b.sendCosts(ctx, message, gyroskop, orders)
+		return
+	}
+
+	if !b.authorize(ctx, message, gyroskop, actionEdit, "die Kosten ändern") {
+		return
+	}
+
+	costs, err := b.db.GetGyroskopCosts(ctx, gyroskop.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Kosten", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Kosten")
+		return
+	}
+
+	keyword, rest, _ := strings.Cut(args, " ")
+	switch strings.ToLower(keyword) {
+	case "zurücksetzen", "reset":
+		costs = &database.Costs{GyroskopID: gyroskop.ID}
+	case "aufteilung":
+		strategy := strings.ToLower(strings.TrimSpace(rest))
+		if !slices.Contains(splitStrategies, strategy) {
+			b.sendMessage(ctx, chatID, "⚠️ Ungültige Aufteilung. Verwende: /kosten aufteilung "+strings.Join(splitStrategies, "|"))
+			return
+		}
+		costs.Split = strategy
+	case "betrag", "beträge":
+		amounts, err := parsePersonAmounts(rest)
+		if err != nil {
+			b.sendMessage(ctx, chatID, "⚠️ Ungültiges Format. Verwende: /kosten betrag @anna 12,50, Ben 8")
+			return
+		}
+		if costs.Amounts == nil {
+			costs.Amounts = make(map[int64]int)
+		}
+		for _, amount := range amounts {
+			order := b.findOrderByName(orders, amount.Name)
+			if order == nil {
+				b.sendFormatted(ctx, chatID, messageFormat.Escape(fmt.Sprintf("⚠️ Keine Bestellung von %s gefunden. Verwende @nutzername oder den Namen aus der Übersicht.", amount.Name)))
+				return
+			}
+			if amount.Amount == 0 {
+				delete(costs.Amounts, order.UserID)
+			} else {
+				costs.Amounts[order.UserID] = amount.Amount
+			}
+		}
+	default:
+		changes, err := parseCosts(args)
+		if err != nil {
+			b.sendMessage(ctx, chatID, "⚠️ Ungültiges Format. Verwende: /kosten Lieferung 3,50, Trinkgeld 10%, Rabatt 5")
+			return
+		}
+		costs.Extras = mergeCosts(costs.Extras, changes)
+	}
+
+	if err := b.db.SaveGyroskopCosts(ctx, costs, message.From.ID); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Speichern der Kosten", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Speichern der Kosten")
+		return
+	}
+	b.log.InfoContext(ctx, "Gyroskop costs changed")
+
+	b.sendCosts(ctx, message, gyroskop, orders)
+	b.updateSummaryMessage(ctx, gyroskop, false)
+}
+
+// sendCosts answers with the split of the costs. For hidden gyroskops the
+// group only gets the totals, organisers get the shares privately.
+func (b *Bot) sendCosts(ctx context.Context, message *tgbotapi.Message, gyroskop *database.Gyroskop, orders []database.Order) {
+	chatID := message.Chat.ID
+	split, err := b.gyroskopCosts(ctx, gyroskop, orders)
+	switch {
+	case errors.Is(err, errNoOrders) || errors.Is(err, errNoAmounts):
+		b.sendMessage(ctx, chatID, splitError(err))
+		return
+	case err != nil:
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Kosten", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Kosten")
+		return
+	case split == nil:
+		b.sendMessage(ctx, chatID, "💶 Keine Zusatzkosten eingetragen. Verwende: /kosten Lieferung 3,50, Trinkgeld 10%, Rabatt 5")
+		return
+	}
+
+	reveal := !gyroskop.Hidden
+	b.sendFormatted(ctx, chatID, messageFormat.Lines(b.costLines(split, reveal)...))
+	if !reveal && b.roleOf(ctx, gyroskop, message.From.ID).can(actionEdit) {
+		b.sendFormatted(ctx, message.From.ID, messageFormat.Lines(b.costLines(split, true)...))
+	}
+}
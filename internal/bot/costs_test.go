@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestParseCosts(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []database.Cost
+		wantErr bool
+	}{
+		{"lieferung 3.50, trinkgeld 10%", []database.Cost{{Label: "Lieferung", Amount: 350}, {Label: "Trinkgeld", Percent: 10}}, false},
+		{"Lieferung 3,50 €, Trinkgeld 10 %", []database.Cost{{Label: "Lieferung", Amount: 350}, {Label: "Trinkgeld", Percent: 10}}, false},
+		{"Rabatt 5, Gutschein -2,5", []database.Cost{{Label: "Rabatt", Amount: -500}, {Label: "Gutschein", Amount: -250}}, false},
+		{"Extra Soße 1,20€", []database.Cost{{Label: "Extra Soße", Amount: 120}}, false},
+		{"Stammkunde -10%", []database.Cost{{Label: "Stammkunde", Percent: -10}}, false},
+		{"Lieferung 0", []database.Cost{{Label: "Lieferung"}}, false},
+		{"Lieferung", nil, true},
+		{"Lieferung drei", nil, true},
+		{"Trinkgeld 7,5%", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseCosts(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCosts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeCosts(t *testing.T) {
+	existing := []database.Cost{{Label: "Lieferung", Amount: 350}, {Label: "Trinkgeld", Percent: 10}}
+	changes := []database.Cost{{Label: "lieferung", Amount: 400}, {Label: "Trinkgeld"}, {Label: "Rabatt", Amount: -500}}
+	want := []database.Cost{{Label: "lieferung", Amount: 400}, {Label: "Rabatt", Amount: -500}}
+	if got := mergeCosts(existing, changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeCosts() = %+v, want %+v", got, want)
+	}
+	if existing[0].Amount != 350 {
+		t.Error("mergeCosts() changed the existing costs")
+	}
+}
+
+func TestParsePersonAmounts(t *testing.T) {
+	got, err := parsePersonAmounts("@anna 12,50, Ben Müller 8, Carl 0")
+	want := []personAmount{{"@anna", 1250}, {"Ben Müller", 800}, {"Carl", 0}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePersonAmounts() = %+v, %v, want %+v", got, err, want)
+	}
+
+	for _, input := range []string{"Anna", "Anna -5", "Anna viel"} {
+		if _, err := parsePersonAmounts(input); err == nil {
+			t.Errorf("parsePersonAmounts(%q) expected an error", input)
+		}
+	}
+}
+
+func TestFindOrderByName(t *testing.T) {
+	b := newTestBot()
+	orders := []database.Order{
+		{UserID: 1, Username: "anna", FirstName: "Anna", LastName: "Schmidt"},
+		{UserID: 2, FirstName: "Ben"},
+		{UserID: 3, FirstName: "Ben", LastName: "Müller"},
+	}
+
+	tests := []struct {
+		name string
+		want int64 // 0 for none
+	}{
+		{"@Anna", 1},
+		{"anna schmidt", 1},
+		{"Anna", 1},
+		{"Ben", 2},
+		{"Ben Müller", 3},
+		{"@ben", 0},
+		{"Carl", 0},
+	}
+
+	for _, tt := range tests {
+		got := b.findOrderByName(orders, tt.name)
+		if (got == nil && tt.want != 0) || (got != nil && got.UserID != tt.want) {
+			t.Errorf("findOrderByName(%q) = %+v, want user %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDistribute(t *testing.T) {
+	tests := []struct {
+		cents   int
+		weights []int
+		want    []int
+	}{
+		{1000, []int{1, 1, 1}, []int{334, 333, 333}},
+		{350, []int{1, 1, 1}, []int{117, 117, 116}},
+		{-500, []int{1, 1, 1}, []int{-167, -167, -166}},
+		{100, []int{1, 2, 3}, []int{17, 33, 50}},
+		{1, []int{1, 1}, []int{1, 0}},
+		{0, []int{1, 1}, []int{0, 0}},
+		{300, []int{0, 0, 0}, []int{100, 100, 100}},
+		{999, []int{1250, 800, 0}, []int{609, 390, 0}},
+	}
+
+	for _, tt := range tests {
+		got := distribute(tt.cents, tt.weights)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("distribute(%d, %v) = %v, want %v", tt.cents, tt.weights, got, tt.want)
+		}
+		sum := 0
+		for _, part := range got {
+			sum += part
+		}
+		if sum != tt.cents {
+			t.Errorf("distribute(%d, %v) sums to %d", tt.cents, tt.weights, sum)
+		}
+	}
+}
+
+func TestSplitCosts(t *testing.T) {
+	orders := []database.Order{
+		{UserID: 1, Quantities: map[string]int{"Margherita": 1}},
+		{UserID: 2, Quantities: map[string]int{"Margherita": 2, "Salami": 1}},
+		{UserID: 3, Quantities: map[string]int{"Salami": 1}},
+	}
+
+	tests := []struct {
+		name       string
+		costs      database.Costs
+		strategy   string
+		menu       []database.MenuItem
+		wantTotal  int
+		wantShares []int // Totals per person
+		wantErr    error
+	}{
+		{
+			name:       "equal",
+			costs:      database.Costs{Extras: []database.Cost{{Label: "Lieferung", Amount: 350}}},
+			strategy:   splitEqual,
+			wantTotal:  350,
+			wantShares: []int{117, 117, 116},
+		},
+		{
+			name:       "items",
+			costs:      database.Costs{Extras: []database.Cost{{Label: "Lieferung", Amount: 500}}},
+			strategy:   splitItems,
+			wantTotal:  500,
+			wantShares: []int{100, 300, 100},
+		},
+		{
+			name: "amounts with tip and discount",
+			costs: database.Costs{
+				Extras:  []database.Cost{{Label: "Lieferung", Amount: 350}, {Label: "Trinkgeld", Percent: 10}, {Label: "Rabatt", Amount: -500}},
+				Amounts: map[int64]int{1: 850, 2: 2250, 3: 999},
+			},
+			strategy:   splitAmounts,
+			wantTotal:  4099 + 350 + 410 - 500,
+			wantShares: []int{850 + 54, 2250 + 143, 999 + 63},
+		},
+		{
+			name: "equal with amounts",
+			costs: database.Costs{
+				Extras:  []database.Cost{{Label: "Trinkgeld", Percent: 10}},
+				Amounts: map[int64]int{1: 1000, 2: 1000, 3: 1000},
+			},
+			strategy:   splitEqual,
+			wantTotal:  3300,
+			wantShares: []int{1100, 1100, 1100},
+		},
+		{
+			name:     "percent without amounts",
+			costs:    database.Costs{Extras: []database.Cost{{Label: "Trinkgeld", Percent: 10}}},
+			strategy: splitEqual,
+			wantErr:  errNoAmounts,
+		},
+		{
+			name:       "percent with menu prices",
+			costs:      database.Costs{Extras: []database.Cost{{Label: "Lieferung", Amount: 350}, {Label: "Trinkgeld", Percent: 10}}},
+			strategy:   splitAmounts,
+			menu:       []database.MenuItem{{Name: "Margherita", Price: 850}, {Name: "Salami", Price: 950}},
+			wantTotal:  4450 + 350 + 445,
+			wantShares: []int{850 + 152, 2650 + 473, 950 + 170},
+		},
+		{
+			name:     "percent with incomplete menu prices",
+			costs:    database.Costs{Extras: []database.Cost{{Label: "Trinkgeld", Percent: 10}}},
+			strategy: splitEqual,
+			menu:     []database.MenuItem{{Name: "Margherita", Price: 850}, {Name: "Salami"}},
+			wantErr:  errNoAmounts,
+		},
+		{
+			name:     "split by amount without amounts",
+			costs:    database.Costs{Extras: []database.Cost{{Label: "Lieferung", Amount: 350}}},
+			strategy: splitAmounts,
+			wantErr:  errNoAmounts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split, err := splitCosts(orders, &tt.costs, tt.strategy, tt.menu)
+			if err != tt.wantErr {
+				t.Fatalf("splitCosts() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if split.Total != tt.wantTotal {
+				t.Errorf("Total = %d, want %d", split.Total, tt.wantTotal)
+			}
+			sum := 0
+			for i, s := range split.Shares {
+				sum += s.Total()
+				if s.Total() != tt.wantShares[i] {
+					t.Errorf("share %d = %d, want %d", i, s.Total(), tt.wantShares[i])
+				}
+			}
+			if sum != split.Total {
+				t.Errorf("shares sum to %d, want %d", sum, split.Total)
+			}
+		})
+	}
+
+	if _, err := splitCosts(nil, &database.Costs{}, splitEqual, nil); err != errNoOrders {
+		t.Errorf("splitCosts(nil) error = %v, want errNoOrders", err)
+	}
+}
+
+func TestNextSplit(t *testing.T) {
+	if got := nextSplit(splitEqual); got != splitItems {
+		t.Errorf("nextSplit(gleich) = %s", got)
+	}
+	if got := nextSplit(splitAmounts); got != splitEqual {
+		t.Errorf("nextSplit(betrag) = %s", got)
+	}
+}
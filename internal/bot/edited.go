@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/metrics"
+)
+
+// handleEditedMessage recomputes an order from the edited text of the message
+// it was written in. Edits of other messages, of orders changed in another way
+// since and of gyroskops past their deadline are ignored.
+func (b *Bot) handleEditedMessage(ctx context.Context, message *tgbotapi.Message) {
+	if message.From == nil || message.IsCommand() {
+		return
+	}
+
+	source := database.OrderMessage{ChatID: message.Chat.ID, MessageID: message.MessageID}
+	order, err := b.db.GetOrderByMessage(ctx, source, message.From.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		b.log.DebugContext(ctx, "Ignoring edit of a message without order")
+		return
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellung zur bearbeiteten Nachricht", "error", err)
+		return
+	}
+
+	gyroskop, exists := b.findActiveGyroskop(order.GyroskopID)
+	if !exists || time.Now().After(gyroskop.Deadline) {
+		b.log.DebugContext(ctx, "Ignoring edit of an order after the deadline", "gyroskop_id", order.GyroskopID)
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	text := strings.TrimSpace(strings.ToLower(message.Text))
+	quantities := map[string]int{}
+	if text != "0" {
+		quantities = b.parseOrderText(text, gyroskop.FoodOptions, b.chatSettings(ctx, gyroskop.ChatID).MaxQuantity)
+		if quantities == nil {
+			metrics.ParseFailures.Inc("order")
+			b.log.DebugContext(ctx, "Ignoring edit that is not an order")
+			return
+		}
+	}
+
+	err = b.placeMessageOrder(ctx, gyroskop, message, quantities, "edit")
+	switch {
+	case errors.Is(err, ErrGyroskopExpired):
+		return
+	case err != nil:
+		b.log.ErrorContext(ctx, "Fehler beim Ändern der Bestellung", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Ändern der Bestellung")
+		return
+	}
+
+	orderText := b.formatOrderQuantities(quantities, gyroskop.FoodOptions)
+	if message.Chat.IsPrivate() {
+		if orderText == "" {
+			b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("❌ Bestellung bei %s storniert", gyroskop.Name))
+			return
+		}
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("✏️ %s: %s", gyroskop.Name, orderText))
+		return
+	}
+
+	if !b.chatSettings(ctx, gyroskop.ChatID).Confirmations || gyroskop.Hidden {
+		return
+	}
+	if orderText == "" {
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("❌ %s hat die Bestellung storniert", b.getUserName(message.From)))
+		return
+	}
+	b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("✏️ %s: %s", b.getUserName(message.From), orderText))
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/export"
+)
+
+// exportRequest describes what /export should export
+type exportRequest struct {
+	Format export.Format
+	Ranged bool      // Export all gyroskops of the chat in [From, To)
+	From   time.Time // Inclusive start of the date range
+	To     time.Time // Exclusive end of the date range
+}
+
+// parseExportArgs parses the arguments of /export
+// Format: [csv|json|md|txt] [von] [bis]
+// Examples:
+//
+//	/export -> CSV of the replied-to or latest gyroskop
+//	/export md -> Markdown of the replied-to or latest gyroskop
+//	/export csv 01.03.2024 31.03.2024 -> CSV of all gyroskops in March 2024
+//	/export json 2024-03-01 -> JSON of all gyroskops since 1 March 2024
+func parseExportArgs(args string, now time.Time, loc *time.Location) (exportRequest, error) {
+	req := exportRequest{Format: export.FormatCSV}
+
+	var dates []time.Time
+	for _, field := range strings.Fields(args) {
+		if format, err := export.ParseFormat(field); err == nil {
+			req.Format = format
+			continue
+		}
+
+		date, err := parseExportDate(field, loc)
+		if err != nil {
+			return exportRequest{}, err
+		}
+		dates = append(dates, date)
+	}
+
+	switch len(dates) {
+	case 0:
+		return req, nil
+	case 1:
+		req.From = dates[0]
+		req.To = now
+	case 2:
+		req.From = dates[0]
+		req.To = dates[1].AddDate(0, 0, 1) // End date is inclusive
+	default:
+		return exportRequest{}, fmt.Errorf("too many dates")
+	}
+
+	if !req.To.After(req.From) {
+		return exportRequest{}, fmt.Errorf("end date before start date")
+	}
+
+	req.Ranged = true
+	return req, nil
+}
+
+// parseExportDate parses a date in German (02.01.2006) or ISO (2006-01-02) format
+func parseExportDate(input string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"02.01.2006", "2.1.2006", "2006-01-02"} {
+		if date, err := time.ParseInLocation(layout, input, loc); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", input)
+}
+
+// handleExport uploads the orders of a gyroskop or a date range as a document
+func (b *Bot) handleExport(ctx context.Context, message *tgbotapi.Message, args string) {
+	loc := b.defaults.Location
+
+	req, err := parseExportArgs(args, time.Now().In(loc), loc)
+	if err != nil {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /export [csv|json|md|txt] [von] [bis] (Datum als TT.MM.JJJJ)")
+		return
+	}
+
+	var gyroskops []database.Gyroskop
+	var fileName string
+
+	switch {
+	case req.Ranged:
+		gyroskops, err = b.db.GetGyroskopsByChat(ctx, message.Chat.ID, req.From, req.To)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Laden der Gyroskops", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Gyroskops")
+			return
+		}
+		fileName = fmt.Sprintf("gyroskops-%s-%s", req.From.Format("2006-01-02"), req.To.AddDate(0, 0, -1).Format("2006-01-02"))
+	case message.ReplyToMessage != nil:
+		gyroskop, err := b.db.GetGyroskopByMessageID(ctx, message.Chat.ID, message.ReplyToMessage.MessageID)
+		if err != nil {
+			b.sendMessage(ctx, message.Chat.ID, "❌ Das ist keine gültige Gyroskop-Nachricht")
+			return
+		}
+		gyroskops = []database.Gyroskop{*gyroskop}
+		fileName = fmt.Sprintf("gyroskop-%d", gyroskop.ID)
+	default:
+		gyroskop, err := b.db.GetLatestGyroskop(ctx, message.Chat.ID)
+		if err != nil {
+			b.sendMessage(ctx, message.Chat.ID, "❌ Noch kein Gyroskop in dieser Gruppe")
+			return
+		}
+		gyroskops = []database.Gyroskop{*gyroskop}
+		fileName = fmt.Sprintf("gyroskop-%d", gyroskop.ID)
+	}
+
+	if len(gyroskops) == 0 {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Keine Gyroskops in diesem Zeitraum")
+		return
+	}
+
+	// Hidden orders are only exported privately to those who may edit the gyroskop
+	visible := gyroskops[:0]
+	private := false
+	skipped := 0
+	for i := range gyroskops {
+		if gyroskops[i].Hidden {
+			if !b.roleOf(ctx, &gyroskops[i], message.From.ID).can(actionEdit) {
+				skipped++
+				continue
+			}
+			private = true
+		}
+		visible = append(visible, gyroskops[i])
+	}
+	gyroskops = visible
+	if len(gyroskops) == 0 {
+		b.sendMessage(ctx, message.Chat.ID, "🙈 Die Bestellungen sind verdeckt, nur Ersteller, Mitorganisatoren und Admins können sie exportieren")
+		return
+	}
+
+	entries := make([]export.Entry, 0, len(gyroskops))
+	for _, gyroskop := range gyroskops {
+		orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Bestellungen")
+			return
+		}
+		entries = append(entries, export.Entry{Gyroskop: gyroskop, Orders: orders, Contact: b.restaurantContact(ctx, &gyroskop)})
+	}
+
+	data, err := export.Render(req.Format, entries, loc)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Exportieren", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Exportieren")
+		return
+	}
+
+	caption := skippedCaption(skipped)
+	if !private {
+		if err := b.sendDocument(ctx, message.Chat.ID, fileName+"."+req.Format.Extension(), caption, data); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Senden des Dokuments", "error", err)
+		}
+		return
+	}
+
+	if err := b.sendDocument(ctx, message.From.ID, fileName+"."+req.Format.Extension(), caption, data); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden des Dokuments", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Ich konnte dir den Export nicht privat schicken. Starte zuerst einen privaten Chat mit mir.")
+		return
+	}
+	if !message.Chat.IsPrivate() {
+		b.sendMessage(ctx, message.Chat.ID, "🙈 Der Export enthält verdeckte Bestellungen und wurde dir privat geschickt")
+	}
+}
+
+// skippedCaption tells that hidden gyroskops are missing from an export, "" if none are
+func skippedCaption(skipped int) string {
+	switch skipped {
+	case 0:
+		return ""
+	case 1:
+		return "🙈 1 verdecktes Gyroskop fehlt, nur Ersteller, Mitorganisatoren und Admins können es exportieren"
+	default:
+		return fmt.Sprintf("🙈 %d verdeckte Gyroskops fehlen, nur Ersteller, Mitorganisatoren und Admins können sie exportieren", skipped)
+	}
+}
+
+// sendDocument sendet eine Datei als Dokument mit optionaler Beschriftung
+func (b *Bot) sendDocument(ctx context.Context, chatID int64, fileName, caption string, data []byte) error {
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: data})
+	doc.Caption = caption
+	_, err := b.send(ctx, chatID, doc)
+	return err
+}
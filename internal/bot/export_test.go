@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/export"
+)
+
+func TestParseExportArgs(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name       string
+		args       string
+		wantFormat export.Format
+		wantRanged bool
+		wantFrom   time.Time
+		wantTo     time.Time
+		wantErr    bool
+	}{
+		{
+			name:       "empty args - csv of single gyroskop",
+			args:       "",
+			wantFormat: export.FormatCSV,
+		},
+		{
+			name:       "markdown",
+			args:       "md",
+			wantFormat: export.FormatMarkdown,
+		},
+		{
+			name:       "date range is inclusive",
+			args:       "json 01.03.2024 15.03.2024",
+			wantFormat: export.FormatJSON,
+			wantRanged: true,
+			wantFrom:   time.Date(2024, 3, 1, 0, 0, 0, 0, berlin),
+			wantTo:     time.Date(2024, 3, 16, 0, 0, 0, 0, berlin),
+		},
+		{
+			name:       "single ISO date until now",
+			args:       "2024-03-01",
+			wantFormat: export.FormatCSV,
+			wantRanged: true,
+			wantFrom:   time.Date(2024, 3, 1, 0, 0, 0, 0, berlin),
+			wantTo:     now,
+		},
+		{
+			name:    "end before start",
+			args:    "csv 15.03.2024 01.03.2024",
+			wantErr: true,
+		},
+		{
+			name:    "invalid argument",
+			args:    "pdf",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := parseExportArgs(tt.args, now, berlin)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExportArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if req.Format != tt.wantFormat || req.Ranged != tt.wantRanged {
+				t.Errorf("parseExportArgs() = %+v, want format %v ranged %v", req, tt.wantFormat, tt.wantRanged)
+			}
+			if !req.From.Equal(tt.wantFrom) || !req.To.Equal(tt.wantTo) {
+				t.Errorf("parseExportArgs() range = %v - %v, want %v - %v", req.From, req.To, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestSkippedCaption(t *testing.T) {
+	if got := skippedCaption(0); got != "" {
+		t.Errorf("skippedCaption(0) = %q, want none", got)
+	}
+	if got := skippedCaption(1); !strings.Contains(got, "1 verdecktes Gyroskop fehlt") {
+		t.Errorf("skippedCaption(1) = %q", got)
+	}
+	if got := skippedCaption(3); !strings.Contains(got, "3 verdeckte Gyroskops fehlen") {
+		t.Errorf("skippedCaption(3) = %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+// Hidden gyroskops
+//
+// The orders of a hidden gyroskop are not shown in the group: the gyroskop
+// message, /status and the final summary only show the totals and the number
+// of participants. Orders are placed with the buttons or in the private chat
+// and the full list is sent privately to the creator when the gyroskop is closed.
+
+// Keywords in the arguments of /gyroskop that hide or show the orders
+var (
+	hideKeywords = []string{"geheim", "verdeckt"}
+	showKeywords = []string{"sichtbar"}
+)
+
+// parseGyroskopFlags removes the hide and show keywords from the arguments of
+// /gyroskop. hidden is nil if neither was given.
+func parseGyroskopFlags(args string) (rest string, hidden *bool) {
+	parts := strings.Split(args, ",")
+	kept := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		switch {
+		case containsFold(hideKeywords, trimmed):
+			value := true
+			hidden = &value
+		case containsFold(showKeywords, trimmed):
+			value := false
+			hidden = &value
+		default:
+			kept = append(kept, trimmed)
+		}
+	}
+	if hidden == nil {
+		return args, nil
+	}
+	return strings.Join(kept, ", "), hidden
+}
+
+// containsFold checks whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// setGyroskopHidden hides or shows the orders of a gyroskop
+func (b *Bot) setGyroskopHidden(ctx context.Context, gyroskop *database.Gyroskop, hidden bool) error {
+	if err := b.db.SetGyroskopHidden(ctx, gyroskop.ID, hidden); err != nil {
+		return err
+	}
+	gyroskop.Hidden = hidden
+	return nil
+}
+
+// orderInstructions explains how to order below the gyroskop message
+func (b *Bot) orderInstructions(gyroskop *database.Gyroskop) string {
+	if gyroskop.Hidden {
+		return "🙈 Die Bestellungen sind verdeckt, nur der Ersteller sieht, wer was bestellt hat. " +
+			"Zum Bestellen nutzt die Buttons unten oder „💬 Privat bestellen“."
+	}
+
+	// Generate example orders
+	var examples []string
+	for _, option := range gyroskop.FoodOptions {
+		examples = append(examples, fmt.Sprintf("'2 %s'", strings.ToLower(option)))
+	}
+	return fmt.Sprintf("Zum Bestellen schreibt %s oder nutzt die Buttons unten.", strings.Join(examples, ", "))
+}
+
+// sendCreatorSummary sends the full summary of a hidden gyroskop privately to
+// its creator and tells the group if that is not possible
+func (b *Bot) sendCreatorSummary(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order) {
+	summary := withTitle(b.summaryMessage(ctx, gyroskop, orders, true), "🙈 Verdeckte Bestellungen aus "+b.chatTitle(ctx, gyroskop.ChatID))
+	msg := tgbotapi.NewMessage(gyroskop.CreatedBy, summary)
+	msg.ParseMode = messageFormat.ParseMode()
+	if _, err := b.send(ctx, gyroskop.CreatedBy, msg); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden der verdeckten Bestellungen", "error", err)
+		b.sendMessage(ctx, gyroskop.ChatID, "⚠️ Ich konnte die Bestellungen nicht privat an den Ersteller schicken. Starte einen privaten Chat mit mir und hole sie mit /export (als Antwort auf die Gyroskop-Nachricht).")
+	}
+}
+
+// answerCallbackAlert answers a callback query with an alert the user has to dismiss
+func (b *Bot) answerCallbackAlert(ctx context.Context, callbackQueryID, text string) {
+	callback := tgbotapi.NewCallbackWithAlert(callbackQueryID, text)
+	_, err := b.request(ctx, 0, callback)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Antworten auf Callback Query", "error", err)
+	}
+}
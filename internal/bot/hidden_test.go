@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestParseGyroskopFlags(t *testing.T) {
+	hidden, shown := true, false
+
+	tests := []struct {
+		args       string
+		wantArgs   string
+		wantHidden *bool
+	}{
+		{"", "", nil},
+		{"30min, Pizza, Salami", "30min, Pizza, Salami", nil},
+		{"geheim", "", &hidden},
+		{"30min, Geheim, Pizza, Salami", "30min, Pizza, Salami", &hidden},
+		{"verdeckt, Döner, Fleisch", "Döner, Fleisch", &hidden},
+		{"sichtbar", "", &shown},
+		// Only whole parts are keywords
+		{"Geheimtipp, Fleisch", "Geheimtipp, Fleisch", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			args, hidden := parseGyroskopFlags(tt.args)
+			if args != tt.wantArgs {
+				t.Errorf("parseGyroskopFlags() args = %q, want %q", args, tt.wantArgs)
+			}
+			if (hidden == nil) != (tt.wantHidden == nil) || (hidden != nil && *hidden != *tt.wantHidden) {
+				t.Errorf("parseGyroskopFlags() hidden = %v, want %v", hidden, tt.wantHidden)
+			}
+		})
+	}
+}
+
+func TestHiddenFormatting(t *testing.T) {
+	b := newTestBot()
+	gyroskop := &database.Gyroskop{
+		Name:        "Gyros",
+		FoodOptions: []string{"Fleisch", "Vegetarisch"},
+		Deadline:    time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+		Hidden:      true,
+	}
+	orders := []database.Order{
+		{UserID: 1, FirstName: "Anna", Quantities: map[string]int{"Fleisch": 2}},
+		{UserID: 2, FirstName: "Ben", Quantities: map[string]int{"Fleisch": 1, "Vegetarisch": 1}},
+	}
+
+	// Without database the chat uses the default templates
+	ctx := context.Background()
+	b.settings.set(gyroskop.ChatID, b.defaults.settings(nil))
+
+	for name, text := range map[string]string{
+		"status":   b.statusMessage(ctx, gyroskop, orders),
+		"summary":  b.summaryMessage(ctx, gyroskop, orders, false),
+		"live":     b.liveMessage(ctx, gyroskop, orders, "Carla", false),
+		"messages": b.orderInstructions(gyroskop),
+	} {
+		if strings.Contains(text, "Anna") || strings.Contains(text, "Ben") {
+			t.Errorf("%s reveals who ordered: %q", name, text)
+		}
+	}
+
+	summary := b.summaryMessage(ctx, gyroskop, orders, false)
+	for _, want := range []string{"2 Teilnehmer", "<b>Gesamt: 4</b> (3 Fleisch, 1 Vegetarisch)", "12:00 Uhr"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summaryMessage() = %q, missing %q", summary, want)
+		}
+	}
+
+	// The full summary for the creator and visible gyroskops still lists everyone
+	if full := b.summaryMessage(ctx, gyroskop, orders, true); !strings.Contains(full, "Anna: 2 Fleisch") {
+		t.Errorf("summaryMessage() for the creator = %q, missing Anna's order", full)
+	}
+	gyroskop.Hidden = false
+	if summary := b.summaryMessage(ctx, gyroskop, orders, false); !strings.Contains(summary, "Ben: 1 Fleisch, 1 Vegetarisch") {
+		t.Errorf("summaryMessage() of a visible gyroskop = %q, missing Ben's order", summary)
+	}
+	if instructions := b.orderInstructions(gyroskop); !strings.Contains(instructions, "'2 fleisch'") {
+		t.Errorf("orderInstructions() of a visible gyroskop = %q, missing examples", instructions)
+	}
+}
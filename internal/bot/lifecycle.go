@@ -0,0 +1,375 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Lifecycle after closing
+//
+// The final summary of a gyroskop has buttons for the creator, co-organisers
+// and admins to advance its state: closed → ordered (with optional ETA) →
+// delivered → archived. When the food arrives everyone who ordered is mentioned.
+const lifecycleCallbackPrefix = "l:" // l:<gyroskopID>:<state>[:<eta minutes>], l:<gyroskopID>:eta|back|zettel
+
+// etaChoices are the ETAs in minutes offered when marking a gyroskop as ordered
+var etaChoices = []int{15, 20, 30, 45, 60, 90}
+
+// stateLabels are the German names of the states
+var stateLabels = map[database.State]string{
+	database.StateOpen:      "offen",
+	database.StateClosed:    "geschlossen",
+	database.StateOrdered:   "bestellt",
+	database.StateDelivered: "angekommen",
+	database.StateArchived:  "archiviert",
+}
+
+// lifecycleKeyboard returns the buttons of the summary message for the state
+// of a gyroskop, or nil if there is nothing more to do
+func lifecycleKeyboard(gyroskop *database.Gyroskop, etaMenu bool) *tgbotapi.InlineKeyboardMarkup {
+	data := func(action string) string {
+		return fmt.Sprintf("%s%d:%s", lifecycleCallbackPrefix, gyroskop.ID, action)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	switch {
+	case etaMenu:
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, minutes := range etaChoices {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d min", minutes),
+				data(fmt.Sprintf("%s:%d", database.StateOrdered, minutes))))
+		}
+		rows = append(rows, buttons[:3], buttons[3:], tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Ohne ETA", data(string(database.StateOrdered)+":0")),
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Zurück", data("back")),
+		))
+	case gyroskop.State == database.StateClosed:
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📞 Bestellt", data("eta")),
+			tgbotapi.NewInlineKeyboardButtonData("🗄 Archivieren", data(string(database.StateArchived))),
+		))
+	case gyroskop.State == database.StateOrdered:
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🍽 Angekommen", data(string(database.StateDelivered))),
+			tgbotapi.NewInlineKeyboardButtonData("⏱ ETA ändern", data("eta")),
+		))
+	case gyroskop.State == database.StateDelivered:
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗄 Archivieren", data(string(database.StateArchived))),
+		))
+	default:
+		return nil
+	}
+	if !etaMenu {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧾 Bestellzettel", data(orderSheetAction)),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// stateLine formats the state of a closed gyroskop for the summary message
+func (b *Bot) stateLine(gyroskop *database.Gyroskop) render.Line {
+	switch gyroskop.State {
+	case database.StateOpen:
+		return render.Line{render.Plain("🔄 "), render.Bold("Wiedereröffnet")}
+	case database.StateOrdered:
+		if gyroskop.ETA != nil {
+			return render.Line{render.Plain("📞 "), render.Bold("Bestellt"),
+				render.Plain(fmt.Sprintf(" – voraussichtlich da um %s Uhr", gyroskop.ETA.In(b.defaults.Location).Format("15:04")))}
+		}
+		return render.Line{render.Plain("📞 "), render.Bold("Bestellt")}
+	case database.StateDelivered:
+		return render.Line{render.Plain("🍽 "), render.Bold("Angekommen")}
+	case database.StateArchived:
+		return render.Line{render.Plain("🗄 "), render.Bold("Archiviert")}
+	default:
+		return nil
+	}
+}
+
+// formatSummaryMessage formats the final summary with the restaurant, the costs and the current state
+func (b *Bot) formatSummaryMessage(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order) string {
+	text := withTitle(b.summaryMessage(ctx, gyroskop, orders, false), "🔒 Gyroskop beendet!")
+	if r := b.gyroskopRestaurant(ctx, gyroskop); r != nil {
+		text += "\n\n" + messageFormat.Line(restaurantContact(r))
+	}
+	if costs := b.summaryCosts(ctx, gyroskop, orders); costs != "" {
+		text += "\n\n" + costs
+	}
+	if state := b.stateLine(gyroskop); state != nil {
+		text += "\n\n" + messageFormat.Line(state)
+	}
+	return text
+}
+
+// sendSummaryMessage posts the final summary of a closed gyroskop with the lifecycle buttons
+func (b *Bot) sendSummaryMessage(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order) {
+	msg := tgbotapi.NewMessage(gyroskop.ChatID, b.formatSummaryMessage(ctx, gyroskop, orders))
+	msg.ParseMode = messageFormat.ParseMode()
+	if keyboard := lifecycleKeyboard(gyroskop, false); keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+
+	sent, err := b.send(ctx, gyroskop.ChatID, msg)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden der Nachricht", "error", err)
+		return
+	}
+	if err := b.db.UpdateGyroskopSummaryMessageID(ctx, gyroskop.ID, sent.MessageID); err != nil {
+		b.log.ErrorContext(ctx, "Error saving summary message ID", "message_id", sent.MessageID, "error", err)
+	}
+	gyroskop.SummaryMessageID = sent.MessageID
+}
+
+// messageLink returns the link to a message, or "" for chats without message
+// links. Only supergroups (IDs starting with -100) have them.
+func messageLink(chatID int64, messageID int) string {
+	id, isSupergroup := strings.CutPrefix(strconv.FormatInt(chatID, 10), "-100")
+	if !isSupergroup || messageID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/c/%s/%d", id, messageID)
+}
+
+// formatClosedGyroskopMessage formats the gyroskop message once it was closed at closedAt
+func (b *Bot) formatClosedGyroskopMessage(gyroskop *database.Gyroskop, creatorName string, closedAt time.Time) string {
+	deadline := gyroskop.Deadline.In(b.defaults.Location).Format("15:04")
+	lines := []render.Line{
+		{render.Plain("🔒 "), render.Bold(gyroskop.Name + " geschlossen")},
+		nil,
+		{render.Plain("👤 Erstellt von: " + creatorName)},
+	}
+	if closedAt.Before(gyroskop.Deadline) {
+		lines = append(lines, render.Line{render.Plain(fmt.Sprintf("⏰ Vorzeitig beendet um %s Uhr (Deadline %s Uhr)", closedAt.In(b.defaults.Location).Format("15:04"), deadline))})
+	} else {
+		lines = append(lines, render.Line{render.Plain(fmt.Sprintf("⏰ Deadline %s Uhr ist abgelaufen", deadline))})
+	}
+
+	if link := messageLink(gyroskop.ChatID, gyroskop.SummaryMessageID); link != "" {
+		lines = append(lines, nil, render.Line{render.Plain("📋 "), render.Link("Zur Übersicht", link)})
+	} else {
+		lines = append(lines, nil, render.Line{render.Plain("📋 Die Übersicht steht weiter unten.")})
+	}
+	return messageFormat.Lines(lines...)
+}
+
+// finaliseGyroskopMessage replaces the gyroskop message of a closed gyroskop
+// by its closed state without the order buttons
+func (b *Bot) finaliseGyroskopMessage(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order, closedAt time.Time) {
+	if gyroskop.MessageID == 0 {
+		return
+	}
+
+	chatID, messageID := gyroskop.ChatID, gyroskop.MessageID
+	text := b.formatClosedGyroskopMessage(gyroskop, b.creatorName(gyroskop, orders), closedAt)
+	b.outbox.Edit(ctx, chatID, messageID, func(ctx context.Context) (tgbotapi.Chattable, error) {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		edit.ParseMode = messageFormat.ParseMode()
+		edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		return edit, nil
+	})
+}
+
+// updateSummaryMessage shows the current state and buttons in the summary message
+func (b *Bot) updateSummaryMessage(ctx context.Context, gyroskop *database.Gyroskop, etaMenu bool) {
+	if gyroskop.SummaryMessageID == 0 {
+		return
+	}
+
+	chatID, messageID := gyroskop.ChatID, gyroskop.SummaryMessageID
+	b.outbox.Edit(ctx, chatID, messageID, func(ctx context.Context) (tgbotapi.Chattable, error) {
+		orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+		if err != nil {
+			return nil, fmt.Errorf("loading orders: %w", err)
+		}
+
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, b.formatSummaryMessage(ctx, gyroskop, orders))
+		edit.ParseMode = messageFormat.ParseMode()
+		if keyboard := lifecycleKeyboard(gyroskop, etaMenu); keyboard != nil {
+			edit.ReplyMarkup = keyboard
+		} else {
+			edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		}
+		return edit, nil
+	})
+}
+
+// handleLifecycleCallback handles the buttons of the summary message
+func (b *Bot) handleLifecycleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(query.Data, lifecycleCallbackPrefix), ":")
+	gyroskopID, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) < 2 {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Callback-Daten")
+		return
+	}
+
+	gyroskop, err := b.db.GetGyroskopByID(ctx, gyroskopID)
+	if err != nil || gyroskop.ChatID != query.Message.Chat.ID {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Gyroskop nicht gefunden")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	// Everyone may get the order sheet, only changing the state is restricted
+	if parts[1] == orderSheetAction {
+		b.handleOrderSheetCallback(ctx, query, gyroskop)
+		return
+	}
+
+	if !b.roleOf(ctx, gyroskop, query.From.ID).can(actionEdit) {
+		b.answerCallbackQuery(ctx, query.ID, fmt.Sprintf("⚠️ Nur %s können den Status ändern!", actionEdit.allowedRoles()))
+		return
+	}
+
+	switch action := parts[1]; action {
+	case "eta", "back":
+		b.answerCallbackQuery(ctx, query.ID, "")
+		b.updateSummaryMessage(ctx, gyroskop, action == "eta")
+	case string(database.StateOrdered), string(database.StateDelivered), string(database.StateArchived):
+		var eta *time.Time
+		if len(parts) == 3 {
+			minutes, err := strconv.Atoi(parts[2])
+			if err != nil || minutes < 0 {
+				b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige ETA")
+				return
+			}
+			if minutes > 0 {
+				at := time.Now().Add(time.Duration(minutes) * time.Minute)
+				eta = &at
+			}
+		}
+
+		text, _ := b.advanceGyroskop(ctx, gyroskop, database.State(action), eta, query.From)
+		b.answerCallbackQuery(ctx, query.ID, text)
+	default:
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Callback-Daten")
+	}
+}
+
+// advanceGyroskop changes the state of a closed gyroskop, updates the summary
+// message and notifies the group. It returns a short confirmation or, if the
+// change failed, the reason to show the user.
+func (b *Bot) advanceGyroskop(ctx context.Context, gyroskop *database.Gyroskop, to database.State, eta *time.Time, user *tgbotapi.User) (string, error) {
+	from := gyroskop.State
+	changed, err := b.db.TransitionGyroskop(ctx, gyroskop.ID, to, eta)
+	if errors.Is(err, database.ErrInvalidTransition) {
+		return fmt.Sprintf("⚠️ Das Gyroskop ist %s, „%s“ ist jetzt nicht möglich", stateLabels[from], stateLabels[to]), err
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Ändern des Status", "error", err)
+		return "❌ Fehler beim Ändern des Status", err
+	}
+	b.log.InfoContext(ctx, "Gyroskop state changed", "from", from, "to", to, "eta", eta)
+
+	b.updateSummaryMessage(ctx, changed, false)
+
+	switch {
+	case to == database.StateOrdered && from == database.StateOrdered:
+		b.sendMessage(ctx, changed.ChatID, fmt.Sprintf("⏱ Neue ETA für %s: %s", changed.Name, b.formatETA(changed)))
+	case to == database.StateOrdered:
+		lines := []render.Line{{render.Plain("📞 "), render.Bold(changed.Name + " wurde bestellt!")}}
+		if changed.ETA != nil {
+			lines = append(lines, render.Line{render.Plain(fmt.Sprintf("⏰ Voraussichtlich da um %s Uhr", b.formatETA(changed)))})
+		}
+		b.sendFormatted(ctx, changed.ChatID, messageFormat.Lines(lines...))
+	case to == database.StateDelivered:
+		b.notifyDelivered(ctx, changed, user)
+	}
+
+	return fmt.Sprintf("✅ Status: %s", stateLabels[to]), nil
+}
+
+// formatETA formats the ETA of a gyroskop or "unbekannt"
+func (b *Bot) formatETA(gyroskop *database.Gyroskop) string {
+	if gyroskop.ETA == nil {
+		return "unbekannt"
+	}
+	return gyroskop.ETA.In(b.defaults.Location).Format("15:04") + " Uhr"
+}
+
+// notifyDelivered tells the group the food arrived, mentioning everyone who ordered
+func (b *Bot) notifyDelivered(ctx context.Context, gyroskop *database.Gyroskop, user *tgbotapi.User) {
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+		return
+	}
+
+	b.sendFormatted(ctx, gyroskop.ChatID, b.formatDelivered(gyroskop, orders, b.getUserName(user)))
+}
+
+// formatDelivered formats the arrival notification with a mention of everyone who ordered
+func (b *Bot) formatDelivered(gyroskop *database.Gyroskop, orders []database.Order, by string) string {
+	lines := []render.Line{{render.Plain("🍽 "), render.Bold(gyroskop.Name + " ist da!"), render.Plain(" (gemeldet von " + by + ")")}}
+
+	if len(orders) > 0 {
+		var mentions render.Line
+		for i, order := range orders {
+			if i > 0 {
+				mentions = append(mentions, render.Plain(", "))
+			}
+			mentions = append(mentions, render.Link(b.formatUserName(&order), fmt.Sprintf("tg://user?id=%d", order.UserID)))
+		}
+		lines = append(lines, nil, mentions)
+	}
+	return messageFormat.Lines(lines...)
+}
+
+// handleOrdered marks the replied-to or latest gyroskop of the chat as ordered
+// Format: /bestellt [ETA], e.g. /bestellt 30min or /bestellt 12:45
+func (b *Bot) handleOrdered(ctx context.Context, message *tgbotapi.Message, args string) {
+	var eta *time.Time
+	if args = strings.TrimSpace(args); args != "" {
+		at, err := b.parseDeadline(args, 0)
+		if err != nil {
+			b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /bestellt [ETA], z.B. /bestellt 30min oder /bestellt 12:45")
+			return
+		}
+		eta = &at
+	}
+
+	b.handleAdvance(ctx, message, database.StateOrdered, eta)
+}
+
+// handleDelivered marks the replied-to or latest gyroskop of the chat as delivered
+func (b *Bot) handleDelivered(ctx context.Context, message *tgbotapi.Message) {
+	b.handleAdvance(ctx, message, database.StateDelivered, nil)
+}
+
+// targetGyroskop returns the replied-to gyroskop or the latest gyroskop of the chat
+func (b *Bot) targetGyroskop(ctx context.Context, message *tgbotapi.Message) (*database.Gyroskop, error) {
+	if message.ReplyToMessage != nil {
+		return b.db.GetGyroskopByMessageID(ctx, message.Chat.ID, message.ReplyToMessage.MessageID)
+	}
+	return b.db.GetLatestGyroskop(ctx, message.Chat.ID)
+}
+
+// handleAdvance changes the state of the replied-to or latest gyroskop of the chat
+func (b *Bot) handleAdvance(ctx context.Context, message *tgbotapi.Message, to database.State, eta *time.Time) {
+	gyroskop, err := b.targetGyroskop(ctx, message)
+	if err != nil {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein Gyroskop gefunden")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if !b.authorize(ctx, message, gyroskop, actionEdit, "den Status ändern") {
+		return
+	}
+
+	// The confirmation is the notification sent to the group, only errors are reported here
+	if text, err := b.advanceGyroskop(ctx, gyroskop, to, eta, message.From); err != nil {
+		b.sendMessage(ctx, message.Chat.ID, text)
+	}
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestLifecycleKeyboard(t *testing.T) {
+	tests := []struct {
+		state   database.State
+		etaMenu bool
+		want    []string // Callback data of all buttons
+	}{
+		{database.StateOpen, false, nil},
+		{database.StateClosed, false, []string{"l:7:eta", "l:7:archived", "l:7:zettel"}},
+		{database.StateOrdered, false, []string{"l:7:delivered", "l:7:eta", "l:7:zettel"}},
+		{database.StateDelivered, false, []string{"l:7:archived", "l:7:zettel"}},
+		{database.StateArchived, false, nil},
+		{database.StateClosed, true, []string{
+			"l:7:ordered:15", "l:7:ordered:20", "l:7:ordered:30",
+			"l:7:ordered:45", "l:7:ordered:60", "l:7:ordered:90",
+			"l:7:ordered:0", "l:7:back",
+		}},
+	}
+
+	for _, tt := range tests {
+		keyboard := lifecycleKeyboard(&database.Gyroskop{ID: 7, State: tt.state}, tt.etaMenu)
+		if tt.want == nil {
+			if keyboard != nil {
+				t.Errorf("lifecycleKeyboard(%s) = %v, want nil", tt.state, keyboard)
+			}
+			continue
+		}
+		if keyboard == nil {
+			t.Errorf("lifecycleKeyboard(%s) = nil, want %v", tt.state, tt.want)
+			continue
+		}
+
+		var got []string
+		for _, row := range keyboard.InlineKeyboard {
+			for _, button := range row {
+				got = append(got, *button.CallbackData)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("lifecycleKeyboard(%s, %v) = %v, want %v", tt.state, tt.etaMenu, got, tt.want)
+		}
+	}
+}
+
+func TestStateLine(t *testing.T) {
+	b := newTestBot()
+	eta := time.Date(2024, 3, 1, 11, 45, 0, 0, time.UTC)
+
+	tests := []struct {
+		gyroskop database.Gyroskop
+		want     string
+	}{
+		{database.Gyroskop{State: database.StateOpen}, "<b>Wiedereröffnet</b>"},
+		{database.Gyroskop{State: database.StateClosed}, ""},
+		{database.Gyroskop{State: database.StateOrdered}, "<b>Bestellt</b>"},
+		{database.Gyroskop{State: database.StateOrdered, ETA: &eta}, "voraussichtlich da um 12:45 Uhr"},
+		{database.Gyroskop{State: database.StateDelivered}, "<b>Angekommen</b>"},
+		{database.Gyroskop{State: database.StateArchived}, "<b>Archiviert</b>"},
+	}
+
+	for _, tt := range tests {
+		got := messageFormat.Line(b.stateLine(&tt.gyroskop))
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("stateLine(%s) = %q, want it to contain %q", tt.gyroskop.State, got, tt.want)
+		}
+	}
+}
+
+func TestMessageLink(t *testing.T) {
+	tests := []struct {
+		chatID    int64
+		messageID int
+		want      string
+	}{
+		{-1001234567890, 42, "https://t.me/c/1234567890/42"},
+		{-1001234567890, 0, ""},
+		{-123456, 42, ""}, // Basic groups have no message links
+	}
+
+	for _, tt := range tests {
+		if got := messageLink(tt.chatID, tt.messageID); got != tt.want {
+			t.Errorf("messageLink(%d, %d) = %q, want %q", tt.chatID, tt.messageID, got, tt.want)
+		}
+	}
+}
+
+func TestFormatClosedGyroskopMessage(t *testing.T) {
+	b := newTestBot()
+	deadline := time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC)
+	gyroskop := &database.Gyroskop{ChatID: -1001234567890, Name: "Pizza", Deadline: deadline, SummaryMessageID: 42}
+
+	got := b.formatClosedGyroskopMessage(gyroskop, "Anna", deadline.Add(time.Minute))
+	for _, want := range []string{"<b>Pizza geschlossen</b>", "Erstellt von: Anna", "Deadline 12:30 Uhr ist abgelaufen", `<a href="https://t.me/c/1234567890/42">Zur Übersicht</a>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatClosedGyroskopMessage() after the deadline = %q, want it to contain %q", got, want)
+		}
+	}
+
+	gyroskop.ChatID = -123456
+	got = b.formatClosedGyroskopMessage(gyroskop, "Anna", deadline.Add(-10*time.Minute))
+	for _, want := range []string{"Vorzeitig beendet um 12:20 Uhr (Deadline 12:30 Uhr)", "Die Übersicht steht weiter unten."} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatClosedGyroskopMessage() before the deadline = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestFormatDelivered(t *testing.T) {
+	b := newTestBot()
+	gyroskop := &database.Gyroskop{Name: "Pizza"}
+	orders := []database.Order{
+		{UserID: 1, FirstName: "Anna <3"},
+		{UserID: 2, Username: "ben_k"},
+	}
+
+	text := b.formatDelivered(gyroskop, orders, "Carla")
+	for _, want := range []string{"<b>Pizza ist da!</b>", "Carla", `<a href="tg://user?id=1">Anna &lt;3</a>`, `<a href="tg://user?id=2">@ben_k</a>`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatDelivered() = %q, missing %q", text, want)
+		}
+	}
+
+	if text := b.formatDelivered(gyroskop, nil, "Carla"); strings.Contains(text, "tg://") {
+		t.Errorf("formatDelivered() without orders = %q, should not mention anyone", text)
+	}
+}
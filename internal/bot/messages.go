@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// messageFormat is the format of the messages listing orders. Those contain
+// names chosen by users, which the renderer escapes.
+const messageFormat = render.HTML
+
+// orderItems returns the ordered quantities in the order of the food options
+func orderItems(quantities map[string]int, foodOptions []string) []render.Item {
+	var items []render.Item
+	for _, option := range foodOptions {
+		if qty := quantities[option]; qty > 0 {
+			items = append(items, render.Item{Option: option, Quantity: qty})
+		}
+	}
+	return items
+}
+
+// gyroskopView builds the data of the message templates. Hidden orders only
+// have the number of participants and the totals.
+func (b *Bot) gyroskopView(gyroskop *database.Gyroskop, orders []database.Order, hidden bool) render.View {
+	view := render.View{
+		Gyroskop:     render.Gyroskop{ID: gyroskop.ID, Name: gyroskop.Name},
+		Creator:      b.creatorName(gyroskop, orders),
+		Deadline:     gyroskop.Deadline.In(b.defaults.Location),
+		Hidden:       hidden,
+		Participants: len(orders),
+	}
+
+	totals := make(map[string]int)
+	for _, order := range orders {
+		if !hidden {
+			view.Orders = append(view.Orders, render.Person{
+				Name:  b.formatUserName(&order),
+				Items: orderItems(order.Quantities, gyroskop.FoodOptions),
+			})
+		}
+		for option, qty := range order.Quantities {
+			totals[option] += qty
+		}
+	}
+	view.Totals = orderItems(totals, gyroskop.FoodOptions)
+	for _, item := range view.Totals {
+		view.Total += item.Quantity
+	}
+	return view
+}
+
+// renderMessage renders a message with the template of the chat. If that
+// fails the default template is used, so that the message still arrives.
+func (b *Bot) renderMessage(ctx context.Context, chatID int64, name string, view render.View) string {
+	if tmpl, ok := b.chatSettings(ctx, chatID).Templates[name]; ok {
+		text, err := tmpl.Execute(view)
+		if err == nil && text != "" {
+			return text
+		}
+		b.log.WarnContext(ctx, "Fehler beim Anwenden der Vorlage", "template", name, "error", err)
+	}
+
+	text, err := render.DefaultTemplate(name).Execute(view)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Anwenden der Standardvorlage", "template", name, "error", err)
+	}
+	return text
+}
+
+// liveMessage renders the gyroskop message with the current orders
+func (b *Bot) liveMessage(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order, creatorName string, reopened bool) string {
+	view := b.gyroskopView(gyroskop, orders, gyroskop.Hidden)
+	view.Creator = creatorName
+	view.Gyroskop.Reopened = reopened
+	view.Instructions = b.orderInstructions(gyroskop)
+	return b.renderMessage(ctx, gyroskop.ChatID, render.TemplateLive, view)
+}
+
+// statusMessage renders the current status shown by /status
+func (b *Bot) statusMessage(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order) string {
+	return b.renderMessage(ctx, gyroskop.ChatID, render.TemplateStatus, b.gyroskopView(gyroskop, orders, gyroskop.Hidden))
+}
+
+// summaryMessage renders the final summary of a gyroskop. Hidden orders are
+// only listed if reveal is set, i.e. for the creator.
+func (b *Bot) summaryMessage(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order, reveal bool) string {
+	return b.renderMessage(ctx, gyroskop.ChatID, render.TemplateSummary, b.gyroskopView(gyroskop, orders, gyroskop.Hidden && !reveal))
+}
+
+// withTitle puts a bold title above a rendered message
+func withTitle(text, title string) string {
+	return messageFormat.Line(render.Line{render.Bold(title)}) + "\n\n" + text
+}
+
+// sendFormatted sends a message rendered in messageFormat
+func (b *Bot) sendFormatted(ctx context.Context, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = messageFormat.ParseMode()
+	if _, err := b.send(ctx, chatID, msg); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden der Nachricht", "error", err)
+	}
+}
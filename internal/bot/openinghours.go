@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Opening hours of restaurants
+//
+// Format: comma-separated rules of days and one or more time ranges, e.g.
+// "Mo-Fr 11:30-14:30 17:30-22:00, Sa-So 17:00-01:00". Days are Mo, Di, Mi,
+// Do, Fr, Sa, So, a range of them (Fr-Mo wraps around the week) or täglich.
+// A range ending at or before its start closes after midnight.
+
+// weekdays are the German day abbreviations, starting on Monday
+var weekdays = []struct {
+	name string
+	day  time.Weekday
+}{
+	{"Mo", time.Monday},
+	{"Di", time.Tuesday},
+	{"Mi", time.Wednesday},
+	{"Do", time.Thursday},
+	{"Fr", time.Friday},
+	{"Sa", time.Saturday},
+	{"So", time.Sunday},
+}
+
+var errInvalidOpeningHours = errors.New("invalid opening hours")
+
+// openingRule are the opening times on some days of the week
+type openingRule struct {
+	label  string // Days as entered, normalised, e.g. "Mo-Fr"
+	days   [7]bool
+	ranges [][2]int // Minutes since midnight; an end at or before the start is on the next day
+}
+
+// openingHours are the opening times of a restaurant
+type openingHours []openingRule
+
+// parseOpeningHours parses opening hours, see the format above
+func parseOpeningHours(input string) (openingHours, error) {
+	var hours openingHours
+	for _, part := range strings.Split(input, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, errInvalidOpeningHours
+		}
+
+		rule, err := parseOpeningDays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields[1:] {
+			from, to, ok := strings.Cut(field, "-")
+			if !ok {
+				return nil, errInvalidOpeningHours
+			}
+			start, err := parseClock(from)
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseClock(to)
+			if err != nil {
+				return nil, err
+			}
+			if start == end || start == 24*60 {
+				return nil, errInvalidOpeningHours
+			}
+			rule.ranges = append(rule.ranges, [2]int{start, end % (24 * 60)})
+		}
+		hours = append(hours, rule)
+	}
+
+	if len(hours) == 0 {
+		return nil, errInvalidOpeningHours
+	}
+	return hours, nil
+}
+
+// parseOpeningDays parses a day, a range of days or "täglich"
+func parseOpeningDays(input string) (openingRule, error) {
+	var rule openingRule
+	if strings.EqualFold(input, "täglich") || strings.EqualFold(input, "tgl") {
+		rule.label = "täglich"
+		for i := range rule.days {
+			rule.days[i] = true
+		}
+		return rule, nil
+	}
+
+	from, to, isRange := strings.Cut(input, "-")
+	start := weekdayIndex(from)
+	end := start
+	if isRange {
+		end = weekdayIndex(to)
+	}
+	if start < 0 || end < 0 {
+		return rule, fmt.Errorf("%w: unknown day %s", errInvalidOpeningHours, input)
+	}
+
+	for i := start; ; i = (i + 1) % len(weekdays) {
+		rule.days[weekdays[i].day] = true
+		if i == end {
+			break
+		}
+	}
+	rule.label = weekdays[start].name
+	if end != start {
+		rule.label += "-" + weekdays[end].name
+	}
+	return rule, nil
+}
+
+// weekdayIndex returns the index of a German day abbreviation in weekdays or -1
+func weekdayIndex(name string) int {
+	for i, weekday := range weekdays {
+		if strings.EqualFold(name, weekday.name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseClock parses HH:MM (or H:MM) into minutes since midnight, allowing 24:00
+func parseClock(input string) (int, error) {
+	h, m, ok := strings.Cut(input, ":")
+	if !ok || len(h) == 0 || len(h) > 2 || len(m) != 2 {
+		return 0, errInvalidOpeningHours
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, errInvalidOpeningHours
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil || hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, errInvalidOpeningHours
+	}
+	return hour*60 + minute, nil
+}
+
+// isOpen checks whether the opening hours include the given time (in its location)
+func (h openingHours) isOpen(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, rule := range h {
+		for _, r := range rule.ranges {
+			start, end := r[0], r[1]
+			overnight := end <= start
+			if rule.days[today] && now >= start && (overnight || now < end) {
+				return true
+			}
+			if rule.days[yesterday] && overnight && now < end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String formats the opening hours in the format they are parsed from
+func (h openingHours) String() string {
+	rules := make([]string, 0, len(h))
+	for _, rule := range h {
+		parts := []string{rule.label}
+		for _, r := range rule.ranges {
+			parts = append(parts, fmt.Sprintf("%02d:%02d-%02d:%02d", r[0]/60, r[0]%60, r[1]/60, r[1]%60))
+		}
+		rules = append(rules, strings.Join(parts, " "))
+	}
+	return strings.Join(rules, ", ")
+}
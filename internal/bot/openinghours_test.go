@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOpeningHours(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Mo-Fr 11:30-14:30 17:30-22:00, Sa-So 17:00-01:00", "Mo-Fr 11:30-14:30 17:30-22:00, Sa-So 17:00-01:00", false},
+		{"täglich 9:00-24:00", "täglich 09:00-00:00", false},
+		{"fr-mo 18:00-02:00", "Fr-Mo 18:00-02:00", false},
+		{"", "", true},
+		{"Mo-Fr", "", true},
+		{"Xy 11:00-12:00", "", true},
+		{"Mo 11:00", "", true},
+		{"Mo 11:00-11:00", "", true},
+		{"Mo 25:00-26:00", "", true},
+		{"Mo 11:5-12:00", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			hours, err := parseOpeningHours(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOpeningHours() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && hours.String() != tt.want {
+				t.Errorf("parseOpeningHours().String() = %q, want %q", hours.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestOpeningHoursIsOpen(t *testing.T) {
+	hours, err := parseOpeningHours("Mo-Fr 11:30-14:30 17:30-22:00, Sa 17:00-01:00")
+	if err != nil {
+		t.Fatalf("parseOpeningHours() error = %v", err)
+	}
+
+	// 4 March 2024 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		time time.Time
+		want bool
+	}{
+		{"Monday before opening", at(4, 11, 29), false},
+		{"Monday lunch", at(4, 11, 30), true},
+		{"Monday afternoon", at(4, 15, 0), false},
+		{"Friday evening", at(8, 21, 59), true},
+		{"Friday closing", at(8, 22, 0), false},
+		{"Saturday late", at(9, 23, 30), true},
+		{"Sunday after midnight", at(10, 0, 30), true},
+		{"Sunday after closing", at(10, 1, 0), false},
+		{"Sunday evening", at(10, 18, 0), false},
+	}
+
+	for _, tt := range tests {
+		if got := hours.isOpen(tt.time); got != tt.want {
+			t.Errorf("isOpen(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
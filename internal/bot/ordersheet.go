@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/export"
+)
+
+// orderSheetAction is the lifecycle callback action of the Bestellzettel button
+const orderSheetAction = "zettel"
+
+// parseContact parses the optional restaurant of /bestellzettel
+// Format: [Restaurant][, Telefon]
+func parseContact(args string) export.Contact {
+	name, phone, _ := strings.Cut(args, ",")
+	return export.Contact{Name: strings.TrimSpace(name), Phone: strings.TrimSpace(phone)}
+}
+
+// orderSheetFileName is the name of the document the Bestellzettel is sent as
+const orderSheetFileName = "bestellzettel.txt"
+
+// orderSheet renders the Bestellzettel of a gyroskop
+func (b *Bot) orderSheet(ctx context.Context, gyroskop *database.Gyroskop, contact export.Contact) ([]byte, error) {
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []export.Entry{{Gyroskop: *gyroskop, Orders: orders, Contact: contact}}
+	return export.Sheet(entries, b.defaults.Location), nil
+}
+
+// sendOrderSheet sends the Bestellzettel as a text document, which unlike a
+// message has no length limit. Without
+// a contact the restaurant of the gyroskop is used. The sheet of a hidden
+// gyroskop lists who ordered what, so it is sent privately.
+func (b *Bot) sendOrderSheet(ctx context.Context, gyroskop *database.Gyroskop, contact export.Contact, userID int64) error {
+	if contact == (export.Contact{}) {
+		contact = b.restaurantContact(ctx, gyroskop)
+	}
+	sheet, err := b.orderSheet(ctx, gyroskop, contact)
+	if err != nil {
+		return fmt.Errorf("rendering order sheet: %w", err)
+	}
+
+	chatID := gyroskop.ChatID
+	if gyroskop.Hidden {
+		chatID = userID
+	}
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: orderSheetFileName, Bytes: sheet})
+	doc.Caption = "🧾 Bestellzettel: " + gyroskop.Name
+	_, err = b.send(ctx, chatID, doc)
+	return err
+}
+
+// handleOrderSheet sends the Bestellzettel of the replied-to or latest gyroskop of the chat
+// Format: /bestellzettel [Restaurant][, Telefon]
+func (b *Bot) handleOrderSheet(ctx context.Context, message *tgbotapi.Message, args string) {
+	gyroskop, err := b.targetGyroskop(ctx, message)
+	if err != nil {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein Gyroskop gefunden")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if gyroskop.Hidden && !b.roleOf(ctx, gyroskop, message.From.ID).can(actionEdit) {
+		b.sendMessage(ctx, message.Chat.ID, "🙈 Die Bestellungen sind verdeckt, nur Ersteller, Mitorganisatoren und Admins bekommen den Bestellzettel")
+		return
+	}
+
+	if err := b.sendOrderSheet(ctx, gyroskop, parseContact(args), message.From.ID); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden des Bestellzettels", "error", err)
+		if gyroskop.Hidden {
+			b.sendMessage(ctx, message.Chat.ID, "❌ Ich konnte dir den Bestellzettel nicht privat schicken. Starte zuerst einen privaten Chat mit mir.")
+		}
+		return
+	}
+	if gyroskop.Hidden && !message.Chat.IsPrivate() {
+		b.sendMessage(ctx, message.Chat.ID, "🙈 Die Bestellungen sind verdeckt, der Bestellzettel wurde dir privat geschickt")
+	}
+}
+
+// handleOrderSheetCallback handles the Bestellzettel button of the summary message
+func (b *Bot) handleOrderSheetCallback(ctx context.Context, query *tgbotapi.CallbackQuery, gyroskop *database.Gyroskop) {
+	if gyroskop.Hidden && !b.roleOf(ctx, gyroskop, query.From.ID).can(actionEdit) {
+		b.answerCallbackQuery(ctx, query.ID, "🙈 Die Bestellungen sind verdeckt")
+		return
+	}
+
+	if err := b.sendOrderSheet(ctx, gyroskop, export.Contact{}, query.From.ID); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden des Bestellzettels", "error", err)
+		if gyroskop.Hidden {
+			b.answerCallbackAlert(ctx, query.ID, "❌ Ich konnte dir den Bestellzettel nicht privat schicken. Starte zuerst einen privaten Chat mit mir.")
+			return
+		}
+		b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Senden des Bestellzettels")
+		return
+	}
+
+	if gyroskop.Hidden {
+		b.answerCallbackQuery(ctx, query.ID, "🙈 Der Bestellzettel wurde dir privat geschickt")
+		return
+	}
+	b.answerCallbackQuery(ctx, query.ID, "🧾 Bestellzettel gesendet")
+}
@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/tionis/gyroskop/internal/export"
+)
+
+func TestParseContact(t *testing.T) {
+	tests := []struct {
+		args string
+		want export.Contact
+	}{
+		{"", export.Contact{}},
+		{"Luigi", export.Contact{Name: "Luigi"}},
+		{" Luigi , 0711 123456 ", export.Contact{Name: "Luigi", Phone: "0711 123456"}},
+		{", 0711 123456", export.Contact{Phone: "0711 123456"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseContact(tt.args); got != tt.want {
+			t.Errorf("parseContact(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/payment"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Keys of /zahlung
+const (
+	paymentIBAN   = "iban"
+	paymentName   = "name"
+	paymentPayPal = "paypal"
+	paymentDelete = "löschen"
+)
+
+// applyPaymentDetail changes one of the payment details, "löschen" as value
+// removes it. Setting an IBAN without beneficiary uses defaultName.
+func applyPaymentDetail(details *database.PaymentDetails, key, value, defaultName string) error {
+	value = strings.TrimSpace(value)
+	remove := strings.EqualFold(value, paymentDelete)
+	switch key {
+	case paymentIBAN:
+		if remove {
+			details.IBAN = ""
+			return nil
+		}
+		iban, err := payment.NormalizeIBAN(value)
+		if err != nil {
+			return err
+		}
+		details.IBAN = iban
+		if details.Name == "" {
+			details.Name = defaultName
+		}
+	case paymentName:
+		if err := payment.ValidateName(value); err != nil {
+			return err
+		}
+		details.Name = value
+	case paymentPayPal:
+		if remove {
+			details.PayPal = ""
+			return nil
+		}
+		name, err := payment.NormalizePayPal(value)
+		if err != nil {
+			return err
+		}
+		details.PayPal = name
+	default:
+		return errInvalidSetting
+	}
+	return nil
+}
+
+// handlePaymentDetails shows and changes where the user wants to be paid when
+// collecting the money of a gyroskop (private chat only)
+// Format:
+//
+//	/zahlung -> show the payment details
+//	/zahlung iban DE89 3704 0044 0532 0130 00 -> set the IBAN for transfers
+//	/zahlung name Anna Schmidt -> set the beneficiary of transfers
+//	/zahlung paypal annaschmidt -> set the PayPal.me name
+//	/zahlung iban|paypal löschen -> remove the IBAN or PayPal.me name
+//	/zahlung löschen -> remove all payment details
+func (b *Bot) handlePaymentDetails(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+	details, err := b.db.GetPaymentDetails(ctx, message.From.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Zahlungsdaten", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Zahlungsdaten")
+		return
+	}
+
+	key, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+	switch key = strings.ToLower(key); key {
+	case "":
+		b.sendFormatted(ctx, chatID, formatPaymentDetails(details))
+		return
+	case paymentDelete:
+		if err := b.db.DeletePaymentDetails(ctx, message.From.ID); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Löschen der Zahlungsdaten", "error", err)
+			b.sendMessage(ctx, chatID, "❌ Fehler beim Löschen der Zahlungsdaten")
+			return
+		}
+		b.log.InfoContext(ctx, "Payment details deleted")
+		b.sendMessage(ctx, chatID, "🗑 Zahlungsdaten gelöscht")
+		return
+	}
+
+	defaultName := strings.TrimSpace(message.From.FirstName + " " + message.From.LastName)
+	if err := applyPaymentDetail(details, key, value, defaultName); err != nil {
+		switch {
+		case errors.Is(err, payment.ErrInvalidIBAN):
+			b.sendMessage(ctx, chatID, "⚠️ Ungültige IBAN. Bitte prüfe die Eingabe, z.B. /zahlung iban DE89 3704 0044 0532 0130 00")
+		case errors.Is(err, payment.ErrInvalidName):
+			b.sendMessage(ctx, chatID, "⚠️ Ungültiger Name. Er muss zwischen 1 und 70 Zeichen lang sein, z.B. /zahlung name Anna Schmidt")
+		case errors.Is(err, payment.ErrInvalidPayPal):
+			b.sendMessage(ctx, chatID, "⚠️ Ungültiger PayPal.me-Name. Verwende z.B. /zahlung paypal annaschmidt")
+		default:
+			b.sendMessage(ctx, chatID, "⚠️ Ungültiges Format. Verwende: /zahlung iban|name|paypal Wert oder /zahlung löschen")
+		}
+		return
+	}
+	if details.IBAN != "" && details.Name == "" {
+		b.sendMessage(ctx, chatID, "⚠️ Für Überweisungen brauche ich noch deinen Namen: /zahlung name Anna Schmidt")
+	}
+
+	if err := b.db.SavePaymentDetails(ctx, details); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Speichern der Zahlungsdaten", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Speichern der Zahlungsdaten")
+		return
+	}
+	b.log.InfoContext(ctx, "Payment details changed", "key", key)
+	b.sendFormatted(ctx, chatID, formatPaymentDetails(details))
+}
+
+// formatPaymentDetails shows the payment details of a user and how to change them
+func formatPaymentDetails(details *database.PaymentDetails) string {
+	lines := []render.Line{{render.Plain("💳 "), render.Bold("Deine Zahlungsdaten")}, nil}
+	if details.IBAN != "" {
+		lines = append(lines,
+			render.Line{render.Plain("🏦 IBAN: " + payment.FormatIBAN(details.IBAN))},
+			render.Line{render.Plain("👤 Empfänger: " + details.Name)},
+		)
+	}
+	if details.PayPal != "" {
+		lines = append(lines, render.Line{render.Plain("🅿️ PayPal.me: " + details.PayPal)})
+	}
+	if details.IsEmpty() {
+		lines = append(lines, render.Line{render.Plain("Noch keine hinterlegt.")})
+	}
+	lines = append(lines, nil,
+		render.Line{render.Plain("Wenn du für ein Gyroskop Geld einsammelst, schickt /einsammeln in der Gruppe allen einen QR-Code für die Überweisung und/oder einen PayPal-Link mit ihrem Betrag.")},
+		nil,
+		render.Line{render.Plain("IBAN: /zahlung iban DE89 3704 0044 0532 0130 00")},
+		render.Line{render.Plain("Name für Überweisungen: /zahlung name Anna Schmidt")},
+		render.Line{render.Plain("PayPal: /zahlung paypal annaschmidt")},
+		render.Line{render.Plain("Entfernen: /zahlung iban löschen, /zahlung paypal löschen oder /zahlung löschen")},
+	)
+	return messageFormat.Lines(lines...)
+}
+
+// paymentReference is the reference of transfers for a gyroskop
+func paymentReference(gyroskop *database.Gyroskop) string {
+	return fmt.Sprintf("Gyroskop #%d", gyroskop.ID)
+}
+
+// paymentRequest builds the request to pay a share to the collector: the text
+// and, if the collector has an IBAN, the EPC QR code. payer is only named in
+// requests posted to the group.
+func paymentRequest(details *database.PaymentDetails, gyroskop *database.Gyroskop, chatTitle, payer string, amount int) (string, []byte, error) {
+	reference := paymentReference(gyroskop)
+	lines := []render.Line{
+		{render.Plain("💸 "), render.Bold(fmt.Sprintf("Zahlung für %s (%s) aus %s", gyroskop.Name, reference, chatTitle))},
+		nil,
+	}
+	if payer != "" {
+		lines = append(lines, render.Line{render.Plain("👤 Von: " + payer)})
+	}
+	lines = append(lines, render.Line{render.Plain("💶 Betrag: "), render.Bold(formatPrice(amount))})
+
+	var qr []byte
+	if details.IBAN != "" && details.Name != "" {
+		var err error
+		qr, err = payment.QRCode(payment.Transfer{Name: details.Name, IBAN: details.IBAN, Amount: amount, Reference: reference})
+		if err != nil {
+			return "", nil, err
+		}
+		lines = append(lines, nil,
+			render.Line{render.Plain("🏦 Überweisung an " + details.Name)},
+			render.Line{render.Plain("IBAN: " + payment.FormatIBAN(details.IBAN))},
+			render.Line{render.Plain("Verwendungszweck: " + reference)},
+			render.Line{render.Plain("📷 Den QR-Code kannst du mit deiner Banking-App scannen.")},
+		)
+	}
+	if details.PayPal != "" {
+		link := payment.PayPalLink(details.PayPal, amount)
+		lines = append(lines, nil, render.Line{render.Plain("🅿️ "), render.Link("Mit PayPal bezahlen", link)})
+	}
+	return messageFormat.Lines(lines...), qr, nil
+}
+
+// sendPaymentRequest sends a payment request, with the QR code as photo if there is one
+func (b *Bot) sendPaymentRequest(ctx context.Context, chatID int64, replyTo int, text string, qr []byte) error {
+	var msg tgbotapi.Chattable
+	if qr != nil {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "zahlung.png", Bytes: qr})
+		photo.Caption = text
+		photo.ParseMode = messageFormat.ParseMode()
+		photo.ReplyToMessageID = replyTo
+		photo.AllowSendingWithoutReply = true
+		msg = photo
+	} else {
+		message := tgbotapi.NewMessage(chatID, text)
+		message.ParseMode = messageFormat.ParseMode()
+		message.ReplyToMessageID = replyTo
+		message.AllowSendingWithoutReply = true
+		message.DisableWebPagePreview = true
+		msg = message
+	}
+	_, err := b.send(ctx, chatID, msg)
+	return err
+}
+
+// handleCollect sends everyone who ordered in the replied-to or latest closed
+// gyroskop a request to pay their share of the costs to the sender. Requests
+// that cannot be sent privately are posted as reply to the summary, except
+// for hidden gyroskops.
+func (b *Bot) handleCollect(ctx context.Context, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	gyroskop, err := b.targetGyroskop(ctx, message)
+	if err != nil {
+		b.sendMessage(ctx, chatID, "❌ Kein Gyroskop gefunden")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if !b.authorize(ctx, message, gyroskop, actionEdit, "Geld einsammeln") {
+		return
+	}
+	if gyroskop.State == database.StateOpen {
+		b.sendMessage(ctx, chatID, "⚠️ Geld wird erst nach dem Beenden eingesammelt. Beende das Gyroskop mit /ende.")
+		return
+	}
+
+	details, err := b.db.GetPaymentDetails(ctx, message.From.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Zahlungsdaten", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Zahlungsdaten")
+		return
+	}
+	if details.PayPal == "" && (details.IBAN == "" || details.Name == "") {
+		b.sendMessage(ctx, chatID, "⚠️ Hinterlege zuerst in einem privaten Chat mit mir deine IBAN oder deinen PayPal.me-Namen: /zahlung")
+		return
+	}
+
+	orders, err := b.db.GetOrdersByGyroskop(ctx, gyroskop.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Bestellungen")
+		return
+	}
+	split, err := b.gyroskopCosts(ctx, gyroskop, orders)
+	switch {
+	case errors.Is(err, errNoOrders) || errors.Is(err, errNoAmounts):
+		b.sendMessage(ctx, chatID, splitError(err))
+		return
+	case err != nil:
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Kosten", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Kosten")
+		return
+	case split == nil || split.Subtotal == 0:
+		// Extras alone would make everyone pay only their part of the delivery fee
+		b.sendMessage(ctx, chatID, "⚠️ Trage zuerst ein, wer wie viel zahlt: /kosten betrag @anna 12,50, Ben 8")
+		return
+	}
+
+	chatTitle := b.chatTitle(ctx, chatID)
+	var sent int
+	var unreachable []share
+	for _, sh := range split.Shares {
+		if sh.Total() <= 0 || sh.Order.UserID == message.From.ID {
+			continue
+		}
+		text, qr, err := paymentRequest(details, gyroskop, chatTitle, "", sh.Total())
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Erstellen der Zahlungsaufforderung", "error", err)
+			b.sendMessage(ctx, chatID, "❌ Fehler beim Erstellen der Zahlungsaufforderung, prüfe deine Zahlungsdaten mit /zahlung")
+			return
+		}
+		if err := b.sendPaymentRequest(ctx, sh.Order.UserID, 0, text, qr); err != nil {
+			b.log.InfoContext(ctx, "Payment request not sent privately", "recipient_id", sh.Order.UserID, "error", err)
+			unreachable = append(unreachable, sh)
+			continue
+		}
+		sent++
+	}
+	b.log.InfoContext(ctx, "Payment requests sent", "private", sent, "unreachable", len(unreachable))
+
+	if len(unreachable) == 0 {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("💸 %d Zahlungsaufforderungen privat verschickt", sent))
+		return
+	}
+
+	var names []string
+	for _, sh := range unreachable {
+		names = append(names, b.formatUserName(sh.Order))
+	}
+	if gyroskop.Hidden {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("💸 %d Zahlungsaufforderungen privat verschickt. %d Personen haben noch keinen privaten Chat mit mir gestartet.", sent, len(unreachable)))
+		b.sendFormatted(ctx, message.From.ID, messageFormat.Escape("💸 Nicht erreicht: "+strings.Join(names, ", ")))
+		return
+	}
+
+	b.sendFormatted(ctx, chatID, messageFormat.Escape(fmt.Sprintf("💸 %d Zahlungsaufforderungen privat verschickt. Nicht erreicht, daher hier: %s", sent, strings.Join(names, ", "))))
+	for _, sh := range unreachable {
+		text, qr, err := paymentRequest(details, gyroskop, chatTitle, b.formatUserName(sh.Order), sh.Total())
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Erstellen der Zahlungsaufforderung", "error", err)
+			return
+		}
+		if err := b.sendPaymentRequest(ctx, chatID, gyroskop.SummaryMessageID, text, qr); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Senden der Zahlungsaufforderung", "error", err)
+		}
+	}
+}
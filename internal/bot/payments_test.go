@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/payment"
+)
+
+func TestApplyPaymentDetail(t *testing.T) {
+	tests := []struct {
+		name    string
+		details database.PaymentDetails
+		key     string
+		value   string
+		want    database.PaymentDetails
+		wantErr error
+	}{
+		{"iban uses default name", database.PaymentDetails{}, paymentIBAN, "de89 3704 0044 0532 0130 00",
+			database.PaymentDetails{Name: "Anna Schmidt", IBAN: "DE89370400440532013000"}, nil},
+		{"iban keeps name", database.PaymentDetails{Name: "A. Schmidt"}, paymentIBAN, "DE89370400440532013000",
+			database.PaymentDetails{Name: "A. Schmidt", IBAN: "DE89370400440532013000"}, nil},
+		{"invalid iban", database.PaymentDetails{}, paymentIBAN, "DE00 1234", database.PaymentDetails{}, payment.ErrInvalidIBAN},
+		{"remove iban", database.PaymentDetails{Name: "Anna", IBAN: "DE89370400440532013000"}, paymentIBAN, "Löschen",
+			database.PaymentDetails{Name: "Anna"}, nil},
+		{"name", database.PaymentDetails{}, paymentName, " Anna Schmidt ", database.PaymentDetails{Name: "Anna Schmidt"}, nil},
+		{"empty name", database.PaymentDetails{}, paymentName, "", database.PaymentDetails{}, payment.ErrInvalidName},
+		{"paypal", database.PaymentDetails{}, paymentPayPal, "paypal.me/anna", database.PaymentDetails{PayPal: "anna"}, nil},
+		{"invalid paypal", database.PaymentDetails{}, paymentPayPal, "anna schmidt", database.PaymentDetails{}, payment.ErrInvalidPayPal},
+		{"remove paypal", database.PaymentDetails{PayPal: "anna"}, paymentPayPal, "löschen", database.PaymentDetails{}, nil},
+		{"unknown key", database.PaymentDetails{}, "bic", "COBADEFFXXX", database.PaymentDetails{}, errInvalidSetting},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details := tt.details
+			err := applyPaymentDetail(&details, tt.key, tt.value, "Anna Schmidt")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("applyPaymentDetail() error = %v, want %v", err, tt.wantErr)
+			}
+			if details != tt.want {
+				t.Errorf("applyPaymentDetail() = %+v, want %+v", details, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentRequest(t *testing.T) {
+	gyroskop := &database.Gyroskop{ID: 42, Name: "Pizza & Co"}
+
+	tests := []struct {
+		name     string
+		details  database.PaymentDetails
+		payer    string
+		wantQR   bool
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "iban",
+			details:  database.PaymentDetails{Name: "Anna Schmidt", IBAN: "DE89370400440532013000"},
+			wantQR:   true,
+			contains: []string{"<b>Zahlung für Pizza &amp; Co (Gyroskop #42) aus Büro</b>", "<b>12,50 €</b>", "IBAN: DE89 3704 0044 0532 0130 00", "Verwendungszweck: Gyroskop #42"},
+			excludes: []string{"PayPal", "Von:"},
+		},
+		{
+			name:     "paypal in the group",
+			details:  database.PaymentDetails{PayPal: "anna"},
+			payer:    "Ben <3",
+			contains: []string{"👤 Von: Ben &lt;3", `<a href="https://paypal.me/anna/12.50EUR">Mit PayPal bezahlen</a>`},
+			excludes: []string{"IBAN"},
+		},
+		{
+			name:     "iban without name",
+			details:  database.PaymentDetails{IBAN: "DE89370400440532013000", PayPal: "anna"},
+			contains: []string{"paypal.me/anna"},
+			excludes: []string{"IBAN"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, qr, err := paymentRequest(&tt.details, gyroskop, "Büro", tt.payer, 1250)
+			if err != nil {
+				t.Fatalf("paymentRequest() error = %v", err)
+			}
+			if tt.wantQR != (qr != nil) || (qr != nil && !bytes.HasPrefix(qr, []byte("\x89PNG"))) {
+				t.Errorf("paymentRequest() QR code = %d bytes, want QR code %v", len(qr), tt.wantQR)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("paymentRequest() = %q, want it to contain %q", text, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("paymentRequest() = %q, should not contain %q", text, unwanted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// adminCacheTTL is how long the result of getChatMember is cached
+const adminCacheTTL = 5 * time.Minute
+
+// role is the relation of a user to a gyroskop
+type role int
+
+const (
+	roleMember    role = iota // May order
+	roleOrganizer             // Co-organiser, may end and edit the gyroskop
+	roleOwner                 // Creator or new owner, may also manage co-organisers and transfer
+	roleModerator             // Chat administrator, may do everything
+)
+
+// action is something only some roles may do with a gyroskop
+type action int
+
+const (
+	actionEdit   action = iota // End, reopen or change deadline and options
+	actionManage               // Add or remove co-organisers and transfer ownership
+)
+
+// can reports whether a role permits an action
+func (r role) can(a action) bool {
+	switch a {
+	case actionEdit:
+		return r >= roleOrganizer
+	case actionManage:
+		return r >= roleOwner
+	default:
+		return false
+	}
+}
+
+// allowedRoles describes who may perform an action, for error messages
+func (a action) allowedRoles() string {
+	if a == actionManage {
+		return "der Ersteller und Gruppen-Admins"
+	}
+	return "der Ersteller, Mitorganisatoren und Gruppen-Admins"
+}
+
+// roleOf determines the role of a user for a gyroskop
+func (b *Bot) roleOf(ctx context.Context, gyroskop *database.Gyroskop, userID int64) role {
+	if gyroskop.CreatedBy == userID {
+		return roleOwner
+	}
+	if b.isChatAdmin(ctx, gyroskop.ChatID, userID) {
+		return roleModerator
+	}
+
+	organizer, err := b.db.IsOrganizer(ctx, gyroskop.ID, userID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Prüfen der Mitorganisatoren", "error", err)
+	}
+	if organizer {
+		return roleOrganizer
+	}
+	return roleMember
+}
+
+// authorize checks whether the sender of a message may perform an action on a
+// gyroskop and tells them if not. what completes "Nur ... können ...!".
+func (b *Bot) authorize(ctx context.Context, message *tgbotapi.Message, gyroskop *database.Gyroskop, a action, what string) bool {
+	if b.roleOf(ctx, gyroskop, message.From.ID).can(a) {
+		return true
+	}
+	b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ Nur %s können %s!", a.allowedRoles(), what))
+	return false
+}
+
+// adminCache caches whether users are administrators of a chat
+type adminCache struct {
+	mu      sync.Mutex
+	entries map[adminKey]adminEntry
+}
+
+type adminKey struct {
+	chatID int64
+	userID int64
+}
+
+type adminEntry struct {
+	admin   bool
+	expires time.Time
+}
+
+// get returns the cached admin status of a user, if it has not expired
+func (c *adminCache) get(chatID, userID int64, now time.Time) (admin, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[adminKey{chatID, userID}]
+	if !ok || now.After(entry.expires) {
+		return false, false
+	}
+	return entry.admin, true
+}
+
+// set caches the admin status of a user and drops expired entries
+func (c *adminCache) set(chatID, userID int64, admin bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[adminKey]adminEntry)
+	}
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[adminKey{chatID, userID}] = adminEntry{admin: admin, expires: now.Add(adminCacheTTL)}
+}
+
+// isChatAdmin checks whether a user is creator or administrator of a chat.
+// The result is cached for adminCacheTTL; errors are not cached.
+func (b *Bot) isChatAdmin(ctx context.Context, chatID, userID int64) bool {
+	if admin, ok := b.admins.get(chatID, userID, time.Now()); ok {
+		return admin
+	}
+
+	member, err := b.chatMember(ctx, chatID, userID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden des Chat-Mitglieds", "error", err)
+		return false
+	}
+
+	admin := member.IsCreator() || member.IsAdministrator()
+	b.admins.set(chatID, userID, admin, time.Now())
+	return admin
+}
+
+// chatMember loads the membership of a user in a chat. The request goes
+// through the outbox, which only applies the global limit to it.
+func (b *Bot) chatMember(ctx context.Context, chatID, userID int64) (tgbotapi.ChatMember, error) {
+	var member tgbotapi.ChatMember
+	resp, err := b.request(ctx, 0, tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		return member, err
+	}
+	err = json.Unmarshal(resp.Result, &member)
+	return member, err
+}
+
+// isChatMember checks whether a user is a member of a chat
+func (b *Bot) isChatMember(ctx context.Context, chatID, userID int64) bool {
+	member, err := b.chatMember(ctx, chatID, userID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden des Chat-Mitglieds", "error", err)
+		return false
+	}
+
+	return !member.HasLeft() && !member.WasKicked()
+}
+
+// errUnknownUser is returned for @usernames the bot has not seen in the chat
+var errUnknownUser = errors.New("unknown user")
+
+// mentionedUser returns the user a command refers to: a user mentioned without
+// username, the author of the replied-to message or else the @username in args
+func mentionedUser(message *tgbotapi.Message, args string) (*tgbotapi.User, string) {
+	for _, entity := range message.Entities {
+		if entity.Type == "text_mention" && entity.User != nil {
+			return entity.User, ""
+		}
+	}
+
+	for _, field := range strings.Fields(args) {
+		if strings.HasPrefix(field, "@") && len(field) > 1 {
+			return nil, strings.TrimPrefix(field, "@")
+		}
+	}
+
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && !reply.From.IsBot {
+		return reply.From, ""
+	}
+	return nil, ""
+}
+
+// targetUser resolves the user a command refers to. Bots cannot look up
+// usernames, so @usernames are resolved from the orders placed in the chat.
+func (b *Bot) targetUser(ctx context.Context, message *tgbotapi.Message, args string) (*tgbotapi.User, error) {
+	user, username := mentionedUser(message, args)
+	if user != nil || username == "" {
+		return user, nil
+	}
+
+	chatUser, err := b.db.FindChatUser(ctx, message.Chat.ID, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errUnknownUser
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.User{
+		ID:        chatUser.UserID,
+		UserName:  chatUser.Username,
+		FirstName: chatUser.FirstName,
+		LastName:  chatUser.LastName,
+	}, nil
+}
+
+// handleOrganizers adds, removes or lists the co-organisers of the active gyroskop
+// Format: /mitorganisator [entfernen] @user (or as reply to a message of the user)
+func (b *Bot) handleOrganizers(ctx context.Context, message *tgbotapi.Message, args string) {
+	gyroskop, exists := b.getActiveGyroskop(message.Chat.ID)
+	if !exists {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	remove := false
+	if fields := strings.Fields(args); len(fields) > 0 && (strings.EqualFold(fields[0], "entfernen") || strings.EqualFold(fields[0], "remove")) {
+		remove = true
+	}
+
+	user, err := b.targetUser(ctx, message, args)
+	if errors.Is(err, errUnknownUser) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Diesen Nutzer kenne ich noch nicht. Antworte stattdessen mit /mitorganisator auf eine Nachricht von ihm.")
+		return
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Suchen des Nutzers", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Suchen des Nutzers")
+		return
+	}
+
+	if user == nil {
+		if remove {
+			b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /mitorganisator entfernen @nutzer")
+			return
+		}
+		b.sendOrganizers(ctx, gyroskop)
+		return
+	}
+
+	if !b.authorize(ctx, message, gyroskop, actionManage, "Mitorganisatoren verwalten") {
+		return
+	}
+
+	name := b.getUserName(user)
+	if remove {
+		removed, err := b.db.RemoveOrganizer(ctx, gyroskop.ID, user.ID)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Entfernen des Mitorganisators", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Entfernen des Mitorganisators")
+			return
+		}
+		if !removed {
+			b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ %s ist kein Mitorganisator", name))
+			return
+		}
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("👥 %s ist kein Mitorganisator mehr", name))
+		return
+	}
+
+	if user.IsBot || user.ID == gyroskop.CreatedBy {
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ %s kann kein Mitorganisator werden", name))
+		return
+	}
+
+	added, err := b.db.AddOrganizer(ctx, gyroskop.ID, user.ID, name, message.From.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Hinzufügen des Mitorganisators", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Hinzufügen des Mitorganisators")
+		return
+	}
+	if !added {
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ %s ist bereits Mitorganisator", name))
+		return
+	}
+
+	b.log.InfoContext(ctx, "Organizer added", "organizer_id", user.ID)
+	b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("👥 %s ist jetzt Mitorganisator und kann das Gyroskop beenden und bearbeiten", name))
+}
+
+// sendOrganizers lists the co-organisers of a gyroskop
+func (b *Bot) sendOrganizers(ctx context.Context, gyroskop *database.Gyroskop) {
+	organizers, err := b.db.GetOrganizers(ctx, gyroskop.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Mitorganisatoren", "error", err)
+		b.sendMessage(ctx, gyroskop.ChatID, "❌ Fehler beim Laden der Mitorganisatoren")
+		return
+	}
+
+	if len(organizers) == 0 {
+		b.sendMessage(ctx, gyroskop.ChatID, "👥 Noch keine Mitorganisatoren. Hinzufügen mit /mitorganisator @nutzer")
+		return
+	}
+
+	lines := []render.Line{{render.Plain("👥 "), render.Bold("Mitorganisatoren von " + gyroskop.Name + ":")}}
+	for _, organizer := range organizers {
+		name := organizer.Name
+		if name == "" {
+			name = fmt.Sprintf("User %d", organizer.UserID)
+		}
+		lines = append(lines, render.Line{render.Plain("• " + name)})
+	}
+	b.sendFormatted(ctx, gyroskop.ChatID, messageFormat.Lines(lines...))
+}
+
+// handleTransfer makes another user the owner of the active gyroskop
+// Format: /uebergeben @user (or as reply to a message of the user)
+func (b *Bot) handleTransfer(ctx context.Context, message *tgbotapi.Message, args string) {
+	gyroskop, exists := b.getActiveGyroskop(message.Chat.ID)
+	if !exists {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if !b.authorize(ctx, message, gyroskop, actionManage, "das Gyroskop übergeben") {
+		return
+	}
+
+	user, err := b.targetUser(ctx, message, args)
+	if errors.Is(err, errUnknownUser) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Diesen Nutzer kenne ich noch nicht. Antworte stattdessen mit /uebergeben auf eine Nachricht von ihm.")
+		return
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Suchen des Nutzers", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Suchen des Nutzers")
+		return
+	}
+	if user == nil {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /uebergeben @nutzer oder antworte auf eine Nachricht")
+		return
+	}
+
+	name := b.getUserName(user)
+	if user.IsBot || user.ID == gyroskop.CreatedBy {
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚠️ Das Gyroskop kann nicht an %s übergeben werden", name))
+		return
+	}
+
+	// The previous owner stays on as co-organiser
+	previousOwnerName := ""
+	if message.From.ID == gyroskop.CreatedBy {
+		previousOwnerName = b.getUserName(message.From)
+	}
+	if err := b.db.TransferGyroskop(ctx, gyroskop.ID, user.ID, previousOwnerName); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Übergeben des Gyroskops", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Übergeben des Gyroskops")
+		return
+	}
+	b.log.InfoContext(ctx, "Gyroskop transferred", "previous_owner_id", gyroskop.CreatedBy, "owner_id", user.ID)
+
+	// Update cache
+	gyroskop.CreatedBy = user.ID
+
+	b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("🔑 %s ist jetzt verantwortlich für %s", name, gyroskop.Name))
+	b.updateGyroskopMessage(ctx, gyroskop, nil)
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRoleCan(t *testing.T) {
+	tests := []struct {
+		role       role
+		wantEdit   bool
+		wantManage bool
+	}{
+		{roleMember, false, false},
+		{roleOrganizer, true, false},
+		{roleOwner, true, true},
+		{roleModerator, true, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.can(actionEdit); got != tt.wantEdit {
+			t.Errorf("role %d can(actionEdit) = %v, want %v", tt.role, got, tt.wantEdit)
+		}
+		if got := tt.role.can(actionManage); got != tt.wantManage {
+			t.Errorf("role %d can(actionManage) = %v, want %v", tt.role, got, tt.wantManage)
+		}
+	}
+}
+
+func TestAdminCache(t *testing.T) {
+	var cache adminCache
+	now := time.Now()
+
+	if _, ok := cache.get(1, 2, now); ok {
+		t.Fatal("get() on empty cache should miss")
+	}
+
+	cache.set(1, 2, true, now)
+	if admin, ok := cache.get(1, 2, now.Add(time.Minute)); !ok || !admin {
+		t.Errorf("get() = %v, %v, want true, true", admin, ok)
+	}
+	if _, ok := cache.get(1, 3, now); ok {
+		t.Error("get() for another user should miss")
+	}
+	if _, ok := cache.get(1, 2, now.Add(adminCacheTTL+time.Second)); ok {
+		t.Error("get() after the TTL should miss")
+	}
+
+	// Expired entries are dropped when setting new ones
+	cache.set(1, 3, false, now.Add(adminCacheTTL+time.Second))
+	if len(cache.entries) != 1 {
+		t.Errorf("cache has %d entries, want 1", len(cache.entries))
+	}
+}
+
+func TestMentionedUser(t *testing.T) {
+	anna := &tgbotapi.User{ID: 1, FirstName: "Anna"}
+	ben := &tgbotapi.User{ID: 2, FirstName: "Ben"}
+	bot := &tgbotapi.User{ID: 3, FirstName: "Gyroskop", IsBot: true}
+
+	tests := []struct {
+		name         string
+		message      *tgbotapi.Message
+		args         string
+		wantUser     *tgbotapi.User
+		wantUsername string
+	}{
+		{"nothing", &tgbotapi.Message{}, "", nil, ""},
+		{"username", &tgbotapi.Message{}, "@ben_k", nil, "ben_k"},
+		{"username after keyword", &tgbotapi.Message{}, "entfernen @ben_k", nil, "ben_k"},
+		{"lone at sign", &tgbotapi.Message{}, "@", nil, ""},
+		{"text mention", &tgbotapi.Message{Entities: []tgbotapi.MessageEntity{{Type: "text_mention", User: anna}}}, "Anna", anna, ""},
+		{"reply", &tgbotapi.Message{ReplyToMessage: &tgbotapi.Message{From: ben}}, "", ben, ""},
+		{"username wins over reply", &tgbotapi.Message{ReplyToMessage: &tgbotapi.Message{From: ben}}, "@anna", nil, "anna"},
+		{"reply to bot", &tgbotapi.Message{ReplyToMessage: &tgbotapi.Message{From: bot}}, "", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, username := mentionedUser(tt.message, tt.args)
+			if user != tt.wantUser || username != tt.wantUsername {
+				t.Errorf("mentionedUser() = %v, %q, want %v, %q", user, username, tt.wantUser, tt.wantUsername)
+			}
+		})
+	}
+}
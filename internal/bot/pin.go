@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+// pinGyroskopMessage pins the gyroskop message if the chat enabled pinning.
+// Pinning needs admin rights, without them this only logs a warning.
+func (b *Bot) pinGyroskopMessage(ctx context.Context, gyroskop *database.Gyroskop) {
+	if gyroskop.MessageID == 0 || !b.chatSettings(ctx, gyroskop.ChatID).Pin {
+		return
+	}
+
+	pin := tgbotapi.PinChatMessageConfig{ChatID: gyroskop.ChatID, MessageID: gyroskop.MessageID, DisableNotification: true}
+	if _, err := b.request(ctx, gyroskop.ChatID, pin); err != nil {
+		b.log.WarnContext(ctx, "Fehler beim Anpinnen der Gyroskop-Nachricht", "error", err)
+	}
+}
+
+// unpinGyroskopMessage unpins the gyroskop message if the chat enabled pinning
+func (b *Bot) unpinGyroskopMessage(ctx context.Context, gyroskop *database.Gyroskop) {
+	if gyroskop.MessageID == 0 || !b.chatSettings(ctx, gyroskop.ChatID).Pin {
+		return
+	}
+
+	unpin := tgbotapi.UnpinChatMessageConfig{ChatID: gyroskop.ChatID, MessageID: gyroskop.MessageID}
+	if _, err := b.request(ctx, gyroskop.ChatID, unpin); err != nil {
+		b.log.WarnContext(ctx, "Fehler beim Lösen der Gyroskop-Nachricht", "error", err)
+	}
+}
+
+// handleBringUp re-sends the message of the active gyroskop at the bottom of
+// the chat and deletes the old one, so that edits and replies target the new one
+func (b *Bot) handleBringUp(ctx context.Context, message *tgbotapi.Message) {
+	gyroskop, exists := b.getActiveGyroskop(message.Chat.ID)
+	if !exists {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Kein aktives Gyroskop in dieser Gruppe")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	text, err := b.gyroskopMessageText(ctx, gyroskop)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Bestellungen")
+		return
+	}
+
+	sentMessage := b.sendMessageWithReactions(ctx, gyroskop.ChatID, text, gyroskop)
+	if sentMessage == nil {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Senden der Gyroskop-Nachricht")
+		return
+	}
+	if err := b.db.UpdateGyroskopMessageID(ctx, gyroskop.ID, sentMessage.MessageID); err != nil {
+		b.log.ErrorContext(ctx, "Error saving message ID", "message_id", sentMessage.MessageID, "error", err)
+	}
+	oldMessageID := gyroskop.MessageID
+	gyroskop.MessageID = sentMessage.MessageID
+	b.log.InfoContext(ctx, "Gyroskop message re-sent", "old_message_id", oldMessageID, "message_id", gyroskop.MessageID)
+
+	b.pinGyroskopMessage(ctx, gyroskop)
+	if oldMessageID != 0 {
+		b.removeOldGyroskopMessage(ctx, gyroskop.ChatID, oldMessageID)
+	}
+}
+
+// removeOldGyroskopMessage deletes a replaced gyroskop message. Messages older
+// than 48 hours cannot be deleted by bots, those are replaced by a pointer to
+// the new message instead.
+func (b *Bot) removeOldGyroskopMessage(ctx context.Context, chatID int64, messageID int) {
+	_, err := b.request(ctx, chatID, tgbotapi.NewDeleteMessage(chatID, messageID))
+	if err == nil {
+		return
+	}
+	b.log.WarnContext(ctx, "Fehler beim Löschen der alten Gyroskop-Nachricht", "message_id", messageID, "error", err)
+
+	b.outbox.Edit(ctx, chatID, messageID, func(ctx context.Context) (tgbotapi.Chattable, error) {
+		return tgbotapi.NewEditMessageText(chatID, messageID, "⬇️ Das Gyroskop wurde nach unten geholt"), nil
+	})
+}
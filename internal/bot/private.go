@@ -0,0 +1,584 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/metrics"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Private chats
+//
+// Gyroskops are opened in groups only. Group members can order privately via
+// the deep link t.me/<bot>?start=g<id> of the gyroskop message; text orders in
+// the private chat then go to that gyroskop. Users who started a private chat
+// get the final summary of the gyroskops they ordered in.
+const (
+	deepLinkPrefix        = "g" // /start g<gyroskopID>
+	privateCallbackPrefix = "p" // p<gyroskopID>:<index>_<quantity>, p<gyroskopID>:0 cancels
+)
+
+// privateSessions remembers which gyroskop users order for in their private chat
+type privateSessions struct {
+	mu      sync.Mutex
+	current map[int64]int       // User ID to the gyroskop text orders go to
+	members map[privateKey]bool // Users known to be in the chat of a gyroskop
+}
+
+type privateKey struct {
+	userID     int64
+	gyroskopID int
+}
+
+// choose makes a gyroskop the one the text orders of a user go to
+func (s *privateSessions) choose(userID int64, gyroskopID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = make(map[int64]int)
+	}
+	s.current[userID] = gyroskopID
+}
+
+// chosen returns the gyroskop the text orders of a user go to
+func (s *privateSessions) chosen(userID int64) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gyroskopID, ok := s.current[userID]
+	return gyroskopID, ok
+}
+
+// isMember returns whether a user is known to be in the chat of a gyroskop
+func (s *privateSessions) isMember(userID int64, gyroskopID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.members[privateKey{userID, gyroskopID}]
+}
+
+// addMember remembers that a user is in the chat of a gyroskop
+func (s *privateSessions) addMember(userID int64, gyroskopID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.members == nil {
+		s.members = make(map[privateKey]bool)
+	}
+	s.members[privateKey{userID, gyroskopID}] = true
+}
+
+// forget drops everything remembered about a closed gyroskop
+func (s *privateSessions) forget(gyroskopID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, current := range s.current {
+		if current == gyroskopID {
+			delete(s.current, userID)
+		}
+	}
+	for key := range s.members {
+		if key.gyroskopID == gyroskopID {
+			delete(s.members, key)
+		}
+	}
+}
+
+// handlePrivateMessage handles messages in private chats with the bot
+func (b *Bot) handlePrivateMessage(ctx context.Context, message *tgbotapi.Message) {
+	if !message.IsCommand() {
+		b.handlePrivateOrder(ctx, message)
+		return
+	}
+
+	switch message.Command() {
+	case "start":
+		b.handlePrivateStart(ctx, message, message.CommandArguments())
+	case "help":
+		b.handlePrivateHelp(ctx, message)
+	case "meine":
+		b.handleMyOrders(ctx, message)
+	case "stornieren", "cancel":
+		b.handlePrivateCancel(ctx, message)
+	case "benachrichtigungen":
+		b.handlePrivateSummaries(ctx, message, message.CommandArguments())
+	case "zahlung":
+		b.handlePaymentDetails(ctx, message, message.CommandArguments())
+	default:
+		b.sendMessage(ctx, message.Chat.ID, "🥙 Dieser Befehl funktioniert nur in Gruppen! Privat kannst du über den Button „💬 Privat bestellen“ bestellen, siehe /help.")
+	}
+}
+
+// handlePrivateHelp sends the help message of the private chat
+func (b *Bot) handlePrivateHelp(ctx context.Context, message *tgbotapi.Message) {
+	helpText := `🥙 <b>Gyroskop Bot - Privat bestellen</b>
+
+Gyroskops werden in Gruppen mit /gyroskop geöffnet. Über den Button „💬 Privat bestellen“ unter der Gyroskop-Nachricht kannst du hier bestellen, ohne die Gruppe vollzuschreiben.
+
+<b>Befehle:</b>
+/meine - Deine offenen Bestellungen in allen Gruppen
+/stornieren - Bestellung im gewählten Gyroskop stornieren
+/benachrichtigungen [an|aus] - Finale Übersicht nach dem Beenden privat erhalten
+/zahlung - IBAN oder PayPal.me hinterlegen, um mit /einsammeln Geld für ein Gyroskop einzusammeln
+/help - Diese Hilfe anzeigen
+
+<b>Bestellen:</b>
+💬 Schreibe die Anzahl und Option (z.B. "2 fleisch") oder nutze die Buttons
+❌ "0" storniert deine Bestellung`
+
+	b.sendFormatted(ctx, message.Chat.ID, helpText)
+}
+
+// handlePrivateStart registers the private chat and chooses the gyroskop of a deep link
+func (b *Bot) handlePrivateStart(ctx context.Context, message *tgbotapi.Message, args string) {
+	if err := b.db.AddPrivateChat(ctx, message.From.ID); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Speichern des privaten Chats", "error", err)
+	}
+
+	if !strings.HasPrefix(args, deepLinkPrefix) {
+		b.handlePrivateHelp(ctx, message)
+		return
+	}
+	gyroskopID, err := strconv.Atoi(strings.TrimPrefix(args, deepLinkPrefix))
+	if err != nil {
+		b.handlePrivateHelp(ctx, message)
+		return
+	}
+
+	gyroskop, exists := b.findActiveGyroskop(gyroskopID)
+	if !exists {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Dieses Gyroskop ist nicht mehr offen. /meine zeigt deine offenen Bestellungen.")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if !b.mayOrderPrivately(ctx, message.From.ID, gyroskop) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Du bist nicht in der Gruppe dieses Gyroskops!")
+		return
+	}
+	b.private.choose(message.From.ID, gyroskop.ID)
+
+	text, keyboard, err := b.privateOrderMessage(ctx, gyroskop, message.From.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellung", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Bestellung")
+		return
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = messageFormat.ParseMode()
+	msg.ReplyMarkup = keyboard
+	if _, err := b.send(ctx, message.Chat.ID, msg); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden der Nachricht", "error", err)
+	}
+}
+
+// handlePrivateOrder places a text order in the gyroskop chosen via deep link
+func (b *Bot) handlePrivateOrder(ctx context.Context, message *tgbotapi.Message) {
+	gyroskop, ok := b.chosenGyroskop(ctx, message)
+	if !ok {
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	text := strings.TrimSpace(strings.ToLower(message.Text))
+	if text == reorderText {
+		quantities, unmapped, err := b.repeatOrder(ctx, gyroskop, message.From, "private")
+		reply, ok := b.reorderReply(gyroskop, quantities, unmapped, err)
+		if !ok {
+			b.log.ErrorContext(ctx, "Fehler beim Wiederholen der Bestellung", "error", err)
+		}
+		b.sendMessage(ctx, message.Chat.ID, reply)
+		return
+	}
+
+	quantities := map[string]int{}
+	if text != "0" {
+		quantities = b.parseOrderText(text, gyroskop.FoodOptions, b.chatSettings(ctx, gyroskop.ChatID).MaxQuantity)
+		if quantities == nil {
+			metrics.ParseFailures.Inc("order")
+			b.sendMessage(ctx, message.Chat.ID, "⚠️ Das habe ich nicht verstanden. Schreibe z.B. '2 fleisch' oder '0' zum Stornieren.")
+			return
+		}
+	}
+
+	if !b.placePrivateOrder(ctx, message, gyroskop, quantities) {
+		return
+	}
+
+	orderText := b.formatOrderQuantities(quantities, gyroskop.FoodOptions)
+	if orderText == "" {
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("❌ Bestellung bei %s storniert", gyroskop.Name))
+		return
+	}
+	b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("✅ %s: %s", gyroskop.Name, orderText))
+}
+
+// handlePrivateCancel cancels the order in the gyroskop chosen via deep link
+func (b *Bot) handlePrivateCancel(ctx context.Context, message *tgbotapi.Message) {
+	gyroskop, ok := b.chosenGyroskop(ctx, message)
+	if !ok {
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if b.placePrivateOrder(ctx, message, gyroskop, map[string]int{}) {
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("❌ Bestellung bei %s storniert", gyroskop.Name))
+	}
+}
+
+// chosenGyroskop returns the gyroskop chosen via deep link and tells the user how to choose one if there is none
+func (b *Bot) chosenGyroskop(ctx context.Context, message *tgbotapi.Message) (*database.Gyroskop, bool) {
+	if gyroskopID, ok := b.private.chosen(message.From.ID); ok {
+		if gyroskop, exists := b.findActiveGyroskop(gyroskopID); exists {
+			return gyroskop, true
+		}
+	}
+	b.sendMessage(ctx, message.Chat.ID, "ℹ️ Wähle zuerst über den Button „💬 Privat bestellen“ unter einer Gyroskop-Nachricht aus, wofür du bestellen willst. /meine zeigt deine offenen Bestellungen.")
+	return nil, false
+}
+
+// placePrivateOrder places an order written in a private chat and tells the user if that failed
+func (b *Bot) placePrivateOrder(ctx context.Context, message *tgbotapi.Message, gyroskop *database.Gyroskop, quantities map[string]int) bool {
+	chatID := message.Chat.ID
+	err := b.placeMessageOrder(ctx, gyroskop, message, quantities, "private")
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrGyroskopExpired):
+		b.sendMessage(ctx, chatID, "⏰ Das Gyroskop ist bereits abgelaufen!")
+	case errors.Is(err, ErrInvalidOrder):
+		b.sendMessage(ctx, chatID, "⚠️ Ungültige Bestellung")
+	default:
+		b.log.ErrorContext(ctx, "Error adding order", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Bestellen")
+	}
+	return false
+}
+
+// handlePrivateCallback handles the order buttons of the private order message
+func (b *Bot) handlePrivateCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	idPart, action, found := strings.Cut(strings.TrimPrefix(query.Data, privateCallbackPrefix), ":")
+	gyroskopID, err := strconv.Atoi(idPart)
+	if !found || err != nil {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Callback-Daten")
+		return
+	}
+
+	gyroskop, exists := b.findActiveGyroskop(gyroskopID)
+	if !exists {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Gyroskop ist bereits geschlossen")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if !b.mayOrderPrivately(ctx, query.From.ID, gyroskop) {
+		b.answerCallbackQuery(ctx, query.ID, "⚠️ Du bist nicht in der Gruppe dieses Gyroskops!")
+		return
+	}
+	// Using the buttons of a gyroskop also chooses it for text orders
+	b.private.choose(query.From.ID, gyroskop.ID)
+
+	if action == reorderAction {
+		if b.answerReorderCallback(ctx, query, gyroskop, "private") {
+			b.refreshPrivateOrderMessage(ctx, query, gyroskop)
+		}
+		return
+	}
+
+	quantities := map[string]int{}
+	responseText := "❌ Bestellung storniert"
+	if action != "0" {
+		parts := strings.Split(action, "_")
+		if len(parts) != 2 {
+			b.answerCallbackQuery(ctx, query.ID, "❌ Ungültiges Format")
+			return
+		}
+		optionIndex, err := strconv.Atoi(parts[0])
+		if err != nil || optionIndex < 0 || optionIndex >= len(gyroskop.FoodOptions) {
+			b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Option")
+			return
+		}
+		quantity, err := strconv.Atoi(parts[1])
+		if err != nil {
+			b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Anzahl")
+			return
+		}
+
+		if existingOrder, err := b.db.GetOrder(ctx, gyroskop.ID, query.From.ID); err == nil && existingOrder.Quantities != nil {
+			quantities = existingOrder.Quantities
+		}
+		selectedOption := gyroskop.FoodOptions[optionIndex]
+		quantities[selectedOption] = quantity
+		responseText = fmt.Sprintf("✅ %d %s", quantity, selectedOption)
+	}
+
+	err = b.placeOrder(ctx, gyroskop, query.From, quantities, "private")
+	switch {
+	case errors.Is(err, ErrGyroskopExpired):
+		b.answerCallbackQuery(ctx, query.ID, "⏰ Das Gyroskop ist bereits abgelaufen!")
+		return
+	case errors.Is(err, ErrInvalidOrder):
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Anzahl")
+		return
+	case err != nil:
+		b.log.ErrorContext(ctx, "Error adding order", "error", err)
+		b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Bestellen")
+		return
+	}
+	b.answerCallbackQuery(ctx, query.ID, responseText)
+	b.refreshPrivateOrderMessage(ctx, query, gyroskop)
+}
+
+// refreshPrivateOrderMessage shows the changed order in the private message of a callback
+func (b *Bot) refreshPrivateOrderMessage(ctx context.Context, query *tgbotapi.CallbackQuery, gyroskop *database.Gyroskop) {
+	chatID, messageID := query.Message.Chat.ID, query.Message.MessageID
+	b.outbox.Edit(ctx, chatID, messageID, func(ctx context.Context) (tgbotapi.Chattable, error) {
+		text, keyboard, err := b.privateOrderMessage(ctx, gyroskop, query.From.ID)
+		if err != nil {
+			return nil, err
+		}
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		edit.ParseMode = messageFormat.ParseMode()
+		edit.ReplyMarkup = &keyboard
+		return edit, nil
+	})
+}
+
+// mayOrderPrivately checks whether a user is in the chat of a gyroskop
+func (b *Bot) mayOrderPrivately(ctx context.Context, userID int64, gyroskop *database.Gyroskop) bool {
+	if b.private.isMember(userID, gyroskop.ID) {
+		return true
+	}
+	if !b.isChatMember(ctx, gyroskop.ChatID, userID) {
+		return false
+	}
+	b.private.addMember(userID, gyroskop.ID)
+	return true
+}
+
+// privateOrderMessage returns the text and buttons of the private order message of a user
+func (b *Bot) privateOrderMessage(ctx context.Context, gyroskop *database.Gyroskop, userID int64) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	var quantities map[string]int
+	order, err := b.db.GetOrder(ctx, gyroskop.ID, userID)
+	if err == nil {
+		quantities = order.Quantities
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", tgbotapi.InlineKeyboardMarkup{}, fmt.Errorf("loading order: %w", err)
+	}
+
+	text := b.formatPrivateOrder(gyroskop, b.chatTitle(ctx, gyroskop.ChatID), quantities)
+	prefix := fmt.Sprintf("%s%d:", privateCallbackPrefix, gyroskop.ID)
+	keyboard := b.foodOptionsKeyboard(gyroskop.FoodOptions, b.chatSettings(ctx, gyroskop.ChatID).quantityButtons(), prefix)
+	return text, keyboard, nil
+}
+
+// formatPrivateOrder formats the private order message of a user
+func (b *Bot) formatPrivateOrder(gyroskop *database.Gyroskop, chatTitle string, quantities map[string]int) string {
+	deadlineLocal := gyroskop.Deadline.In(b.defaults.Location)
+	lines := []render.Line{
+		{render.Plain("🥙 "), render.Bold(gyroskop.Name), render.Plain(" in " + chatTitle)},
+		{render.Plain(fmt.Sprintf("⏰ Deadline: %s Uhr", deadlineLocal.Format("15:04")))},
+		nil,
+	}
+
+	if orderText := b.formatOrderQuantities(quantities, gyroskop.FoodOptions); orderText != "" {
+		lines = append(lines, render.Line{render.Plain("🧾 Deine Bestellung: " + orderText)})
+	} else {
+		lines = append(lines, render.Line{render.Plain("🧾 Du hast noch nichts bestellt")})
+	}
+
+	var examples []string
+	for _, option := range gyroskop.FoodOptions {
+		examples = append(examples, fmt.Sprintf("'2 %s'", strings.ToLower(option)))
+	}
+	lines = append(lines, nil, render.Line{render.Plain(fmt.Sprintf("Schreibe %s oder nutze die Buttons. '0' storniert deine Bestellung.", strings.Join(examples, ", ")))})
+	return messageFormat.Lines(lines...)
+}
+
+// handleMyOrders lists the orders of the user in open gyroskops of all groups
+func (b *Bot) handleMyOrders(ctx context.Context, message *tgbotapi.Message) {
+	orders, err := b.db.GetOpenOrdersByUser(ctx, message.From.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Bestellungen", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Bestellungen")
+		return
+	}
+
+	titles := make(map[int64]string)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, order := range orders {
+		if _, ok := titles[order.Gyroskop.ChatID]; !ok {
+			titles[order.Gyroskop.ChatID] = b.chatTitle(ctx, order.Gyroskop.ChatID)
+		}
+		if link := b.deepLink(order.Gyroskop.ID); link != "" {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonURL("✏️ "+order.Gyroskop.Name+" ändern", link),
+			))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, b.formatMyOrders(orders, titles))
+	msg.ParseMode = messageFormat.ParseMode()
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	if _, err := b.send(ctx, message.Chat.ID, msg); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Senden der Nachricht", "error", err)
+	}
+}
+
+// formatMyOrders formats the open orders of a user; titles maps chat IDs to group names
+func (b *Bot) formatMyOrders(orders []database.UserOrder, titles map[int64]string) string {
+	if len(orders) == 0 {
+		return messageFormat.Escape("📭 Du hast gerade keine offenen Bestellungen")
+	}
+
+	lines := []render.Line{{render.Plain("🧾 "), render.Bold("Deine offenen Bestellungen")}, nil}
+	for _, order := range orders {
+		deadlineLocal := order.Gyroskop.Deadline.In(b.defaults.Location)
+		lines = append(lines, render.Line{
+			render.Plain("• "),
+			render.Bold(order.Gyroskop.Name),
+			render.Plain(fmt.Sprintf(" in %s (bis %s Uhr): %s",
+				titles[order.Gyroskop.ChatID],
+				deadlineLocal.Format("15:04"),
+				b.formatOrderQuantities(order.Order.Quantities, order.Gyroskop.FoodOptions),
+			)),
+		})
+	}
+	return messageFormat.Lines(lines...)
+}
+
+// handlePrivateSummaries shows or changes whether the user gets private summaries
+func (b *Bot) handlePrivateSummaries(ctx context.Context, message *tgbotapi.Message, args string) {
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		current, err := b.db.GetPrivateSummaries(ctx, message.From.ID)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Laden der Benachrichtigungen", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Benachrichtigungen")
+			return
+		}
+		state := "aus"
+		if current {
+			state = "an"
+		}
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("📬 Private Übersichten sind %s. Ändern mit /benachrichtigungen an oder /benachrichtigungen aus", state))
+		return
+	case "an":
+		enabled = true
+	case "aus":
+		enabled = false
+	default:
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /benachrichtigungen [an|aus]")
+		return
+	}
+
+	if err := b.db.SetPrivateSummaries(ctx, message.From.ID, enabled); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Speichern der Benachrichtigungen", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Speichern der Benachrichtigungen")
+		return
+	}
+	if enabled {
+		b.sendMessage(ctx, message.Chat.ID, "📬 Du bekommst die finale Übersicht jedes Gyroskops, bei dem du bestellt hast")
+	} else {
+		b.sendMessage(ctx, message.Chat.ID, "📭 Du bekommst keine privaten Übersichten mehr")
+	}
+}
+
+// sendPrivateSummaries sends the final summary of a closed gyroskop to everyone
+// who ordered and gets private summaries
+func (b *Bot) sendPrivateSummaries(ctx context.Context, gyroskop *database.Gyroskop, orders []database.Order) {
+	b.private.forget(gyroskop.ID)
+
+	recipients, err := b.db.GetSummaryRecipients(ctx, gyroskop.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Empfänger", "error", err)
+		return
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	summary := withTitle(b.summaryMessage(ctx, gyroskop, orders, false), "📬 Übersicht aus "+b.chatTitle(ctx, gyroskop.ChatID))
+	for _, userID := range recipients {
+		// The creator of a hidden gyroskop already got the full summary
+		if gyroskop.Hidden && userID == gyroskop.CreatedBy {
+			continue
+		}
+
+		text := summary
+		for _, order := range orders {
+			if order.UserID == userID {
+				text += "\n\n" + messageFormat.Escape("🧾 Deine Bestellung: "+b.formatOrderQuantities(order.Quantities, gyroskop.FoodOptions))
+				break
+			}
+		}
+
+		msg := tgbotapi.NewMessage(userID, text)
+		msg.ParseMode = messageFormat.ParseMode()
+		_, err := b.send(ctx, userID, msg)
+		var apiErr *tgbotapi.Error
+		switch {
+		case err == nil:
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden:
+			// The user blocked the bot
+			b.log.InfoContext(ctx, "Forgetting private chat", "recipient_id", userID, "error", err)
+			if err := b.db.RemovePrivateChat(ctx, userID); err != nil {
+				b.log.ErrorContext(ctx, "Fehler beim Entfernen des privaten Chats", "error", err)
+			}
+		default:
+			b.log.ErrorContext(ctx, "Fehler beim Senden der privaten Übersicht", "recipient_id", userID, "error", err)
+		}
+	}
+}
+
+// privateChatUnavailable reports whether a private message failed because the
+// user never started a private chat with the bot or blocked it, as opposed to
+// e.g. a network error
+func privateChatUnavailable(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusForbidden ||
+		(apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "chat not found"))
+}
+
+// chatTitle returns the title of a group. Titles are remembered from the
+// messages of the group and otherwise loaded from Telegram.
+func (b *Bot) chatTitle(ctx context.Context, chatID int64) string {
+	if title, ok := b.chatTitles.Load(chatID); ok {
+		return title.(string)
+	}
+
+	if b.outbox != nil {
+		var chat tgbotapi.Chat
+		resp, err := b.request(ctx, 0, tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+		if err == nil {
+			err = json.Unmarshal(resp.Result, &chat)
+		}
+		if err == nil {
+			b.chatTitles.Store(chatID, chat.Title)
+			return chat.Title
+		}
+		b.log.ErrorContext(ctx, "Fehler beim Laden des Chats", "error", err)
+	}
+	return "der Gruppe"
+}
+
+// deepLink returns the link to order privately in a gyroskop, or "" if the bot username is unknown
+func (b *Bot) deepLink(gyroskopID int) string {
+	if b.api == nil || b.api.Self.UserName == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", b.api.Self.UserName, deepLinkPrefix, gyroskopID)
+}
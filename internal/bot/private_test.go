@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestPrivateSessions(t *testing.T) {
+	var sessions privateSessions
+
+	if _, ok := sessions.chosen(1); ok {
+		t.Fatal("chosen() on empty sessions should miss")
+	}
+
+	sessions.choose(1, 10)
+	sessions.choose(2, 10)
+	sessions.choose(2, 20)
+	if gyroskopID, ok := sessions.chosen(2); !ok || gyroskopID != 20 {
+		t.Errorf("chosen(2) = %d, %v, want 20, true", gyroskopID, ok)
+	}
+
+	sessions.addMember(1, 10)
+	sessions.addMember(2, 20)
+	if !sessions.isMember(1, 10) || sessions.isMember(1, 20) {
+		t.Error("isMember() should only report added members")
+	}
+
+	// Closing gyroskop 10 forgets it, but not gyroskop 20
+	sessions.forget(10)
+	if _, ok := sessions.chosen(1); ok {
+		t.Error("chosen(1) should miss after the gyroskop was forgotten")
+	}
+	if sessions.isMember(1, 10) {
+		t.Error("isMember(1, 10) should be false after the gyroskop was forgotten")
+	}
+	if gyroskopID, ok := sessions.chosen(2); !ok || gyroskopID != 20 || !sessions.isMember(2, 20) {
+		t.Error("Forgetting a gyroskop should keep the others")
+	}
+}
+
+func TestFoodOptionsKeyboardPrefix(t *testing.T) {
+	b := newTestBot()
+
+	keyboard := b.foodOptionsKeyboard([]string{"Fleisch", "Vegetarisch"}, []int{1, 2}, "p42:")
+	if got := *keyboard.InlineKeyboard[3][1].CallbackData; got != "p42:1_2" {
+		t.Errorf("callback data = %q, want p42:1_2", got)
+	}
+	if got := *keyboard.InlineKeyboard[4][0].CallbackData; got != "p42:0" {
+		t.Errorf("cancel callback data = %q, want p42:0", got)
+	}
+	if got := *keyboard.InlineKeyboard[4][1].CallbackData; got != "p42:r" {
+		t.Errorf("reorder callback data = %q, want p42:r", got)
+	}
+
+	// Without the bot username there is no deep link to order privately
+	gyroskop := &database.Gyroskop{ID: 42, FoodOptions: []string{"Fleisch"}}
+	if link := b.deepLink(gyroskop.ID); link != "" {
+		t.Errorf("deepLink() = %q, want empty", link)
+	}
+
+	gyroskop.ChatID = -100
+	b.settings.set(gyroskop.ChatID, b.defaults.settings(nil))
+	keyboard = b.gyroskopKeyboard(context.Background(), gyroskop)
+	if got := *keyboard.InlineKeyboard[1][0].CallbackData; got != "g0_1" {
+		t.Errorf("gyroskopKeyboard() callback data = %q, want g0_1", got)
+	}
+}
+
+func TestFormatPrivateOrder(t *testing.T) {
+	b := newTestBot()
+	gyroskop := &database.Gyroskop{
+		Name:        "Pizza",
+		FoodOptions: []string{"Margherita", "Salami"},
+		Deadline:    time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC),
+	}
+
+	text := b.formatPrivateOrder(gyroskop, "Büro", map[string]int{"Salami": 2})
+	for _, want := range []string{"<b>Pizza</b> in Büro", "12:30 Uhr", "Deine Bestellung: 2 Salami", "'2 margherita'"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatPrivateOrder() = %q, missing %q", text, want)
+		}
+	}
+
+	text = b.formatPrivateOrder(gyroskop, "Büro", nil)
+	if !strings.Contains(text, "noch nichts bestellt") {
+		t.Errorf("formatPrivateOrder() without order = %q", text)
+	}
+}
+
+func TestFormatMyOrders(t *testing.T) {
+	b := newTestBot()
+
+	if text := b.formatMyOrders(nil, nil); !strings.Contains(text, "keine offenen Bestellungen") {
+		t.Errorf("formatMyOrders(nil) = %q", text)
+	}
+
+	orders := []database.UserOrder{
+		{
+			Gyroskop: database.Gyroskop{ChatID: 1, Name: "Gyros", FoodOptions: []string{"Fleisch", "Vegetarisch"}, Deadline: time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+			Order:    database.Order{Quantities: map[string]int{"Fleisch": 1, "Vegetarisch": 2}},
+		},
+		{
+			Gyroskop: database.Gyroskop{ChatID: 2, Name: "Pizza", FoodOptions: []string{"Salami"}, Deadline: time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)},
+			Order:    database.Order{Quantities: map[string]int{"Salami": 1}},
+		},
+	}
+	text := b.formatMyOrders(orders, map[int64]string{1: "Büro", 2: "WG"})
+	for _, want := range []string{
+		"• <b>Gyros</b> in Büro (bis 12:00 Uhr): 1 Fleisch, 2 Vegetarisch",
+		"• <b>Pizza</b> in WG (bis 18:00 Uhr): 1 Salami",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatMyOrders() = %q, missing %q", text, want)
+		}
+	}
+}
+
+func TestPrivateChatUnavailable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, true},
+		{&tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, true},
+		{&tgbotapi.Error{Code: 400, Message: "Bad Request: can't parse entities"}, false},
+		{errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		if got := privateChatUnavailable(tt.err); got != tt.want {
+			t.Errorf("privateChatUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+const (
+	// reorderAction is the callback action of the "Wie immer" button, appended
+	// to the prefix of the order keyboard (gr, p<id>:r)
+	reorderAction = "r"
+	// reorderText is the text command repeating the previous order
+	reorderText = "wie immer"
+)
+
+var (
+	errNoPreviousOrder = errors.New("no previous order")
+	errNothingMapped   = errors.New("no option of the previous order is available")
+)
+
+// mapPreviousOrder maps a previous order onto the options of a gyroskop by
+// name, ignoring case. Quantities are capped at maxQuantity. Options that are
+// not available any more are returned sorted as unmapped.
+func mapPreviousOrder(previous map[string]int, foodOptions []string, maxQuantity int) (map[string]int, []string) {
+	names := make([]string, 0, len(previous))
+	for option, qty := range previous {
+		if qty > 0 {
+			names = append(names, option)
+		}
+	}
+	sort.Strings(names)
+
+	quantities := make(map[string]int)
+	var unmapped []string
+	for _, name := range names {
+		found := false
+		for _, option := range foodOptions {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(option)) {
+				quantities[option] = min(quantities[option]+previous[name], maxQuantity)
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmapped = append(unmapped, name)
+		}
+	}
+	return quantities, unmapped
+}
+
+// repeatOrder replaces the order of a user with their latest order in an
+// earlier gyroskop with the same name or restaurant. It returns the placed
+// quantities and the items that could not be mapped.
+func (b *Bot) repeatOrder(ctx context.Context, gyroskop *database.Gyroskop, user *tgbotapi.User, source string) (map[string]int, []string, error) {
+	previous, err := b.db.GetPreviousOrder(ctx, gyroskop, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errNoPreviousOrder
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quantities, unmapped := mapPreviousOrder(previous.Quantities, gyroskop.FoodOptions, b.chatSettings(ctx, gyroskop.ChatID).MaxQuantity)
+	if len(quantities) == 0 {
+		return nil, unmapped, errNothingMapped
+	}
+	if err := b.placeOrder(ctx, gyroskop, user, quantities, source); err != nil {
+		return nil, nil, err
+	}
+	return quantities, unmapped, nil
+}
+
+// reorderReply describes the outcome of repeatOrder to the user. The second
+// return value is false for errors that are not about the previous order.
+func (b *Bot) reorderReply(gyroskop *database.Gyroskop, quantities map[string]int, unmapped []string, err error) (string, bool) {
+	var text string
+	switch {
+	case err == nil:
+		text = fmt.Sprintf("🔁 Wie immer: %s", b.formatOrderQuantities(quantities, gyroskop.FoodOptions))
+	case errors.Is(err, errNoPreviousOrder):
+		return fmt.Sprintf("🔁 Du hast noch bei keinem früheren %s bestellt", gyroskop.Name), true
+	case errors.Is(err, errNothingMapped):
+		text = "⚠️ Von deiner letzten Bestellung gibt es diesmal nichts"
+	case errors.Is(err, ErrGyroskopExpired):
+		return "⏰ Das Gyroskop ist bereits abgelaufen!", true
+	default:
+		return "❌ Fehler beim Bestellen", false
+	}
+
+	if len(unmapped) > 0 {
+		text += fmt.Sprintf("\n⚠️ Nicht mehr verfügbar: %s", strings.Join(unmapped, ", "))
+	}
+	return text, true
+}
+
+// answerReorderCallback repeats the previous order for a "Wie immer" button
+// and reports whether the order was placed
+func (b *Bot) answerReorderCallback(ctx context.Context, query *tgbotapi.CallbackQuery, gyroskop *database.Gyroskop, source string) bool {
+	quantities, unmapped, err := b.repeatOrder(ctx, gyroskop, query.From, source)
+	text, ok := b.reorderReply(gyroskop, quantities, unmapped, err)
+	if !ok {
+		b.log.ErrorContext(ctx, "Fehler beim Wiederholen der Bestellung", "error", err)
+	}
+	// Unmapped items don't fit into a short notification
+	if len(unmapped) > 0 {
+		b.answerCallbackAlert(ctx, query.ID, text)
+	} else {
+		b.answerCallbackQuery(ctx, query.ID, text)
+	}
+	return err == nil
+}
+
+// handleReorderCallback handles the "Wie immer" button of the gyroskop message
+func (b *Bot) handleReorderCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	gyroskop, exists := b.getActiveGyroskop(query.Message.Chat.ID)
+	if !exists {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Kein aktives Gyroskop")
+		return
+	}
+	ctx = withGyroskop(ctx, gyroskop)
+
+	if b.answerReorderCallback(ctx, query, gyroskop, "reorder") {
+		b.updateGyroskopMessage(ctx, gyroskop, query.Message)
+	}
+}
+
+// handleReorderText handles "wie immer" written in the group. The previous
+// order of hidden gyroskops is not repeated into the group.
+func (b *Bot) handleReorderText(ctx context.Context, message *tgbotapi.Message, gyroskop *database.Gyroskop) {
+	quantities, unmapped, err := b.repeatOrder(ctx, gyroskop, message.From, "reorder")
+	text, ok := b.reorderReply(gyroskop, quantities, unmapped, err)
+	if !ok {
+		b.log.ErrorContext(ctx, "Fehler beim Wiederholen der Bestellung", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, text)
+		return
+	}
+
+	if gyroskop.Hidden {
+		if err != nil && !errors.Is(err, errNothingMapped) {
+			b.sendMessage(ctx, message.Chat.ID, text)
+		}
+		return
+	}
+	if err != nil {
+		b.sendMessage(ctx, message.Chat.ID, text)
+		return
+	}
+	if len(unmapped) == 0 && !b.chatSettings(ctx, gyroskop.ChatID).Confirmations {
+		return
+	}
+	text = fmt.Sprintf("🔁 %s: %s", b.getUserName(message.From), b.formatOrderQuantities(quantities, gyroskop.FoodOptions))
+	if len(unmapped) > 0 {
+		text += fmt.Sprintf("\n⚠️ Nicht mehr verfügbar: %s", strings.Join(unmapped, ", "))
+	}
+	b.sendMessage(ctx, message.Chat.ID, text)
+}
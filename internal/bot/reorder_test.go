@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestMapPreviousOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		previous     map[string]int
+		options      []string
+		want         map[string]int
+		wantUnmapped []string
+	}{
+		{
+			name:     "same options",
+			previous: map[string]int{"Fleisch": 2, "Vegetarisch": 1},
+			options:  []string{"Fleisch", "Vegetarisch"},
+			want:     map[string]int{"Fleisch": 2, "Vegetarisch": 1},
+		},
+		{
+			name:     "ignores case",
+			previous: map[string]int{"fleisch": 1},
+			options:  []string{"Fleisch"},
+			want:     map[string]int{"Fleisch": 1},
+		},
+		{
+			name:         "missing options",
+			previous:     map[string]int{"Salami": 1, "Hawaii": 2, "Margherita": 1},
+			options:      []string{"Margherita", "Funghi"},
+			want:         map[string]int{"Margherita": 1},
+			wantUnmapped: []string{"Hawaii", "Salami"},
+		},
+		{
+			name:     "skips zero quantities",
+			previous: map[string]int{"Fleisch": 0, "Pommes": 0, "Vegetarisch": 1},
+			options:  []string{"Fleisch", "Vegetarisch"},
+			want:     map[string]int{"Vegetarisch": 1},
+		},
+		{
+			name:     "caps quantities",
+			previous: map[string]int{"Fleisch": 12},
+			options:  []string{"Fleisch"},
+			want:     map[string]int{"Fleisch": 10},
+		},
+		{
+			name:         "nothing available",
+			previous:     map[string]int{"Salami": 1},
+			options:      []string{"Fleisch"},
+			want:         map[string]int{},
+			wantUnmapped: []string{"Salami"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unmapped := mapPreviousOrder(tt.previous, tt.options, 10)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapPreviousOrder() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(unmapped, tt.wantUnmapped) {
+				t.Errorf("mapPreviousOrder() unmapped = %v, want %v", unmapped, tt.wantUnmapped)
+			}
+		})
+	}
+}
+
+func TestReorderReply(t *testing.T) {
+	b := newTestBot()
+	gyroskop := &database.Gyroskop{Name: "Pizza", FoodOptions: []string{"Margherita", "Salami"}}
+
+	tests := []struct {
+		name       string
+		quantities map[string]int
+		unmapped   []string
+		err        error
+		want       string
+		wantOK     bool
+	}{
+		{"placed", map[string]int{"Salami": 2}, nil, nil, "🔁 Wie immer: 2 Salami", true},
+		{"placed with missing items", map[string]int{"Salami": 1}, []string{"Hawaii"}, nil, "🔁 Wie immer: 1 Salami\n⚠️ Nicht mehr verfügbar: Hawaii", true},
+		{"no previous order", nil, nil, errNoPreviousOrder, "🔁 Du hast noch bei keinem früheren Pizza bestellt", true},
+		{"nothing mapped", nil, []string{"Hawaii"}, errNothingMapped, "⚠️ Von deiner letzten Bestellung gibt es diesmal nichts\n⚠️ Nicht mehr verfügbar: Hawaii", true},
+		{"expired", nil, nil, ErrGyroskopExpired, "⏰ Das Gyroskop ist bereits abgelaufen!", true},
+		{"database error", nil, nil, errors.New("connection refused"), "❌ Fehler beim Bestellen", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := b.reorderReply(gyroskop, tt.quantities, tt.unmapped, tt.err)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("reorderReply() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
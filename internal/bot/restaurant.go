@@ -0,0 +1,474 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/export"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Restaurant directory
+//
+// Every chat has its own list of restaurants with contact data, opening hours
+// and a menu. /gyroskop 30min, @Luigi opens a gyroskop with the menu of Luigi
+// as options, warns if Luigi is closed at the deadline and shows the contact
+// data in the final summary and on the order sheet.
+
+// restaurantNamePattern restricts names to a single word so they can be referenced as @Name
+var restaurantNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,32}$`)
+
+// Fields of /restaurant <Name> <Feld> <Wert>
+const (
+	restaurantPhone   = "telefon"
+	restaurantAddress = "adresse"
+	restaurantURL     = "web"
+	restaurantHours   = "zeiten"
+	restaurantMenu    = "karte"
+)
+
+var errInvalidMenu = errors.New("invalid menu")
+
+// pricePattern matches prices like 8,50 or 8.50€ or 10€ or -3,5
+var pricePattern = regexp.MustCompile(`^(-?)(\d{1,5})(?:[.,](\d{1,2}))?(€?)$`)
+
+// parseRestaurantRef removes the @Name part from the arguments of /gyroskop.
+// name is empty if no restaurant was given.
+func parseRestaurantRef(args string) (rest string, name string) {
+	parts := strings.Split(args, ",")
+	kept := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if strings.HasPrefix(trimmed, "@") && name == "" {
+			name = strings.TrimPrefix(trimmed, "@")
+			continue
+		}
+		kept = append(kept, trimmed)
+	}
+	if name == "" {
+		return args, ""
+	}
+	return strings.Join(kept, ", "), name
+}
+
+// parseMenu parses a menu of comma-separated dishes with optional prices
+// Format: Margherita 8,50, Salami 9,50€, Hawaii 10€, Calzone
+func parseMenu(input string) ([]database.MenuItem, error) {
+	var menu []database.MenuItem
+	seen := make(map[string]bool)
+	for _, part := range splitMenu(input) {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+
+		item := database.MenuItem{Name: strings.Join(fields, " ")}
+		last := fields[len(fields)-1]
+		if last == "€" && len(fields) > 2 {
+			fields = fields[:len(fields)-1]
+			last = fields[len(fields)-1] + "€"
+		}
+		if price, ok := parsePrice(last, false); ok && price >= 0 && len(fields) > 1 {
+			item = database.MenuItem{Name: strings.Join(fields[:len(fields)-1], " "), Price: price}
+		}
+
+		if seen[strings.ToLower(item.Name)] {
+			return nil, fmt.Errorf("%w: %s appears twice", errInvalidMenu, item.Name)
+		}
+		seen[strings.ToLower(item.Name)] = true
+		menu = append(menu, item)
+	}
+	return menu, nil
+}
+
+// splitMenu splits a menu at commas, except decimal commas between two digits
+func splitMenu(input string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(input); i++ {
+		if input[i] != ',' {
+			continue
+		}
+		if i > 0 && i+1 < len(input) && isDigit(input[i-1]) && isDigit(input[i+1]) {
+			continue
+		}
+		parts = append(parts, input[start:i])
+		start = i + 1
+	}
+	return append(parts, input[start:])
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parsePrice parses a price into cents, see pricePattern. Unless lenient is
+// set, whole numbers need the € sign and cents two digits, so that dishes like
+// "Menü 12" or "Cola 0,5" keep their number. Amounts of /kosten are lenient.
+func parsePrice(input string, lenient bool) (int, bool) {
+	m := pricePattern.FindStringSubmatch(input)
+	if m == nil {
+		return 0, false
+	}
+	if !lenient && ((m[3] == "" && m[4] == "") || len(m[3]) == 1) {
+		return 0, false
+	}
+	euros, _ := strconv.Atoi(m[2])
+	cents, _ := strconv.Atoi(m[3])
+	if len(m[3]) == 1 {
+		cents *= 10
+	}
+	price := euros*100 + cents
+	if m[1] == "-" {
+		price = -price
+	}
+	return price, true
+}
+
+// formatPrice formats cents as euros, e.g. "8,50 €"
+func formatPrice(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d,%02d €", sign, cents/100, cents%100)
+}
+
+// withRestaurant uses the name and menu of a restaurant as defaults for /gyroskop
+func (s Settings) withRestaurant(r *database.Restaurant) Settings {
+	s.Name = r.Name
+	if len(r.Menu) > 0 {
+		s.FoodOptions = make([]string, 0, len(r.Menu))
+		for _, item := range r.Menu {
+			s.FoodOptions = append(s.FoodOptions, item.Name)
+		}
+	}
+	return s
+}
+
+// lookupRestaurant finds a restaurant of the chat by name, telling the user if it does not exist
+func (b *Bot) lookupRestaurant(ctx context.Context, chatID int64, name string) (*database.Restaurant, bool) {
+	r, err := b.db.GetRestaurantByName(ctx, chatID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("❌ Restaurant %s nicht gefunden. Alle Restaurants der Gruppe: /restaurant", name))
+		return nil, false
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden des Restaurants", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden des Restaurants")
+		return nil, false
+	}
+	return r, true
+}
+
+// setGyroskopRestaurant links a gyroskop to the restaurant it orders from
+func (b *Bot) setGyroskopRestaurant(ctx context.Context, gyroskop *database.Gyroskop, r *database.Restaurant) error {
+	if err := b.db.SetGyroskopRestaurant(ctx, gyroskop.ID, &r.ID); err != nil {
+		return err
+	}
+	gyroskop.RestaurantID = &r.ID
+	return nil
+}
+
+// gyroskopRestaurant returns the restaurant a gyroskop orders from, or nil
+func (b *Bot) gyroskopRestaurant(ctx context.Context, gyroskop *database.Gyroskop) *database.Restaurant {
+	if gyroskop.RestaurantID == nil {
+		return nil
+	}
+	r, err := b.db.GetRestaurant(ctx, *gyroskop.RestaurantID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden des Restaurants", "restaurant_id", *gyroskop.RestaurantID, "error", err)
+		return nil
+	}
+	return r
+}
+
+// restaurantContact returns the restaurant of a gyroskop for the order sheet
+func (b *Bot) restaurantContact(ctx context.Context, gyroskop *database.Gyroskop) export.Contact {
+	r := b.gyroskopRestaurant(ctx, gyroskop)
+	if r == nil {
+		return export.Contact{}
+	}
+	return export.Contact{Name: r.Name, Phone: r.Phone}
+}
+
+// warnIfClosed warns the group if the restaurant is closed at the deadline
+func (b *Bot) warnIfClosed(ctx context.Context, chatID int64, r *database.Restaurant, deadline time.Time) {
+	if r == nil || r.OpeningHours == "" {
+		return
+	}
+	hours, err := parseOpeningHours(r.OpeningHours)
+	if err != nil {
+		b.log.WarnContext(ctx, "Invalid stored opening hours", "restaurant_id", r.ID, "error", err)
+		return
+	}
+
+	deadlineLocal := deadline.In(b.defaults.Location)
+	if !hours.isOpen(deadlineLocal) {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("⚠️ %s hat um %s Uhr laut Öffnungszeiten geschlossen (%s)",
+			r.Name, deadlineLocal.Format("15:04"), hours))
+	}
+}
+
+// restaurantContact formats the contact data of a restaurant for the final summary
+func restaurantContact(r *database.Restaurant) render.Line {
+	line := render.Line{render.Plain("🏪 "), render.Bold(r.Name)}
+	if r.Phone != "" {
+		line = append(line, render.Plain("\n☎️ "+r.Phone))
+	}
+	if r.Address != "" {
+		line = append(line, render.Plain("\n📍 "+r.Address))
+	}
+	if r.URL != "" {
+		line = append(line, render.Plain("\n🌐 "+r.URL))
+	}
+	return line
+}
+
+// formatRestaurant formats all details of a restaurant for /restaurant Name
+func (b *Bot) formatRestaurant(r *database.Restaurant, now time.Time) string {
+	line := restaurantContact(r)
+
+	if r.OpeningHours != "" {
+		line = append(line, render.Plain("\n🕐 "+r.OpeningHours))
+		if hours, err := parseOpeningHours(r.OpeningHours); err == nil {
+			if hours.isOpen(now.In(b.defaults.Location)) {
+				line = append(line, render.Plain(" (jetzt geöffnet)"))
+			} else {
+				line = append(line, render.Plain(" (jetzt geschlossen)"))
+			}
+		}
+	}
+
+	if len(r.Menu) > 0 {
+		line = append(line, render.Plain("\n\n📋 "), render.Bold("Karte:"))
+		for _, item := range r.Menu {
+			line = append(line, render.Plain("\n• "+item.Name))
+			if item.Price > 0 {
+				line = append(line, render.Plain(" – "+formatPrice(item.Price)))
+			}
+		}
+	}
+
+	line = append(line, render.Plain("\n\nBestellen: /gyroskop 30min, @"+r.Name))
+	return messageFormat.Line(line)
+}
+
+// handleRestaurant manages the restaurants of the chat
+// Format:
+//
+//	/restaurant -> list all restaurants
+//	/restaurant neu Luigi -> add a restaurant
+//	/restaurant Luigi -> show a restaurant
+//	/restaurant Luigi telefon|adresse|web|zeiten|karte [Wert] -> change or clear a field
+//	/restaurant Luigi löschen -> remove a restaurant (admins and who added it)
+func (b *Bot) handleRestaurant(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.listRestaurants(ctx, chatID)
+		return
+	}
+
+	if strings.EqualFold(fields[0], "neu") {
+		if len(fields) != 2 {
+			b.sendMessage(ctx, chatID, "⚠️ Ungültiges Format. Verwende: /restaurant neu Name")
+			return
+		}
+		b.addRestaurant(ctx, message, fields[1])
+		return
+	}
+
+	r, ok := b.lookupRestaurant(ctx, chatID, strings.TrimPrefix(fields[0], "@"))
+	if !ok {
+		return
+	}
+	if len(fields) == 1 {
+		b.sendFormatted(ctx, chatID, b.formatRestaurant(r, time.Now()))
+		return
+	}
+
+	field := strings.ToLower(fields[1])
+	if field == "löschen" || field == "loeschen" {
+		b.deleteRestaurant(ctx, message, r)
+		return
+	}
+
+	if !b.mayManageRestaurants(ctx, message) {
+		return
+	}
+
+	// The value is the rest of the arguments after the field, with its original spacing
+	_, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+	_, value, _ = strings.Cut(strings.TrimSpace(value), " ")
+	if err := applyRestaurantField(r, field, strings.TrimSpace(value)); err != nil {
+		b.sendMessage(ctx, chatID, restaurantFieldUsage(field))
+		return
+	}
+
+	if err := b.db.UpdateRestaurant(ctx, r); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Speichern des Restaurants", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Speichern des Restaurants")
+		return
+	}
+	b.log.InfoContext(ctx, "Restaurant changed", "restaurant_id", r.ID, "field", field)
+	b.sendFormatted(ctx, chatID, "✅ Gespeichert!\n\n"+b.formatRestaurant(r, time.Now()))
+}
+
+// applyRestaurantField changes a field of a restaurant, an empty value clears it
+func applyRestaurantField(r *database.Restaurant, field, value string) error {
+	switch field {
+	case restaurantPhone:
+		r.Phone = value
+	case restaurantAddress:
+		r.Address = value
+	case restaurantURL:
+		if value == "" {
+			r.URL = ""
+			return nil
+		}
+		if !strings.Contains(value, "://") {
+			value = "https://" + value
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errInvalidSetting
+		}
+		r.URL = u.String()
+	case restaurantHours:
+		if value == "" {
+			r.OpeningHours = ""
+			return nil
+		}
+		hours, err := parseOpeningHours(value)
+		if err != nil {
+			return err
+		}
+		r.OpeningHours = hours.String()
+	case restaurantMenu:
+		menu, err := parseMenu(value)
+		if err != nil {
+			return err
+		}
+		r.Menu = menu
+	default:
+		return errInvalidSetting
+	}
+	return nil
+}
+
+// restaurantFieldUsage explains the format of a restaurant field
+func restaurantFieldUsage(field string) string {
+	switch field {
+	case restaurantURL:
+		return "⚠️ Ungültige Adresse. Verwende z.B.: /restaurant Luigi web https://luigi.de"
+	case restaurantHours:
+		return "⚠️ Ungültige Öffnungszeiten. Verwende z.B.: /restaurant Luigi zeiten Mo-Fr 11:30-14:30 17:30-22:00, Sa-So 17:00-23:00"
+	case restaurantMenu:
+		return "⚠️ Ungültige Karte. Verwende z.B.: /restaurant Luigi karte Margherita 8,50, Salami 9,50, Calzone 11€ (jedes Gericht nur einmal)"
+	}
+	return "⚠️ Ungültiges Format. Verwende: /restaurant Name telefon|adresse|web|zeiten|karte Wert oder /restaurant Name löschen"
+}
+
+// mayManageRestaurants checks whether the user may add and change restaurants,
+// which is allowed to everyone who may open a gyroskop
+func (b *Bot) mayManageRestaurants(ctx context.Context, message *tgbotapi.Message) bool {
+	if b.chatSettings(ctx, message.Chat.ID).OpenPermission == openByAdmins && !b.isChatAdmin(ctx, message.Chat.ID, message.From.ID) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ In dieser Gruppe können nur Admins Restaurants verwalten!")
+		return false
+	}
+	return true
+}
+
+// listRestaurants lists the restaurants of the chat
+func (b *Bot) listRestaurants(ctx context.Context, chatID int64) {
+	restaurants, err := b.db.GetRestaurantsByChat(ctx, chatID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Restaurants", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Restaurants")
+		return
+	}
+	if len(restaurants) == 0 {
+		b.sendMessage(ctx, chatID, "🏪 Noch keine Restaurants in dieser Gruppe. Anlegen mit /restaurant neu Name")
+		return
+	}
+
+	lines := []render.Line{{render.Plain("🏪 "), render.Bold("Restaurants:")}, nil}
+	for _, r := range restaurants {
+		text := "• @" + r.Name
+		if r.Phone != "" {
+			text += " – ☎️ " + r.Phone
+		}
+		if len(r.Menu) > 0 {
+			text += fmt.Sprintf(" (%d Gerichte)", len(r.Menu))
+		}
+		lines = append(lines, render.Line{render.Plain(text)})
+	}
+	lines = append(lines, nil,
+		render.Line{render.Plain("Details: /restaurant Name")},
+		render.Line{render.Plain("Bestellen: /gyroskop 30min, @Name")},
+	)
+	b.sendFormatted(ctx, chatID, messageFormat.Lines(lines...))
+}
+
+// addRestaurant adds a restaurant to the chat
+func (b *Bot) addRestaurant(ctx context.Context, message *tgbotapi.Message, name string) {
+	chatID := message.Chat.ID
+	name = strings.TrimPrefix(name, "@")
+	if !restaurantNamePattern.MatchString(name) {
+		b.sendMessage(ctx, chatID, "⚠️ Der Name muss ein Wort sein (Buchstaben, Ziffern, - und _), damit man ihn mit @Name angeben kann")
+		return
+	}
+	if !b.mayManageRestaurants(ctx, message) {
+		return
+	}
+
+	r := &database.Restaurant{ChatID: chatID, Name: name, CreatedBy: message.From.ID}
+	added, err := b.db.AddRestaurant(ctx, r)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Anlegen des Restaurants", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Anlegen des Restaurants")
+		return
+	}
+	if !added {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("⚠️ Es gibt schon ein Restaurant %s", name))
+		return
+	}
+	b.log.InfoContext(ctx, "Restaurant added", "restaurant_id", r.ID, "name", name)
+
+	b.sendFormatted(ctx, chatID, messageFormat.Lines(
+		render.Line{render.Plain("🏪 "), render.Bold(name), render.Plain(" angelegt!")},
+		nil,
+		render.Line{render.Plain(fmt.Sprintf("/restaurant %[1]s telefon 0711 123456\n"+
+			"/restaurant %[1]s adresse Hauptstraße 1\n"+
+			"/restaurant %[1]s web https://example.com\n"+
+			"/restaurant %[1]s zeiten Mo-Fr 11:30-22:00, Sa-So 17:00-23:00\n"+
+			"/restaurant %[1]s karte Margherita 8,50, Salami 9,50", name))},
+	))
+}
+
+// deleteRestaurant removes a restaurant (admins and who added it)
+func (b *Bot) deleteRestaurant(ctx context.Context, message *tgbotapi.Message, r *database.Restaurant) {
+	chatID := message.Chat.ID
+	if r.CreatedBy != message.From.ID && !b.isChatAdmin(ctx, chatID, message.From.ID) {
+		b.sendMessage(ctx, chatID, "⚠️ Nur wer das Restaurant angelegt hat und Admins können es löschen!")
+		return
+	}
+
+	if err := b.db.DeleteRestaurant(ctx, r.ID); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Löschen des Restaurants", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Löschen des Restaurants")
+		return
+	}
+	b.log.InfoContext(ctx, "Restaurant deleted", "restaurant_id", r.ID)
+	b.sendMessage(ctx, chatID, fmt.Sprintf("🗑 Restaurant %s gelöscht", r.Name))
+}
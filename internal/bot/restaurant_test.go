@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestParseRestaurantRef(t *testing.T) {
+	tests := []struct {
+		args     string
+		wantArgs string
+		wantName string
+	}{
+		{"", "", ""},
+		{"30min, Pizza, Salami", "30min, Pizza, Salami", ""},
+		{"30min, @Luigi", "30min", "Luigi"},
+		{"@Luigi, Pizza, Margherita", "Pizza, Margherita", "Luigi"},
+	}
+
+	for _, tt := range tests {
+		args, name := parseRestaurantRef(tt.args)
+		if args != tt.wantArgs || name != tt.wantName {
+			t.Errorf("parseRestaurantRef(%q) = %q, %q, want %q, %q", tt.args, args, name, tt.wantArgs, tt.wantName)
+		}
+	}
+}
+
+func TestParseMenu(t *testing.T) {
+	menu, err := parseMenu("Margherita 8,50, Salami 9.50€,Hawaii 10 €, Menü 12, Cola 0,5, Calzone")
+	if err != nil {
+		t.Fatalf("parseMenu() error = %v", err)
+	}
+	want := []database.MenuItem{
+		{Name: "Margherita", Price: 850},
+		{Name: "Salami", Price: 950},
+		{Name: "Hawaii", Price: 1000},
+		{Name: "Menü 12"},
+		{Name: "Cola 0,5"},
+		{Name: "Calzone"},
+	}
+	if !reflect.DeepEqual(menu, want) {
+		t.Errorf("parseMenu() = %+v, want %+v", menu, want)
+	}
+
+	if _, err := parseMenu("Salami 9,50, salami 10,00"); err == nil {
+		t.Error("parseMenu() with a duplicate dish should fail")
+	}
+	if menu, err := parseMenu(""); err != nil || menu != nil {
+		t.Errorf("parseMenu(\"\") = %v, %v, want an empty menu", menu, err)
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		input   string
+		lenient bool
+		want    int
+		wantOK  bool
+	}{
+		{"8,50", false, 850, true},
+		{"9.50€", false, 950, true},
+		{"10€", false, 1000, true},
+		{"12", false, 0, false},
+		{"0,5", false, 0, false},
+		{"12", true, 1200, true},
+		{"3,5", true, 350, true},
+		{"-5", true, -500, true},
+		{"-2,50€", true, -250, true},
+		{"8,505", true, 0, false},
+		{"abc", true, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePrice(tt.input, tt.lenient)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parsePrice(%q, %v) = %d, %v, want %d, %v", tt.input, tt.lenient, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestFormatPrice(t *testing.T) {
+	tests := map[int]string{0: "0,00 €", 5: "0,05 €", 850: "8,50 €", 12345: "123,45 €", -250: "-2,50 €"}
+	for cents, want := range tests {
+		if got := formatPrice(cents); got != want {
+			t.Errorf("formatPrice(%d) = %q, want %q", cents, got, want)
+		}
+	}
+}
+
+func TestApplyRestaurantField(t *testing.T) {
+	r := &database.Restaurant{Name: "Luigi"}
+
+	if err := applyRestaurantField(r, restaurantURL, "luigi.de/karte"); err != nil || r.URL != "https://luigi.de/karte" {
+		t.Errorf("URL = %q, %v", r.URL, err)
+	}
+	if err := applyRestaurantField(r, restaurantURL, "ftp://luigi.de"); err == nil {
+		t.Error("A non-HTTP URL should be rejected")
+	}
+	if err := applyRestaurantField(r, restaurantHours, "mo-fr 11:00-22:00"); err != nil || r.OpeningHours != "Mo-Fr 11:00-22:00" {
+		t.Errorf("OpeningHours = %q, %v", r.OpeningHours, err)
+	}
+	if err := applyRestaurantField(r, restaurantPhone, "0711 123456"); err != nil || r.Phone != "0711 123456" {
+		t.Errorf("Phone = %q, %v", r.Phone, err)
+	}
+	if err := applyRestaurantField(r, restaurantPhone, ""); err != nil || r.Phone != "" {
+		t.Errorf("Clearing the phone: %q, %v", r.Phone, err)
+	}
+	if err := applyRestaurantField(r, "fax", "123"); err == nil {
+		t.Error("Unknown fields should be rejected")
+	}
+}
+
+func TestRestaurantDefaults(t *testing.T) {
+	b := newTestBot()
+	settings := b.defaults.settings(nil)
+	r := &database.Restaurant{
+		Name: "Luigi",
+		Menu: []database.MenuItem{{Name: "Margherita", Price: 850}, {Name: "Salami"}},
+	}
+
+	_, name, options, err := b.parseGyroskopArgs("30min", settings.withRestaurant(r))
+	if err != nil || name != "Luigi" || !reflect.DeepEqual(options, []string{"Margherita", "Salami"}) {
+		t.Errorf("parseGyroskopArgs() with restaurant = %q, %v, %v", name, options, err)
+	}
+
+	// A restaurant without menu keeps the default options
+	_, _, options, _ = b.parseGyroskopArgs("", settings.withRestaurant(&database.Restaurant{Name: "Kebab"}))
+	if !reflect.DeepEqual(options, settings.FoodOptions) {
+		t.Errorf("parseGyroskopArgs() with restaurant without menu = %v, want %v", options, settings.FoodOptions)
+	}
+
+	text := b.formatRestaurant(&database.Restaurant{
+		Name:         "Luigi",
+		Phone:        "0711 123456",
+		OpeningHours: "Mo-Fr 11:00-22:00",
+		Menu:         r.Menu,
+	}, time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC))
+	for _, want := range []string{"☎️ 0711 123456", "(jetzt geöffnet)", "• Margherita – 8,50 €", "• Salami", "@Luigi"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatRestaurant() = %q, missing %q", text, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/logging"
+	"github.com/tionis/gyroskop/internal/metrics"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Errors returned by the exported gyroskop operations used by the HTTP API
+var (
+	ErrGyroskopActive    = errors.New("there is already an active gyroskop in this chat")
+	ErrGyroskopNotActive = errors.New("gyroskop is not the active gyroskop of this chat")
+	ErrGyroskopExpired   = errors.New("gyroskop deadline has passed")
+	ErrInvalidOrder      = errors.New("invalid order")
+	ErrNotPermitted      = errors.New("only chat administrators may do this in this chat")
+)
+
+// OpenGyroskop opens a new gyroskop in a chat and posts the gyroskop message.
+// An empty name, no options or a zero deadline are replaced by the defaults of the chat.
+// If only admins may open gyroskops in the chat, createdBy must still be one.
+func (b *Bot) OpenGyroskop(ctx context.Context, chatID, createdBy int64, creatorName, name string, foodOptions []string, deadline time.Time) (*database.Gyroskop, error) {
+	if _, exists := b.getActiveGyroskop(chatID); exists {
+		return nil, ErrGyroskopActive
+	}
+	ctx = apiContext(ctx, chatID)
+
+	settings := b.chatSettings(ctx, chatID)
+	if settings.OpenPermission == openByAdmins && !b.isChatAdmin(ctx, chatID, createdBy) {
+		return nil, ErrNotPermitted
+	}
+	if name == "" {
+		name = settings.Name
+	}
+	if len(foodOptions) == 0 {
+		foodOptions = settings.FoodOptions
+	}
+	if deadline.IsZero() {
+		deadline = time.Now().Add(settings.Duration)
+	}
+
+	creator := &tgbotapi.User{ID: createdBy, FirstName: creatorName}
+	return b.openGyroskop(ctx, chatID, creator, name, foodOptions, deadline, false, nil, "api")
+}
+
+// CloseGyroskop closes the active gyroskop of a chat and posts the summary
+func (b *Bot) CloseGyroskop(ctx context.Context, chatID int64, gyroskopID int) error {
+	gyroskop, exists := b.getActiveGyroskop(chatID)
+	if !exists || gyroskop.ID != gyroskopID {
+		return ErrGyroskopNotActive
+	}
+
+	err := b.closeGyroskop(withGyroskop(apiContext(ctx, chatID), gyroskop), gyroskop, "api")
+	if errors.Is(err, database.ErrInvalidTransition) {
+		return ErrGyroskopNotActive
+	}
+	return err
+}
+
+// PlaceOrder adds or replaces the order of a user in the active gyroskop of a chat.
+// An order with only zero quantities cancels the user's order.
+func (b *Bot) PlaceOrder(ctx context.Context, chatID int64, gyroskopID int, userID int64, username, firstName, lastName string, quantities map[string]int) error {
+	gyroskop, exists := b.getActiveGyroskop(chatID)
+	if !exists || gyroskop.ID != gyroskopID {
+		return ErrGyroskopNotActive
+	}
+
+	ctx = logging.With(withGyroskop(apiContext(ctx, chatID), gyroskop), "user_id", userID)
+	return b.placeOrder(ctx, gyroskop, &tgbotapi.User{ID: userID, UserName: username, FirstName: firstName, LastName: lastName}, quantities, "api")
+}
+
+// placeOrder validates and stores the order of a user and updates the gyroskop
+// message. The source (api, private) is only used for metrics and logging.
+func (b *Bot) placeOrder(ctx context.Context, gyroskop *database.Gyroskop, user *tgbotapi.User, quantities map[string]int, source string) error {
+	return b.storeOrder(ctx, gyroskop, user, quantities, source, nil)
+}
+
+// placeMessageOrder places an order written in a message, so that editing the
+// message changes the order
+func (b *Bot) placeMessageOrder(ctx context.Context, gyroskop *database.Gyroskop, message *tgbotapi.Message, quantities map[string]int, source string) error {
+	origin := database.OrderMessage{ChatID: message.Chat.ID, MessageID: message.MessageID}
+	return b.storeOrder(ctx, gyroskop, message.From, quantities, source, &origin)
+}
+
+// storeOrder validates and stores an order, optionally with the message it was written in
+func (b *Bot) storeOrder(ctx context.Context, gyroskop *database.Gyroskop, user *tgbotapi.User, quantities map[string]int, source string, origin *database.OrderMessage) error {
+	if time.Now().After(gyroskop.Deadline) {
+		return ErrGyroskopExpired
+	}
+
+	if err := validateQuantities(quantities, gyroskop.FoodOptions, b.chatSettings(ctx, gyroskop.ChatID).MaxQuantity); err != nil {
+		return err
+	}
+
+	var err error
+	if origin != nil {
+		err = b.db.AddOrUpdateOrderFromMessage(ctx, gyroskop.ID, user.ID, user.UserName, user.FirstName, user.LastName, quantities, *origin)
+	} else {
+		err = b.db.AddOrUpdateOrder(ctx, gyroskop.ID, user.ID, user.UserName, user.FirstName, user.LastName, quantities)
+	}
+	if err != nil {
+		return err
+	}
+	metrics.OrdersPlaced.Inc(source)
+
+	b.log.InfoContext(ctx, "Order placed", "source", source, "quantities", quantities)
+	b.updateGyroskopMessage(ctx, gyroskop, nil)
+	return nil
+}
+
+// apiContext adds the logging attributes of an operation requested via the HTTP API
+func apiContext(ctx context.Context, chatID int64) context.Context {
+	return logging.With(ctx, "chat_id", chatID, "source", "api")
+}
+
+// validateQuantities checks that all options exist and all quantities are in range
+func validateQuantities(quantities map[string]int, foodOptions []string, maxQuantity int) error {
+	for option, qty := range quantities {
+		found := false
+		for _, foodOption := range foodOptions {
+			if option == foodOption {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: unknown option %q", ErrInvalidOrder, option)
+		}
+		if qty < 0 || qty > maxQuantity {
+			return fmt.Errorf("%w: quantity for %q must be between 0 and %d", ErrInvalidOrder, option, maxQuantity)
+		}
+	}
+	return nil
+}
+
+// formatAPIToken formats the private message with a new API token of a group
+func formatAPIToken(chatTitle, token string) string {
+	return messageFormat.Lines(
+		render.Line{render.Plain("🔑 "), render.Bold("API-Token für " + chatTitle)},
+		nil,
+		render.Line{render.Code(token)},
+		nil,
+		render.Line{render.Plain("Verwende es als "), render.Code("Authorization: Bearer <token>"), render.Plain(". Widerrufen mit /apitoken widerrufen in der Gruppe.")},
+	)
+}
+
+// handleAPIToken issues or revokes API tokens for the chat (chat admins only)
+func (b *Bot) handleAPIToken(ctx context.Context, message *tgbotapi.Message, args string) {
+	if !b.isChatAdmin(ctx, message.Chat.ID, message.From.ID) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Nur Gruppen-Admins können API-Tokens verwalten!")
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		token, err := b.db.CreateAPIToken(ctx, message.Chat.ID, message.From.ID, b.getUserName(message.From))
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Erstellen des API-Tokens", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Erstellen des API-Tokens")
+			return
+		}
+
+		msg := tgbotapi.NewMessage(message.From.ID, formatAPIToken(message.Chat.Title, token))
+		msg.ParseMode = messageFormat.ParseMode()
+		if _, err := b.send(ctx, message.From.ID, msg); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Senden des API-Tokens", "error", err)
+			if _, err := b.db.RevokeAPIToken(ctx, token); err != nil {
+				b.log.ErrorContext(ctx, "Fehler beim Widerrufen des API-Tokens", "error", err)
+			}
+			if privateChatUnavailable(err) {
+				b.sendMessage(ctx, message.Chat.ID, "❌ Ich konnte dir keine private Nachricht senden. Starte zuerst einen privaten Chat mit mir.")
+			} else {
+				b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Senden des API-Tokens, bitte versuche es erneut.")
+			}
+			return
+		}
+
+		b.sendMessage(ctx, message.Chat.ID, "🔑 API-Token wurde dir privat geschickt")
+	case "widerrufen", "revoke":
+		count, err := b.db.RevokeAPITokens(ctx, message.Chat.ID)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Widerrufen der API-Tokens", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Widerrufen der API-Tokens")
+			return
+		}
+		b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("🔒 %d API-Token(s) widerrufen", count))
+	default:
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /apitoken oder /apitoken widerrufen")
+	}
+}
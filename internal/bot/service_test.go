@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateQuantities(t *testing.T) {
+	foodOptions := []string{"Fleisch", "Vegetarisch"}
+
+	tests := []struct {
+		name       string
+		quantities map[string]int
+		wantErr    bool
+	}{
+		{"valid order", map[string]int{"Fleisch": 2, "Vegetarisch": 1}, false},
+		{"empty order", map[string]int{}, false},
+		{"zero cancels", map[string]int{"Fleisch": 0}, false},
+		{"maximum quantity", map[string]int{"Fleisch": 10}, false},
+		{"unknown option", map[string]int{"Pizza": 1}, true},
+		{"option case must match", map[string]int{"fleisch": 1}, true},
+		{"quantity too high", map[string]int{"Fleisch": 11}, true},
+		{"negative quantity", map[string]int{"Fleisch": -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateQuantities(tt.quantities, foodOptions, 10)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateQuantities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidOrder) {
+				t.Errorf("validateQuantities() error = %v, want ErrInvalidOrder", err)
+			}
+		})
+	}
+}
+
+func TestFormatAPIToken(t *testing.T) {
+	got := formatAPIToken("Team_*Mittag*", "abc_123")
+	want := "🔑 <b>API-Token für Team_*Mittag*</b>\n\n<code>abc_123</code>\n\nVerwende es als <code>Authorization: Bearer &lt;token&gt;</code>. Widerrufen mit /apitoken widerrufen in der Gruppe."
+	if got != want {
+		t.Errorf("formatAPIToken() = %q, want %q", got, want)
+	}
+}
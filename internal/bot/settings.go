@@ -0,0 +1,495 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Who may open gyroskops in a chat
+const (
+	openByAll    = "alle"
+	openByAdmins = "admins"
+)
+
+// defaultQuantityButtons is the highest quantity button unless configured
+const defaultQuantityButtons = 5
+
+// settingsCallbackPrefix marks callback data of the settings menu
+const settingsCallbackPrefix = "s:"
+
+// Keys of the settings in callback data and /einstellungen
+const (
+	settingDuration        = "dauer"
+	settingName            = "name"
+	settingMaxQuantity     = "max"
+	settingQuantityButtons = "buttons"
+	settingConfirmations   = "bestaetigungen"
+	settingOpenPermission  = "oeffnen"
+	settingPin             = "anpinnen"
+	settingSplit           = "aufteilung"
+)
+
+// Choices offered in the settings menu
+var (
+	durationChoices        = []int{5, 10, 15, 20, 30, 45, 60} // Minutes
+	maxQuantityChoices     = []int{1, 2, 3, 5, 10, 20}
+	quantityButtonsChoices = []int{0, 3, 4, 5, 6, 8}
+)
+
+var errInvalidSetting = errors.New("invalid setting")
+
+// Settings are the effective settings of a chat
+type Settings struct {
+	Duration        time.Duration // Time until the deadline if none is given
+	Name            string        // Name of a gyroskop if none is given
+	FoodOptions     []string      // Options of a gyroskop if none are given
+	MaxQuantity     int           // Highest quantity that can be ordered per option
+	QuantityButtons int           // Highest quantity button under the gyroskop message, 0 for none
+	Confirmations   bool          // Whether orders and cancellations are confirmed with a message
+	OpenPermission  string        // Who may open gyroskops: openByAll or openByAdmins
+	Pin             bool          // Whether the gyroskop message is pinned while open
+	Split           string        // How extra costs are split unless chosen per gyroskop, one of splitStrategies
+
+	Templates map[string]*render.Template // Message templates of the chat by name, the others use the defaults
+}
+
+// settings merges the stored settings of a chat with the defaults
+func (d Defaults) settings(stored *database.ChatSettings) Settings {
+	s := Settings{
+		Duration:        d.Duration,
+		Name:            d.Name,
+		FoodOptions:     append([]string(nil), d.FoodOptions...),
+		MaxQuantity:     d.MaxQuantity,
+		QuantityButtons: defaultQuantityButtons,
+		Confirmations:   true,
+		OpenPermission:  openByAll,
+		Split:           splitEqual,
+	}
+	if stored == nil {
+		return s
+	}
+
+	if stored.DefaultDuration != nil {
+		s.Duration = *stored.DefaultDuration
+	}
+	if stored.DefaultName != nil {
+		s.Name = *stored.DefaultName
+	}
+	if len(stored.FoodOptions) > 0 {
+		s.FoodOptions = append([]string(nil), stored.FoodOptions...)
+	}
+	if stored.MaxQuantity != nil {
+		s.MaxQuantity = *stored.MaxQuantity
+	}
+	if stored.QuantityButtons != nil {
+		s.QuantityButtons = *stored.QuantityButtons
+	}
+	if stored.Confirmations != nil {
+		s.Confirmations = *stored.Confirmations
+	}
+	if stored.OpenPermission != nil {
+		s.OpenPermission = *stored.OpenPermission
+	}
+	if stored.Pin != nil {
+		s.Pin = *stored.Pin
+	}
+	if stored.Split != nil {
+		s.Split = *stored.Split
+	}
+	return s
+}
+
+// quantityButtons returns the quantities offered as buttons, at most MaxQuantity
+func (s Settings) quantityButtons() []int {
+	var quantities []int
+	for qty := 1; qty <= s.QuantityButtons && qty <= s.MaxQuantity; qty++ {
+		quantities = append(quantities, qty)
+	}
+	return quantities
+}
+
+// settingsCache caches the effective settings per chat. The bot is the only
+// writer of chat_settings, so entries are invalidated on change instead of expiring.
+type settingsCache struct {
+	mu      sync.Mutex
+	entries map[int64]Settings
+}
+
+func (c *settingsCache) get(chatID int64) (Settings, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.entries[chatID]
+	return s, ok
+}
+
+func (c *settingsCache) set(chatID int64, s Settings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[int64]Settings)
+	}
+	c.entries[chatID] = s
+}
+
+func (c *settingsCache) invalidate(chatID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, chatID)
+}
+
+// chatSettings returns the effective settings of a chat, falling back to the
+// defaults if they cannot be loaded
+func (b *Bot) chatSettings(ctx context.Context, chatID int64) Settings {
+	if s, ok := b.settings.get(chatID); ok {
+		return s
+	}
+
+	stored, err := b.db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Einstellungen", "error", err)
+		return b.defaults.settings(nil)
+	}
+
+	s := b.defaults.settings(stored)
+	s.Templates = b.chatTemplates(ctx, chatID)
+	b.settings.set(chatID, s)
+	return s
+}
+
+// applySetting changes a stored setting to a value chosen in the settings menu
+func applySetting(stored *database.ChatSettings, key, value string) error {
+	switch key {
+	case settingDuration:
+		minutes, err := parseChoice(value, durationChoices)
+		if err != nil {
+			return err
+		}
+		duration := time.Duration(minutes) * time.Minute
+		stored.DefaultDuration = &duration
+	case settingMaxQuantity:
+		maxQuantity, err := parseChoice(value, maxQuantityChoices)
+		if err != nil {
+			return err
+		}
+		stored.MaxQuantity = &maxQuantity
+	case settingQuantityButtons:
+		buttons, err := parseChoice(value, quantityButtonsChoices)
+		if err != nil {
+			return err
+		}
+		stored.QuantityButtons = &buttons
+	case settingConfirmations:
+		enabled, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		stored.Confirmations = &enabled
+	case settingPin:
+		enabled, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		stored.Pin = &enabled
+	case settingOpenPermission:
+		if value != openByAll && value != openByAdmins {
+			return errInvalidSetting
+		}
+		stored.OpenPermission = &value
+	case settingSplit:
+		if !slices.Contains(splitStrategies, value) {
+			return errInvalidSetting
+		}
+		stored.Split = &value
+	default:
+		return errInvalidSetting
+	}
+	return nil
+}
+
+// parseOnOff parses the value of a setting that is turned on or off
+func parseOnOff(value string) (bool, error) {
+	switch value {
+	case "an":
+		return true, nil
+	case "aus":
+		return false, nil
+	}
+	return false, errInvalidSetting
+}
+
+// parseChoice parses a number that has to be one of the choices
+func parseChoice(value string, choices []int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errInvalidSetting
+	}
+	for _, choice := range choices {
+		if n == choice {
+			return n, nil
+		}
+	}
+	return 0, errInvalidSetting
+}
+
+// parseDefaultGyroskop parses the arguments of /einstellungen name
+// Format: Name, Option1, Option2, ... (empty or "zurücksetzen" for the defaults)
+func parseDefaultGyroskop(args string) (name *string, foodOptions []string, err error) {
+	args = strings.TrimSpace(args)
+	if args == "" || strings.EqualFold(args, "zurücksetzen") {
+		return nil, nil, nil
+	}
+
+	var parts []string
+	for _, part := range strings.Split(args, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) < 2 {
+		return nil, nil, errInvalidSetting
+	}
+	return &parts[0], parts[1:], nil
+}
+
+// formatSettings formats the settings for the settings menu
+func formatSettings(s Settings) string {
+	confirmations := "an"
+	if !s.Confirmations {
+		confirmations = "aus"
+	}
+	open := "alle"
+	if s.OpenPermission == openByAdmins {
+		open = "nur Admins"
+	}
+	pin := "an"
+	if !s.Pin {
+		pin = "aus"
+	}
+
+	return messageFormat.Lines(
+		render.Line{render.Plain("⚙️ "), render.Bold("Einstellungen")},
+		nil,
+		render.Line{render.Plain(fmt.Sprintf("⏱ Standarddauer: %d min", int(s.Duration.Minutes())))},
+		render.Line{render.Plain(fmt.Sprintf("🥙 Standard: %s (%s)", s.Name, strings.Join(s.FoodOptions, ", ")))},
+		render.Line{render.Plain(fmt.Sprintf("🔢 Höchstanzahl pro Option: %d", s.MaxQuantity))},
+		render.Line{render.Plain("🔘 Anzahl-Buttons: " + formatQuantityButtons(s.QuantityButtons))},
+		render.Line{render.Plain("✅ Bestätigungen: " + confirmations)},
+		render.Line{render.Plain("🔐 Gyroskop öffnen: " + open)},
+		render.Line{render.Plain("📌 Anpinnen: " + pin)},
+		render.Line{render.Plain("💶 Kostenaufteilung: " + splitLabels[s.Split])},
+		nil,
+		render.Line{render.Plain("Nur Gruppen-Admins können Einstellungen ändern.")},
+		render.Line{render.Plain("Name und Optionen: /einstellungen name Pizza, Margherita, Salami")},
+	)
+}
+
+// formatQuantityButtons describes the quantity buttons setting, e.g. "1–5"
+func formatQuantityButtons(buttons int) string {
+	if buttons == 0 {
+		return "keine"
+	}
+	return fmt.Sprintf("1–%d", buttons)
+}
+
+// settingsKeyboard returns the keyboard of the settings menu or of one of its submenus
+func settingsKeyboard(s Settings, submenu string) tgbotapi.InlineKeyboardMarkup {
+	switch submenu {
+	case settingDuration:
+		return choicesKeyboard(settingDuration, durationChoices, int(s.Duration.Minutes()), func(n int) string {
+			return fmt.Sprintf("%d min", n)
+		})
+	case settingMaxQuantity:
+		return choicesKeyboard(settingMaxQuantity, maxQuantityChoices, s.MaxQuantity, strconv.Itoa)
+	case settingQuantityButtons:
+		return choicesKeyboard(settingQuantityButtons, quantityButtonsChoices, s.QuantityButtons, formatQuantityButtons)
+	}
+
+	confirmations := tgbotapi.NewInlineKeyboardButtonData("✅ Bestätigungen: an", settingsCallbackPrefix+settingConfirmations+":aus")
+	if !s.Confirmations {
+		confirmations = tgbotapi.NewInlineKeyboardButtonData("✅ Bestätigungen: aus", settingsCallbackPrefix+settingConfirmations+":an")
+	}
+	open := tgbotapi.NewInlineKeyboardButtonData("🔐 Öffnen: alle", settingsCallbackPrefix+settingOpenPermission+":"+openByAdmins)
+	if s.OpenPermission == openByAdmins {
+		open = tgbotapi.NewInlineKeyboardButtonData("🔐 Öffnen: Admins", settingsCallbackPrefix+settingOpenPermission+":"+openByAll)
+	}
+	pin := tgbotapi.NewInlineKeyboardButtonData("📌 Anpinnen: aus", settingsCallbackPrefix+settingPin+":an")
+	if s.Pin {
+		pin = tgbotapi.NewInlineKeyboardButtonData("📌 Anpinnen: an", settingsCallbackPrefix+settingPin+":aus")
+	}
+	split := tgbotapi.NewInlineKeyboardButtonData("💶 Kosten: "+s.Split, settingsCallbackPrefix+settingSplit+":"+nextSplit(s.Split))
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏱ Dauer", settingsCallbackPrefix+settingDuration),
+			tgbotapi.NewInlineKeyboardButtonData("🥙 Name & Optionen", settingsCallbackPrefix+settingName),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔢 Höchstanzahl", settingsCallbackPrefix+settingMaxQuantity),
+			tgbotapi.NewInlineKeyboardButtonData("🔘 Buttons", settingsCallbackPrefix+settingQuantityButtons),
+		),
+		tgbotapi.NewInlineKeyboardRow(confirmations, open),
+		tgbotapi.NewInlineKeyboardRow(pin, split),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Zurücksetzen", settingsCallbackPrefix+"reset"),
+			tgbotapi.NewInlineKeyboardButtonData("✔️ Fertig", settingsCallbackPrefix+"fertig"),
+		),
+	)
+}
+
+// choicesKeyboard returns a submenu with one button per choice, marking the current one
+func choicesKeyboard(key string, choices []int, current int, label func(int) string) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, choice := range choices {
+		text := label(choice)
+		if choice == current {
+			text = "• " + text
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("%s%s:%d", settingsCallbackPrefix, key, choice)))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Zurück", settingsCallbackPrefix+"menu")),
+	)
+}
+
+// handleSettings shows the settings menu or sets the default name and options
+// Format: /einstellungen [name Name, Option1, Option2, ...]
+func (b *Bot) handleSettings(ctx context.Context, message *tgbotapi.Message, args string) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		s := b.chatSettings(ctx, message.Chat.ID)
+		msg := tgbotapi.NewMessage(message.Chat.ID, formatSettings(s))
+		msg.ParseMode = messageFormat.ParseMode()
+		msg.ReplyMarkup = settingsKeyboard(s, "")
+		if _, err := b.send(ctx, message.Chat.ID, msg); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Senden der Einstellungen", "error", err)
+		}
+		return
+	}
+
+	key, rest, _ := strings.Cut(args, " ")
+	if !strings.EqualFold(key, settingName) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /einstellungen oder /einstellungen name Name, Option1, Option2, ...")
+		return
+	}
+
+	if !b.isChatAdmin(ctx, message.Chat.ID, message.From.ID) {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Nur Gruppen-Admins können Einstellungen ändern!")
+		return
+	}
+
+	name, foodOptions, err := parseDefaultGyroskop(rest)
+	if err != nil {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /einstellungen name Name, Option1, Option2, ...")
+		return
+	}
+
+	stored, err := b.db.GetChatSettings(ctx, message.Chat.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Einstellungen", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Einstellungen")
+		return
+	}
+	stored.DefaultName = name
+	stored.FoodOptions = foodOptions
+
+	if !b.saveChatSettings(ctx, stored, message.From.ID) {
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Speichern der Einstellungen")
+		return
+	}
+
+	s := b.chatSettings(ctx, message.Chat.ID)
+	b.sendMessage(ctx, message.Chat.ID, fmt.Sprintf("⚙️ Standard: %s (%s)", s.Name, strings.Join(s.FoodOptions, ", ")))
+}
+
+// handleSettingsCallback handles the buttons of the settings menu (chat admins only)
+// Format: s:<key> opens a submenu, s:<key>:<value> changes a setting
+func (b *Bot) handleSettingsCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	key, value, hasValue := strings.Cut(strings.TrimPrefix(query.Data, settingsCallbackPrefix), ":")
+
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.answerCallbackQuery(ctx, query.ID, "⚠️ Nur Gruppen-Admins können Einstellungen ändern")
+		return
+	}
+
+	switch {
+	case key == "fertig":
+		b.answerCallbackQuery(ctx, query.ID, "")
+		b.showSettingsMenu(ctx, query.Message, "", false)
+	case key == "menu":
+		b.answerCallbackQuery(ctx, query.ID, "")
+		b.showSettingsMenu(ctx, query.Message, "", true)
+	case key == settingName:
+		b.answerCallbackQuery(ctx, query.ID, "Name und Optionen änderst du mit /einstellungen name Pizza, Margherita, Salami")
+	case key == "reset":
+		if err := b.db.DeleteChatSettings(ctx, chatID); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Zurücksetzen der Einstellungen", "error", err)
+			b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Zurücksetzen")
+			return
+		}
+		b.settings.invalidate(chatID)
+		b.log.InfoContext(ctx, "Chat settings reset")
+		b.answerCallbackQuery(ctx, query.ID, "↩️ Zurückgesetzt")
+		b.showSettingsMenu(ctx, query.Message, "", true)
+	case !hasValue:
+		b.answerCallbackQuery(ctx, query.ID, "")
+		b.showSettingsMenu(ctx, query.Message, key, true)
+	default:
+		stored, err := b.db.GetChatSettings(ctx, chatID)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Laden der Einstellungen", "error", err)
+			b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Laden der Einstellungen")
+			return
+		}
+		if err := applySetting(stored, key, value); err != nil {
+			b.answerCallbackQuery(ctx, query.ID, "❌ Ungültiger Wert")
+			return
+		}
+		if !b.saveChatSettings(ctx, stored, query.From.ID) {
+			b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Speichern")
+			return
+		}
+		b.answerCallbackQuery(ctx, query.ID, "✅ Gespeichert")
+		b.showSettingsMenu(ctx, query.Message, "", true)
+	}
+}
+
+// saveChatSettings stores the settings of a chat and drops the cached ones
+func (b *Bot) saveChatSettings(ctx context.Context, stored *database.ChatSettings, updatedBy int64) bool {
+	if err := b.db.SaveChatSettings(ctx, stored, updatedBy); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Speichern der Einstellungen", "error", err)
+		return false
+	}
+	b.settings.invalidate(stored.ChatID)
+	b.log.InfoContext(ctx, "Chat settings changed")
+	return true
+}
+
+// showSettingsMenu edits the settings message to show the current settings
+// with the menu or a submenu, or without buttons once done
+func (b *Bot) showSettingsMenu(ctx context.Context, message *tgbotapi.Message, submenu string, withKeyboard bool) {
+	chatID := message.Chat.ID
+	b.outbox.Edit(ctx, chatID, message.MessageID, func(ctx context.Context) (tgbotapi.Chattable, error) {
+		s := b.chatSettings(ctx, chatID)
+
+		edit := tgbotapi.NewEditMessageText(chatID, message.MessageID, formatSettings(s))
+		edit.ParseMode = messageFormat.ParseMode()
+		if withKeyboard {
+			keyboard := settingsKeyboard(s, submenu)
+			edit.ReplyMarkup = &keyboard
+		}
+		return edit, nil
+	})
+}
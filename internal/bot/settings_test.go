@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestDefaultsSettings(t *testing.T) {
+	b := newTestBot()
+
+	defaults := b.defaults.settings(nil)
+	want := Settings{
+		Duration:        15 * time.Minute,
+		Name:            "Gyros",
+		FoodOptions:     []string{"Fleisch", "Vegetarisch"},
+		MaxQuantity:     10,
+		QuantityButtons: 5,
+		Confirmations:   true,
+		OpenPermission:  openByAll,
+		Split:           splitEqual,
+	}
+	if !reflect.DeepEqual(defaults, want) {
+		t.Errorf("settings(nil) = %+v, want %+v", defaults, want)
+	}
+
+	duration := 30 * time.Minute
+	name := "Pizza"
+	maxQuantity := 3
+	buttons := 0
+	confirmations := false
+	open := openByAdmins
+	pin := true
+	split := splitAmounts
+	stored := &database.ChatSettings{
+		DefaultDuration: &duration,
+		DefaultName:     &name,
+		FoodOptions:     []string{"Margherita"},
+		MaxQuantity:     &maxQuantity,
+		QuantityButtons: &buttons,
+		Confirmations:   &confirmations,
+		OpenPermission:  &open,
+		Pin:             &pin,
+		Split:           &split,
+	}
+	got := b.defaults.settings(stored)
+	want = Settings{
+		Duration:        30 * time.Minute,
+		Name:            "Pizza",
+		FoodOptions:     []string{"Margherita"},
+		MaxQuantity:     3,
+		QuantityButtons: 0,
+		Confirmations:   false,
+		OpenPermission:  openByAdmins,
+		Pin:             true,
+		Split:           splitAmounts,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("settings(stored) = %+v, want %+v", got, want)
+	}
+
+	// The defaults must not share the options slice with the settings
+	defaults.FoodOptions[0] = "Geändert"
+	if b.defaults.FoodOptions[0] != "Fleisch" {
+		t.Error("Changing the settings changed the defaults")
+	}
+}
+
+func TestApplySetting(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		wantErr bool
+		check   func(*database.ChatSettings) bool
+	}{
+		{settingDuration, "30", false, func(s *database.ChatSettings) bool { return *s.DefaultDuration == 30*time.Minute }},
+		{settingDuration, "7", true, nil},
+		{settingDuration, "abc", true, nil},
+		{settingMaxQuantity, "5", false, func(s *database.ChatSettings) bool { return *s.MaxQuantity == 5 }},
+		{settingMaxQuantity, "100", true, nil},
+		{settingQuantityButtons, "0", false, func(s *database.ChatSettings) bool { return *s.QuantityButtons == 0 }},
+		{settingQuantityButtons, "8", false, func(s *database.ChatSettings) bool { return *s.QuantityButtons == 8 }},
+		{settingQuantityButtons, "9", true, nil},
+		{settingConfirmations, "aus", false, func(s *database.ChatSettings) bool { return !*s.Confirmations }},
+		{settingConfirmations, "an", false, func(s *database.ChatSettings) bool { return *s.Confirmations }},
+		{settingConfirmations, "vielleicht", true, nil},
+		{settingPin, "an", false, func(s *database.ChatSettings) bool { return *s.Pin }},
+		{settingPin, "aus", false, func(s *database.ChatSettings) bool { return !*s.Pin }},
+		{settingPin, "ja", true, nil},
+		{settingOpenPermission, openByAdmins, false, func(s *database.ChatSettings) bool { return *s.OpenPermission == openByAdmins }},
+		{settingOpenPermission, "niemand", true, nil},
+		{settingSplit, splitItems, false, func(s *database.ChatSettings) bool { return *s.Split == splitItems }},
+		{settingSplit, "zufall", true, nil},
+		{"unbekannt", "1", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			stored := &database.ChatSettings{ChatID: 1}
+			err := applySetting(stored, tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applySetting() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errInvalidSetting) {
+				t.Errorf("applySetting() error = %v, want errInvalidSetting", err)
+			}
+			if tt.check != nil && !tt.check(stored) {
+				t.Errorf("applySetting() stored %+v", stored)
+			}
+		})
+	}
+}
+
+func TestParseDefaultGyroskop(t *testing.T) {
+	tests := []struct {
+		args        string
+		wantName    string
+		wantOptions []string
+		wantErr     bool
+	}{
+		{"Pizza, Margherita, Salami", "Pizza", []string{"Margherita", "Salami"}, false},
+		{" Döner ,Fleisch,, Vegetarisch ", "Döner", []string{"Fleisch", "Vegetarisch"}, false},
+		{"", "", nil, false},
+		{"zurücksetzen", "", nil, false},
+		{"Pizza", "", nil, true},
+		{"Pizza,", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			name, options, err := parseDefaultGyroskop(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDefaultGyroskop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			gotName := ""
+			if name != nil {
+				gotName = *name
+			}
+			if gotName != tt.wantName || !reflect.DeepEqual(options, tt.wantOptions) {
+				t.Errorf("parseDefaultGyroskop() = %q, %v, want %q, %v", gotName, options, tt.wantName, tt.wantOptions)
+			}
+		})
+	}
+}
+
+func TestQuantityButtons(t *testing.T) {
+	tests := []struct {
+		buttons     int
+		maxQuantity int
+		want        []int
+	}{
+		{5, 10, []int{1, 2, 3, 4, 5}},
+		{5, 3, []int{1, 2, 3}},
+		{0, 10, nil},
+	}
+
+	for _, tt := range tests {
+		s := Settings{QuantityButtons: tt.buttons, MaxQuantity: tt.maxQuantity}
+		if got := s.quantityButtons(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("quantityButtons() with %d buttons and max %d = %v, want %v", tt.buttons, tt.maxQuantity, got, tt.want)
+		}
+	}
+}
+
+func TestCreateFoodOptionsKeyboard(t *testing.T) {
+	b := newTestBot()
+
+	keyboard := b.createFoodOptionsKeyboard([]string{"Fleisch", "Vegetarisch"}, []int{1, 2, 3})
+	// Header and buttons per option, then the cancel and reorder buttons
+	if len(keyboard.InlineKeyboard) != 5 {
+		t.Fatalf("keyboard has %d rows, want 5", len(keyboard.InlineKeyboard))
+	}
+	row := keyboard.InlineKeyboard[3]
+	if len(row) != 3 || row[0].Text != "1️⃣" || *row[2].CallbackData != "g1_3" {
+		t.Errorf("unexpected button row for Vegetarisch: %+v", row)
+	}
+
+	row = keyboard.InlineKeyboard[4]
+	if len(row) != 2 || *row[0].CallbackData != "g0" || *row[1].CallbackData != "gr" {
+		t.Errorf("unexpected cancel and reorder row: %+v", row)
+	}
+
+	keyboard = b.createFoodOptionsKeyboard([]string{"Fleisch"}, nil)
+	if len(keyboard.InlineKeyboard) != 1 || *keyboard.InlineKeyboard[0][0].CallbackData != "g0" {
+		t.Errorf("keyboard without quantity buttons should only have the cancel and reorder buttons: %+v", keyboard.InlineKeyboard)
+	}
+}
+
+func TestSettingsKeyboard(t *testing.T) {
+	s := newTestBot().defaults.settings(nil)
+
+	// Every callback of the menu and its submenus has to fit Telegram's 64 byte limit
+	for _, submenu := range []string{"", settingDuration, settingMaxQuantity, settingQuantityButtons} {
+		keyboard := settingsKeyboard(s, submenu)
+		for _, row := range keyboard.InlineKeyboard {
+			for _, button := range row {
+				data := *button.CallbackData
+				if !strings.HasPrefix(data, settingsCallbackPrefix) || len(data) > 64 {
+					t.Errorf("invalid callback data %q in submenu %q", data, submenu)
+				}
+			}
+		}
+	}
+
+	// The current value is marked in submenus
+	keyboard := settingsKeyboard(s, settingDuration)
+	found := false
+	for _, button := range keyboard.InlineKeyboard[0] {
+		if button.Text == "• 15 min" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("current duration not marked: %+v", keyboard.InlineKeyboard[0])
+	}
+}
+
+func TestFormatSettings(t *testing.T) {
+	s := newTestBot().defaults.settings(nil)
+	s.Name = "Spaghetti_Carbonara <Luigi>"
+	s.FoodOptions = []string{"*Klein*", "Groß & Hungrig"}
+
+	text := formatSettings(s)
+	for _, want := range []string{"<b>Einstellungen</b>", "Spaghetti_Carbonara &lt;Luigi&gt; (*Klein*, Groß &amp; Hungrig)", "Standarddauer: 15 min"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatSettings() = %q, missing %q", text, want)
+		}
+	}
+}
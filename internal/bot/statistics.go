@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+const (
+	statisticsTopLimit     = 5  // Number of entries in the top lists
+	statisticsHistoryLimit = 10 // Number of entries in the personal history
+)
+
+// statisticsPeriod is the time range a statistic is computed for
+type statisticsPeriod struct {
+	Label string
+	Since time.Time
+}
+
+var weekdayNames = []string{"", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag", "Sonntag"}
+
+// parseStatisticsArgs parses the arguments of /statistik
+// Format: [ich] [woche|monat|quartal|jahr|alle|<n>d]
+// Examples:
+//
+//	/statistik -> chat statistics over all time
+//	/statistik monat -> chat statistics of the last 30 days
+//	/statistik ich 14d -> personal statistics of the last 14 days
+func parseStatisticsArgs(args string, now time.Time) (bool, statisticsPeriod, error) {
+	personal := false
+	period := statisticsPeriod{Label: "gesamter Zeitraum"}
+
+	daysRegex := regexp.MustCompile(`^(\d+)(d|t|tag|tage)$`)
+
+	for _, field := range strings.Fields(strings.ToLower(args)) {
+		switch field {
+		case "ich", "me":
+			personal = true
+		case "alle", "all", "gesamt":
+			period = statisticsPeriod{Label: "gesamter Zeitraum"}
+		case "woche", "week":
+			period = statisticsPeriod{Label: "letzte 7 Tage", Since: now.AddDate(0, 0, -7)}
+		case "monat", "month":
+			period = statisticsPeriod{Label: "letzte 30 Tage", Since: now.AddDate(0, 0, -30)}
+		case "quartal", "quarter":
+			period = statisticsPeriod{Label: "letzte 90 Tage", Since: now.AddDate(0, 0, -90)}
+		case "jahr", "year":
+			period = statisticsPeriod{Label: "letzte 365 Tage", Since: now.AddDate(0, 0, -365)}
+		default:
+			matches := daysRegex.FindStringSubmatch(field)
+			if len(matches) != 3 {
+				return false, statisticsPeriod{}, fmt.Errorf("invalid statistics argument: %s", field)
+			}
+			days, err := strconv.Atoi(matches[1])
+			if err != nil || days <= 0 {
+				return false, statisticsPeriod{}, fmt.Errorf("invalid number of days: %s", field)
+			}
+			period = statisticsPeriod{Label: fmt.Sprintf("letzte %d Tage", days), Since: now.AddDate(0, 0, -days)}
+		}
+	}
+
+	return personal, period, nil
+}
+
+// handleStatistics shows aggregated statistics of the chat or the calling user
+func (b *Bot) handleStatistics(ctx context.Context, message *tgbotapi.Message, args string) {
+	personal, period, err := parseStatisticsArgs(args, time.Now())
+	if err != nil {
+		b.sendMessage(ctx, message.Chat.ID, "⚠️ Ungültiges Format. Verwende: /statistik [ich] [woche|monat|quartal|jahr|alle|14d]")
+		return
+	}
+
+	if personal {
+		stats, err := b.db.GetUserStatistics(ctx, message.Chat.ID, int64(message.From.ID), period.Since, statisticsTopLimit, statisticsHistoryLimit)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Laden der Statistik", "error", err)
+			b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Statistik")
+			return
+		}
+
+		b.sendFormatted(ctx, message.Chat.ID, b.formatUserStatistics(b.getUserName(message.From), period, stats))
+		return
+	}
+
+	stats, err := b.db.GetChatStatistics(ctx, message.Chat.ID, period.Since, b.defaults.Location.String(), statisticsTopLimit)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Statistik", "error", err)
+		b.sendMessage(ctx, message.Chat.ID, "❌ Fehler beim Laden der Statistik")
+		return
+	}
+
+	b.sendFormatted(ctx, message.Chat.ID, b.formatChatStatistics(period, stats))
+}
+
+// formatChatStatistics formatiert die Statistik einer Gruppe
+func (b *Bot) formatChatStatistics(period statisticsPeriod, stats *database.ChatStatistics) string {
+	lines := []render.Line{{render.Plain("📈 "), render.Bold("Statistik"), render.Plain(" (" + period.Label + ")")}, nil}
+
+	if stats.GyroskopCount == 0 {
+		lines = append(lines, render.Line{render.Plain("Noch keine Gyroskops in diesem Zeitraum 😢")})
+		return messageFormat.Lines(lines...)
+	}
+
+	lines = append(lines,
+		render.Line{render.Plain(fmt.Sprintf("🥙 Gyroskops: %d", stats.GyroskopCount))},
+		render.Line{render.Plain(fmt.Sprintf("🧾 Bestellungen: %d (%d Portionen)", stats.OrderCount, stats.ItemCount))},
+		render.Line{render.Plain(fmt.Sprintf("👥 Ø Teilnehmer: %.1f", stats.AvgParticipants))},
+	)
+
+	if len(stats.TopNames) > 0 {
+		lines = append(lines, nil, render.Line{render.Plain("🏆 "), render.Bold("Beliebteste Gyroskops:")})
+		for i, name := range stats.TopNames {
+			lines = append(lines, render.Line{render.Plain(fmt.Sprintf("%d. %s (%dx)", i+1, name.Name, name.Count))})
+		}
+	}
+
+	if len(stats.TopOptions) > 0 {
+		lines = append(lines, nil, render.Line{render.Plain("🍽 "), render.Bold("Beliebteste Optionen:")})
+		for i, option := range stats.TopOptions {
+			lines = append(lines, render.Line{render.Plain(fmt.Sprintf("%d. %s (%d)", i+1, option.Name, option.Count))})
+		}
+	}
+
+	if len(stats.TopOrderers) > 0 {
+		lines = append(lines, nil, render.Line{render.Plain("🙋 "), render.Bold("Fleißigste Besteller:")})
+		for i, user := range stats.TopOrderers {
+			order := database.Order{UserID: user.UserID, Username: user.Username, FirstName: user.FirstName, LastName: user.LastName}
+			lines = append(lines, render.Line{render.Plain(fmt.Sprintf("%d. %s: %d Bestellungen, %d Portionen", i+1, b.formatUserName(&order), user.Orders, user.Items))})
+		}
+	}
+
+	if weekday, ok := busiestBucket(stats.Weekdays); ok && weekday < len(weekdayNames) {
+		lines = append(lines, nil, render.Line{render.Plain("📅 Beliebtester Wochentag: " + weekdayNames[weekday])})
+	}
+
+	if hour, ok := busiestBucket(stats.Hours); ok {
+		lines = append(lines, render.Line{render.Plain(fmt.Sprintf("🕐 Beliebteste Uhrzeit: %02d:00 - %02d:00 Uhr", hour, (hour+1)%24))})
+	}
+
+	return messageFormat.Lines(lines...)
+}
+
+// formatUserStatistics formatiert die persönliche Statistik eines Users
+func (b *Bot) formatUserStatistics(userName string, period statisticsPeriod, stats *database.UserStatistics) string {
+	lines := []render.Line{{render.Plain("📈 "), render.Bold("Statistik für " + userName), render.Plain(" (" + period.Label + ")")}, nil}
+
+	if stats.OrderCount == 0 {
+		lines = append(lines, render.Line{render.Plain("Noch keine Bestellungen in diesem Zeitraum 😢")})
+		return messageFormat.Lines(lines...)
+	}
+
+	lines = append(lines, render.Line{render.Plain(fmt.Sprintf("🧾 Bestellungen: %d (%d Portionen)", stats.OrderCount, stats.ItemCount))})
+
+	if len(stats.TopOptions) > 0 {
+		lines = append(lines, nil, render.Line{render.Plain("🍽 "), render.Bold("Lieblingsoptionen:")})
+		for i, option := range stats.TopOptions {
+			lines = append(lines, render.Line{render.Plain(fmt.Sprintf("%d. %s (%d)", i+1, option.Name, option.Count))})
+		}
+	}
+
+	if len(stats.History) > 0 {
+		lines = append(lines, nil, render.Line{render.Plain("🕓 "), render.Bold("Letzte Bestellungen:")})
+		for _, entry := range stats.History {
+			lines = append(lines, render.Line{render.Plain(fmt.Sprintf("• %s %s: %s",
+				entry.Deadline.In(b.defaults.Location).Format("02.01.2006"),
+				entry.GyroskopName,
+				b.formatOrderQuantities(entry.Quantities, entry.FoodOptions),
+			))})
+		}
+	}
+
+	return messageFormat.Lines(lines...)
+}
+
+// busiestBucket returns the bucket with the highest count (the first one on ties)
+func busiestBucket(buckets []database.BucketCount) (int, bool) {
+	best := -1
+	bestCount := 0
+	for _, bucket := range buckets {
+		if bucket.Count > bestCount {
+			best = bucket.Bucket
+			bestCount = bucket.Count
+		}
+	}
+	return best, best >= 0
+}
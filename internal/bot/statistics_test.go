@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestParseStatisticsArgs(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		args         string
+		wantPersonal bool
+		wantSince    time.Time
+		wantErr      bool
+	}{
+		{
+			name:      "empty args - all time",
+			args:      "",
+			wantSince: time.Time{},
+		},
+		{
+			name:      "week",
+			args:      "woche",
+			wantSince: now.AddDate(0, 0, -7),
+		},
+		{
+			name:      "month uppercase",
+			args:      "Monat",
+			wantSince: now.AddDate(0, 0, -30),
+		},
+		{
+			name:         "personal only",
+			args:         "ich",
+			wantPersonal: true,
+			wantSince:    time.Time{},
+		},
+		{
+			name:         "personal with year",
+			args:         "ich jahr",
+			wantPersonal: true,
+			wantSince:    now.AddDate(0, 0, -365),
+		},
+		{
+			name:      "number of days",
+			args:      "14d",
+			wantSince: now.AddDate(0, 0, -14),
+		},
+		{
+			name:    "number of days separated from unit",
+			args:    "ich 3 tage",
+			wantErr: true,
+		},
+		{
+			name:    "invalid period",
+			args:    "gestern",
+			wantErr: true,
+		},
+		{
+			name:    "zero days",
+			args:    "0d",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			personal, period, err := parseStatisticsArgs(tt.args, now)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStatisticsArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if personal != tt.wantPersonal {
+				t.Errorf("parseStatisticsArgs() personal = %v, want %v", personal, tt.wantPersonal)
+			}
+			if !period.Since.Equal(tt.wantSince) {
+				t.Errorf("parseStatisticsArgs() since = %v, want %v", period.Since, tt.wantSince)
+			}
+		})
+	}
+}
+
+func TestBusiestBucket(t *testing.T) {
+	if _, ok := busiestBucket(nil); ok {
+		t.Error("busiestBucket(nil) should not find a bucket")
+	}
+
+	buckets := []database.BucketCount{{Bucket: 1, Count: 2}, {Bucket: 3, Count: 5}, {Bucket: 5, Count: 5}}
+	if got, ok := busiestBucket(buckets); !ok || got != 3 {
+		t.Errorf("busiestBucket() = %d, %v, want 3, true", got, ok)
+	}
+}
+
+func TestFormatChatStatistics(t *testing.T) {
+	b := newTestBot()
+	period := statisticsPeriod{Label: "letzte 30 Tage"}
+
+	empty := b.formatChatStatistics(period, &database.ChatStatistics{})
+	if !strings.Contains(empty, "Noch keine Gyroskops") {
+		t.Errorf("formatChatStatistics() for empty stats = %q", empty)
+	}
+
+	stats := &database.ChatStatistics{
+		GyroskopCount:   4,
+		OrderCount:      10,
+		ItemCount:       17,
+		AvgParticipants: 2.5,
+		TopNames:        []database.NameCount{{Name: "Gyros", Count: 3}, {Name: "Pizza & *Pasta*", Count: 1}},
+		TopOptions:      []database.NameCount{{Name: "Fleisch", Count: 12}},
+		TopOrderers:     []database.UserCount{{UserID: 1, FirstName: "Anna", Orders: 4, Items: 6}},
+		Weekdays:        []database.BucketCount{{Bucket: 2, Count: 1}, {Bucket: 5, Count: 3}},
+		Hours:           []database.BucketCount{{Bucket: 12, Count: 4}},
+	}
+
+	text := b.formatChatStatistics(period, stats)
+	for _, want := range []string{
+		"Gyroskops: 4",
+		"Bestellungen: 10 (17 Portionen)",
+		"Ø Teilnehmer: 2.5",
+		"1. Gyros (3x)",
+		"2. Pizza &amp; *Pasta* (1x)",
+		"1. Fleisch (12)",
+		"1. Anna: 4 Bestellungen, 6 Portionen",
+		"Wochentag: Freitag",
+		"12:00 - 13:00 Uhr",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatChatStatistics() missing %q in:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// templateResetKeyword resets a template to the default with /vorlage Name standard
+const templateResetKeyword = "standard"
+
+// chatTemplates loads the message templates of a chat. Templates that cannot
+// be parsed are skipped, the default is used instead.
+func (b *Bot) chatTemplates(ctx context.Context, chatID int64) map[string]*render.Template {
+	stored, err := b.db.GetChatTemplates(ctx, chatID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Vorlagen", "error", err)
+		return nil
+	}
+	if len(stored) == 0 {
+		return nil
+	}
+
+	templates := make(map[string]*render.Template, len(stored))
+	for name, text := range stored {
+		tmpl, err := render.ParseTemplate(messageFormat, name, text)
+		if err != nil {
+			b.log.WarnContext(ctx, "Ignoring invalid template", "template", name, "error", err)
+			continue
+		}
+		templates[name] = tmpl
+	}
+	return templates
+}
+
+// parseTemplateArgs splits the arguments of /vorlage into the name of the
+// template and the rest, which keeps its line breaks
+func parseTemplateArgs(args string) (name, rest string) {
+	args = strings.TrimSpace(args)
+	end := strings.IndexAny(args, " \n")
+	if end < 0 {
+		return strings.ToLower(args), ""
+	}
+	return strings.ToLower(args[:end]), strings.TrimSpace(args[end:])
+}
+
+// handleTemplate shows and changes the message templates of the chat
+// Format:
+//
+//	/vorlage -> list the templates
+//	/vorlage Name -> show a template
+//	/vorlage Name Vorlage -> change a template, also as reply to a message with the template (admins only)
+//	/vorlage Name standard -> reset a template to the default (admins only)
+func (b *Bot) handleTemplate(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+	name, text := parseTemplateArgs(args)
+	if name == "" {
+		b.sendFormatted(ctx, chatID, b.formatTemplates(ctx, chatID))
+		return
+	}
+	if !slices.Contains(render.TemplateNames, name) {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("⚠️ Unbekannte Vorlage. Vorlagen: %s", strings.Join(render.TemplateNames, ", ")))
+		return
+	}
+
+	if text == "" && message.ReplyToMessage != nil {
+		text = strings.TrimSpace(message.ReplyToMessage.Text)
+	}
+	if text == "" {
+		b.sendFormatted(ctx, chatID, b.formatTemplate(ctx, chatID, name))
+		return
+	}
+
+	if !b.isChatAdmin(ctx, chatID, message.From.ID) {
+		b.sendMessage(ctx, chatID, "⚠️ Nur Gruppen-Admins können Vorlagen ändern!")
+		return
+	}
+
+	if strings.EqualFold(text, templateResetKeyword) {
+		if err := b.db.DeleteChatTemplate(ctx, chatID, name); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Zurücksetzen der Vorlage", "error", err)
+			b.sendMessage(ctx, chatID, "❌ Fehler beim Zurücksetzen der Vorlage")
+			return
+		}
+		b.settings.invalidate(chatID)
+		b.log.InfoContext(ctx, "Chat template reset", "template", name)
+		b.sendMessage(ctx, chatID, fmt.Sprintf("📝 Vorlage %s zurückgesetzt", name))
+		return
+	}
+
+	tmpl, err := render.ParseTemplate(messageFormat, name, text)
+	if err == nil {
+		err = tmpl.Validate()
+	}
+	if err != nil {
+		b.sendFormatted(ctx, chatID, messageFormat.Escape("⚠️ Die Vorlage ist ungültig: "+err.Error()))
+		return
+	}
+
+	if err := b.db.SaveChatTemplate(ctx, chatID, name, text, message.From.ID); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Speichern der Vorlage", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Speichern der Vorlage")
+		return
+	}
+	b.settings.invalidate(chatID)
+	b.log.InfoContext(ctx, "Chat template changed", "template", name)
+	b.sendMessage(ctx, chatID, fmt.Sprintf("📝 Vorlage %s gespeichert", name))
+}
+
+// formatTemplates lists the templates of a chat and how to change them
+func (b *Bot) formatTemplates(ctx context.Context, chatID int64) string {
+	templates := b.chatSettings(ctx, chatID).Templates
+	lines := []render.Line{{render.Plain("📝 "), render.Bold("Vorlagen")}}
+	for _, name := range render.TemplateNames {
+		state := "Standard"
+		if _, ok := templates[name]; ok {
+			state = "angepasst"
+		}
+		lines = append(lines, render.Line{render.Plain(fmt.Sprintf("• %s (%s)", name, state))})
+	}
+	lines = append(lines, nil,
+		render.Line{render.Plain("Anzeigen: /vorlage Name")},
+		render.Line{render.Plain("Ändern: /vorlage Name gefolgt von der Vorlage (nur Admins)")},
+		render.Line{render.Plain("Zurücksetzen: /vorlage Name " + templateResetKeyword)},
+	)
+	return messageFormat.Lines(lines...)
+}
+
+// formatTemplate shows the source of the template a chat uses for a message
+func (b *Bot) formatTemplate(ctx context.Context, chatID int64, name string) string {
+	tmpl, ok := b.chatSettings(ctx, chatID).Templates[name]
+	title := fmt.Sprintf("📝 Vorlage %s (angepasst)", name)
+	if !ok {
+		tmpl = render.DefaultTemplate(name)
+		title = fmt.Sprintf("📝 Vorlage %s (Standard)", name)
+	}
+	return withTitle(messageFormat.Line(render.Line{render.Pre(tmpl.Text())}), title)
+}
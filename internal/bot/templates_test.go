@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+func TestParseTemplateArgs(t *testing.T) {
+	tests := []struct {
+		args     string
+		wantName string
+		wantRest string
+	}{
+		{"", "", ""},
+		{"Status", "status", ""},
+		{"status standard", "status", "standard"},
+		{"status\n<b>{{.Total}}</b>\n{{.Creator}}", "status", "<b>{{.Total}}</b>\n{{.Creator}}"},
+	}
+
+	for _, tt := range tests {
+		name, rest := parseTemplateArgs(tt.args)
+		if name != tt.wantName || rest != tt.wantRest {
+			t.Errorf("parseTemplateArgs(%q) = %q, %q, want %q, %q", tt.args, name, rest, tt.wantName, tt.wantRest)
+		}
+	}
+}
+
+func TestRenderMessageWithChatTemplate(t *testing.T) {
+	b := newTestBot()
+	b.log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	gyroskop := &database.Gyroskop{
+		ChatID:      1,
+		Name:        "Pizza_Abend",
+		FoodOptions: []string{"Margherita"},
+		Deadline:    time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+	}
+	orders := []database.Order{{UserID: 1, FirstName: "Anna", Quantities: map[string]int{"Margherita": 2}}}
+
+	custom, err := render.ParseTemplate(messageFormat, render.TemplateStatus, "<b>{{.Gyroskop.Name}}</b>: {{.Total}} bis {{.Deadline.Format \"15:04\"}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := b.defaults.settings(nil)
+	settings.Templates = map[string]*render.Template{render.TemplateStatus: custom}
+	b.settings.set(gyroskop.ChatID, settings)
+
+	if got, want := b.statusMessage(ctx, gyroskop, orders), "<b>Pizza_Abend</b>: 2 bis 12:00"; got != want {
+		t.Errorf("statusMessage() with chat template = %q, want %q", got, want)
+	}
+
+	// A template failing on real data falls back to the default
+	broken, err := render.ParseTemplate(messageFormat, render.TemplateStatus, "{{(index .Orders 3).Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.Templates = map[string]*render.Template{render.TemplateStatus: broken}
+	b.settings.set(gyroskop.ChatID, settings)
+
+	if got := b.statusMessage(ctx, gyroskop, orders); !strings.Contains(got, "Aktueller Status") {
+		t.Errorf("statusMessage() with broken chat template = %q, want the default", got)
+	}
+}
@@ -0,0 +1,452 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/logging"
+	"github.com/tionis/gyroskop/internal/render"
+)
+
+// Votes on where to order
+//
+// /abstimmung starts a timed vote with inline buttons between free-text
+// candidates, @Restaurants or, without candidates, the names of the latest
+// gyroskops of the chat. When the vote closes the winner opens a gyroskop with
+// that name and the options last used with it. Ties go to the candidate that
+// reached the winning number of votes first, then to the one listed first.
+const voteCallbackPrefix = "v:" // v:<voteID>:<candidate index>, v:<voteID>:ende
+
+// Limits of a vote
+const (
+	defaultVoteDuration = 10 * time.Minute
+	maxVoteCandidates   = 8
+	maxCandidateLength  = 40
+)
+
+var errInvalidVote = errors.New("invalid vote")
+
+// parseVoteArgs parses the arguments of /abstimmung
+// Format: [Dauer], Kandidat1, Kandidat2, ...
+// Examples:
+//
+//	/abstimmung -> 10min between the latest gyroskops of the chat
+//	/abstimmung Pizza, Döner, @Luigi -> 10min between the given candidates
+//	/abstimmung 5min, Pizza, Döner -> 5min between the given candidates
+func (b *Bot) parseVoteArgs(args string) (time.Time, []string, error) {
+	deadline := time.Now().Add(defaultVoteDuration)
+
+	var candidates []string
+	seen := make(map[string]bool)
+	for i, part := range strings.Split(args, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if i == 0 {
+			if d, err := b.parseDeadline(part, defaultVoteDuration); err == nil {
+				deadline = d
+				continue
+			}
+		}
+		if len([]rune(part)) > maxCandidateLength {
+			return time.Time{}, nil, fmt.Errorf("%w: candidate too long", errInvalidVote)
+		}
+		if key := strings.ToLower(part); !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, part)
+		}
+	}
+
+	if len(candidates) > maxVoteCandidates {
+		return time.Time{}, nil, fmt.Errorf("%w: too many candidates", errInvalidVote)
+	}
+	return deadline, candidates, nil
+}
+
+// voteResult counts the ballots of a vote. winner is -1 if nobody voted and
+// tie is set if several candidates had the most votes.
+func voteResult(candidates []string, ballots []database.Ballot) (counts []int, winner int, tie bool) {
+	counts = make([]int, len(candidates))
+	reached := make([]time.Time, len(candidates)) // When a candidate got its last vote
+	for _, ballot := range ballots {
+		if ballot.Candidate < 0 || ballot.Candidate >= len(candidates) {
+			continue
+		}
+		counts[ballot.Candidate]++
+		if ballot.VotedAt.After(reached[ballot.Candidate]) {
+			reached[ballot.Candidate] = ballot.VotedAt
+		}
+	}
+
+	winner = -1
+	for i, count := range counts {
+		switch {
+		case count == 0:
+		case winner < 0 || count > counts[winner]:
+			winner, tie = i, false
+		case count == counts[winner]:
+			tie = true
+			if reached[i].Before(reached[winner]) {
+				winner = i
+			}
+		}
+	}
+	return counts, winner, tie
+}
+
+// formatVote formats the vote message with the current standings
+func (b *Bot) formatVote(vote *database.Vote, ballots []database.Ballot) string {
+	counts, winner, tie := voteResult(vote.Candidates, ballots)
+
+	var lines []render.Line
+	if vote.IsOpen {
+		lines = append(lines,
+			render.Line{render.Plain("🗳 "), render.Bold("Abstimmung: Wo bestellen wir?")},
+			render.Line{render.Plain("👤 Gestartet von: " + vote.CreatedByName)},
+			render.Line{render.Plain(fmt.Sprintf("⏰ Bis %s Uhr", vote.Deadline.In(b.defaults.Location).Format("15:04")))},
+		)
+	} else {
+		lines = append(lines, render.Line{render.Plain("🗳 "), render.Bold("Abstimmung beendet!")})
+	}
+	lines = append(lines, nil)
+
+	for i, candidate := range vote.Candidates {
+		var voters []string
+		for _, ballot := range ballots {
+			if ballot.Candidate == i {
+				voters = append(voters, ballot.Name)
+			}
+		}
+
+		marker := "▫️"
+		if !vote.IsOpen && i == winner {
+			marker = "🏆"
+		}
+		text := fmt.Sprintf("%s %s: %d", marker, candidate, counts[i])
+		if len(voters) > 0 {
+			text += " (" + strings.Join(voters, ", ") + ")"
+		}
+		lines = append(lines, render.Line{render.Plain(text)})
+	}
+
+	switch {
+	case vote.IsOpen:
+		lines = append(lines, nil, render.Line{render.Plain("Danach wird automatisch ein Gyroskop mit dem Gewinner geöffnet.")})
+	case winner < 0:
+		lines = append(lines, nil, render.Line{render.Plain("Niemand hat abgestimmt.")})
+	case tie:
+		lines = append(lines, nil, render.Line{render.Plain(fmt.Sprintf("⚖️ Gleichstand – %s hat die Stimmenzahl zuerst erreicht.", vote.Candidates[winner]))})
+	}
+	return messageFormat.Lines(lines...)
+}
+
+// voteKeyboard returns one button per candidate and a button to end the vote
+func voteKeyboard(vote *database.Vote, ballots []database.Ballot) tgbotapi.InlineKeyboardMarkup {
+	counts, _, _ := voteResult(vote.Candidates, ballots)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, candidate := range vote.Candidates {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%s (%d)", candidate, counts[i]),
+			fmt.Sprintf("%s%d:%d", voteCallbackPrefix, vote.ID, i),
+		)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+		"🏁 Abstimmung beenden", fmt.Sprintf("%s%d:ende", voteCallbackPrefix, vote.ID),
+	)))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleVote starts a vote or ends the open one
+// Format: /abstimmung [Dauer], Kandidat1, Kandidat2, ... or /abstimmung ende
+func (b *Bot) handleVote(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+
+	open, err := b.db.GetOpenVote(ctx, chatID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Abstimmung", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Abstimmung")
+		return
+	}
+
+	if strings.EqualFold(strings.TrimSpace(args), "ende") {
+		if open == nil {
+			b.sendMessage(ctx, chatID, "❌ Keine laufende Abstimmung in dieser Gruppe")
+			return
+		}
+		if !b.mayEndVote(ctx, open, message.From.ID) {
+			b.sendMessage(ctx, chatID, "⚠️ Nur wer die Abstimmung gestartet hat und Admins können sie beenden!")
+			return
+		}
+		b.closeVote(ctx, open)
+		return
+	}
+
+	if open != nil {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("⚠️ Es läuft bereits eine Abstimmung bis %s Uhr. Beenden mit /abstimmung ende",
+			open.Deadline.In(b.defaults.Location).Format("15:04")))
+		return
+	}
+	if _, exists := b.getActiveGyroskop(chatID); exists {
+		b.sendMessage(ctx, chatID, "⚠️ Es gibt bereits ein aktives Gyroskop. Beende es zuerst mit /ende.")
+		return
+	}
+	if b.chatSettings(ctx, chatID).OpenPermission == openByAdmins && !b.isChatAdmin(ctx, chatID, message.From.ID) {
+		b.sendMessage(ctx, chatID, "⚠️ In dieser Gruppe können nur Admins ein Gyroskop öffnen!")
+		return
+	}
+
+	deadline, candidates, err := b.parseVoteArgs(args)
+	if err != nil {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("⚠️ Ungültiges Format. Verwende: /abstimmung [Dauer], Kandidat1, Kandidat2, ... (höchstens %d Kandidaten mit je %d Zeichen)",
+			maxVoteCandidates, maxCandidateLength))
+		return
+	}
+
+	// Without candidates the latest gyroskops of the chat are suggested
+	if len(candidates) == 0 {
+		candidates, err = b.db.GetRecentGyroskopNames(ctx, chatID, maxVoteCandidates)
+		if err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Laden der Gyroskops", "error", err)
+			b.sendMessage(ctx, chatID, "❌ Fehler beim Laden der Vorschläge")
+			return
+		}
+	}
+	if len(candidates) < 2 {
+		b.sendMessage(ctx, chatID, "⚠️ Eine Abstimmung braucht mindestens zwei Kandidaten, z.B. /abstimmung 10min, Pizza, Döner, @Luigi")
+		return
+	}
+
+	vote := &database.Vote{
+		ChatID:        chatID,
+		CreatedBy:     message.From.ID,
+		CreatedByName: b.getUserName(message.From),
+		Candidates:    candidates,
+		Deadline:      deadline,
+	}
+	if err := b.db.CreateVote(ctx, vote); err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Starten der Abstimmung", "error", err)
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Starten der Abstimmung")
+		return
+	}
+	b.log.InfoContext(ctx, "Vote started", "vote_id", vote.ID, "candidates", len(candidates), "deadline", deadline)
+
+	msg := tgbotapi.NewMessage(chatID, b.formatVote(vote, nil))
+	msg.ParseMode = messageFormat.ParseMode()
+	msg.ReplyMarkup = voteKeyboard(vote, nil)
+	sent, err := b.send(ctx, chatID, msg)
+	if err != nil {
+		// Nobody can vote without the message, so it must not block new votes
+		b.log.ErrorContext(ctx, "Fehler beim Senden der Abstimmung", "error", err)
+		if _, err := b.db.CloseVote(ctx, vote.ID, ""); err != nil {
+			b.log.ErrorContext(ctx, "Fehler beim Beenden der Abstimmung", "error", err)
+		}
+		b.sendMessage(ctx, chatID, "❌ Fehler beim Starten der Abstimmung")
+		return
+	}
+	if err := b.db.UpdateVoteMessageID(ctx, vote.ID, sent.MessageID); err != nil {
+		b.log.ErrorContext(ctx, "Error saving vote message ID", "message_id", sent.MessageID, "error", err)
+	}
+}
+
+// mayEndVote checks whether the user started the vote or is a chat admin
+func (b *Bot) mayEndVote(ctx context.Context, vote *database.Vote, userID int64) bool {
+	return vote.CreatedBy == userID || b.isChatAdmin(ctx, vote.ChatID, userID)
+}
+
+// handleVoteCallback handles the buttons of the vote message
+func (b *Bot) handleVoteCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(query.Data, voteCallbackPrefix), ":")
+	voteID, err := strconv.Atoi(id)
+	if err != nil {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültige Callback-Daten")
+		return
+	}
+
+	vote, err := b.db.GetVote(ctx, voteID)
+	if err != nil || vote.ChatID != query.Message.Chat.ID {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Abstimmung nicht gefunden")
+		return
+	}
+	if !vote.IsOpen {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Die Abstimmung ist bereits beendet")
+		return
+	}
+
+	if action == "ende" {
+		if !b.mayEndVote(ctx, vote, query.From.ID) {
+			b.answerCallbackQuery(ctx, query.ID, "⚠️ Nur wer die Abstimmung gestartet hat und Admins können sie beenden!")
+			return
+		}
+		b.answerCallbackQuery(ctx, query.ID, "🏁 Abstimmung beendet")
+		b.closeVote(ctx, vote)
+		return
+	}
+
+	candidate, err := strconv.Atoi(action)
+	if err != nil || candidate < 0 || candidate >= len(vote.Candidates) {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Ungültiger Kandidat")
+		return
+	}
+
+	counted, err := b.db.CastBallot(ctx, vote.ID, query.From.ID, b.getUserName(query.From), candidate)
+	if errors.Is(err, database.ErrVoteClosed) {
+		b.answerCallbackQuery(ctx, query.ID, "❌ Die Abstimmung ist bereits beendet")
+		return
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Abstimmen", "error", err)
+		b.answerCallbackQuery(ctx, query.ID, "❌ Fehler beim Abstimmen")
+		return
+	}
+
+	if counted {
+		b.answerCallbackQuery(ctx, query.ID, fmt.Sprintf("✅ Stimme für %s", vote.Candidates[candidate]))
+	} else {
+		b.answerCallbackQuery(ctx, query.ID, "↩️ Stimme zurückgenommen")
+	}
+	b.updateVoteMessage(ctx, vote)
+}
+
+// updateVoteMessage shows the current standings in the vote message
+func (b *Bot) updateVoteMessage(ctx context.Context, vote *database.Vote) {
+	if vote.MessageID == 0 {
+		return
+	}
+
+	b.outbox.Edit(ctx, vote.ChatID, vote.MessageID, func(ctx context.Context) (tgbotapi.Chattable, error) {
+		ballots, err := b.db.GetBallots(ctx, vote.ID)
+		if err != nil {
+			return nil, fmt.Errorf("loading ballots: %w", err)
+		}
+
+		edit := tgbotapi.NewEditMessageText(vote.ChatID, vote.MessageID, b.formatVote(vote, ballots))
+		edit.ParseMode = messageFormat.ParseMode()
+		if vote.IsOpen {
+			keyboard := voteKeyboard(vote, ballots)
+			edit.ReplyMarkup = &keyboard
+		} else {
+			edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		}
+		return edit, nil
+	})
+}
+
+// closeVote ends a vote and opens a gyroskop for the winner
+func (b *Bot) closeVote(ctx context.Context, vote *database.Vote) {
+	ctx = logging.With(ctx, "vote_id", vote.ID)
+
+	ballots, err := b.db.GetBallots(ctx, vote.ID)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Laden der Stimmen", "error", err)
+		return
+	}
+	_, winner, _ := voteResult(vote.Candidates, ballots)
+	winnerName := ""
+	if winner >= 0 {
+		winnerName = vote.Candidates[winner]
+	}
+
+	// Only one of the expiry checker and the end button closes the vote
+	closed, err := b.db.CloseVote(ctx, vote.ID, winnerName)
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Beenden der Abstimmung", "error", err)
+		return
+	}
+	if !closed {
+		return
+	}
+	vote.IsOpen = false
+	vote.Winner = winnerName
+	b.log.InfoContext(ctx, "Vote closed", "winner", winnerName, "ballots", len(ballots))
+
+	b.updateVoteMessage(ctx, vote)
+
+	if winner < 0 {
+		b.sendMessage(ctx, vote.ChatID, "🗳 Niemand hat abgestimmt, es wird kein Gyroskop geöffnet.")
+		return
+	}
+	if _, exists := b.getActiveGyroskop(vote.ChatID); exists {
+		b.sendFormatted(ctx, vote.ChatID, messageFormat.Line(render.Line{
+			render.Plain("🏆 "), render.Bold(winnerName), render.Plain(" hat gewonnen! Es läuft aber schon ein Gyroskop, daher wird kein neues geöffnet."),
+		}))
+		return
+	}
+
+	gyroskop, err := b.openVoteWinner(ctx, vote, winnerName)
+	if errors.Is(err, ErrGyroskopActive) {
+		b.sendFormatted(ctx, vote.ChatID, messageFormat.Line(render.Line{
+			render.Plain("🏆 "), render.Bold(winnerName), render.Plain(" hat gewonnen! Es läuft aber schon ein Gyroskop, daher wird kein neues geöffnet."),
+		}))
+		return
+	}
+	if err != nil {
+		b.log.ErrorContext(ctx, "Fehler beim Erstellen des Gyroskops", "error", err)
+		b.sendFormatted(ctx, vote.ChatID, messageFormat.Line(render.Line{
+			render.Plain("🏆 "), render.Bold(winnerName), render.Plain(" hat gewonnen, aber das Gyroskop konnte nicht geöffnet werden."),
+		}))
+		return
+	}
+	if err := b.db.SetVoteGyroskop(ctx, vote.ID, gyroskop.ID); err != nil {
+		b.log.ErrorContext(ctx, "Error saving vote gyroskop", "gyroskop_id", gyroskop.ID, "error", err)
+	}
+}
+
+// openVoteWinner opens a gyroskop for the winner of a vote on behalf of whoever
+// started the vote. @Name uses the menu of a restaurant, any other name the
+// options and restaurant of the latest gyroskop with that name.
+func (b *Bot) openVoteWinner(ctx context.Context, vote *database.Vote, winner string) (*database.Gyroskop, error) {
+	settings := b.chatSettings(ctx, vote.ChatID)
+	name, foodOptions := winner, settings.FoodOptions
+
+	var restaurant *database.Restaurant
+	if restaurantName, isRestaurant := strings.CutPrefix(winner, "@"); isRestaurant {
+		r, err := b.db.GetRestaurantByName(ctx, vote.ChatID, restaurantName)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		name = restaurantName
+		if r != nil {
+			restaurant = r
+			withMenu := settings.withRestaurant(r)
+			name, foodOptions = withMenu.Name, withMenu.FoodOptions
+		}
+	} else {
+		previous, err := b.db.GetLatestGyroskopByName(ctx, vote.ChatID, winner)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if previous != nil {
+			name, foodOptions = previous.Name, previous.FoodOptions
+			restaurant = b.gyroskopRestaurant(ctx, previous)
+		}
+	}
+
+	creator := &tgbotapi.User{ID: vote.CreatedBy, FirstName: vote.CreatedByName}
+	return b.openGyroskop(ctx, vote.ChatID, creator, name, foodOptions, time.Now().Add(settings.Duration), false, restaurant, "vote")
+}
+
+// checkExpiredVotes closes all votes whose deadline has passed
+func (b *Bot) checkExpiredVotes(ctx context.Context) {
+	votes, err := b.db.GetOpenVotes(ctx)
+	if err != nil {
+		b.log.Error("Error checking open votes", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range votes {
+		vote := &votes[i]
+		if vote.Deadline.Before(now) {
+			b.spawn(func() { b.closeVote(logging.With(ctx, "chat_id", vote.ChatID), vote) })
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func TestParseVoteArgs(t *testing.T) {
+	b := newTestBot()
+
+	tests := []struct {
+		args           string
+		wantCandidates []string
+		wantDuration   time.Duration
+		wantErr        bool
+	}{
+		{"", nil, defaultVoteDuration, false},
+		{"Pizza, Döner, @Luigi", []string{"Pizza", "Döner", "@Luigi"}, defaultVoteDuration, false},
+		{"5min, Pizza, Döner", []string{"Pizza", "Döner"}, 5 * time.Minute, false},
+		{"Pizza, pizza, Döner", []string{"Pizza", "Döner"}, defaultVoteDuration, false},
+		{"a, b, c, d, e, f, g, h, i", nil, 0, true},
+		{"Pizza, " + strings.Repeat("x", maxCandidateLength+1), nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			deadline, candidates, err := b.parseVoteArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVoteArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(candidates, tt.wantCandidates) {
+				t.Errorf("parseVoteArgs() candidates = %v, want %v", candidates, tt.wantCandidates)
+			}
+			if d := time.Until(deadline); d < tt.wantDuration-time.Minute || d > tt.wantDuration {
+				t.Errorf("parseVoteArgs() deadline in %v, want %v", d, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestVoteResult(t *testing.T) {
+	candidates := []string{"Pizza", "Döner", "Burger"}
+	at := func(minute int) time.Time { return time.Date(2024, 3, 1, 12, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		ballots    []database.Ballot
+		wantCounts []int
+		wantWinner int
+		wantTie    bool
+	}{
+		{"no votes", nil, []int{0, 0, 0}, -1, false},
+		{"clear winner", []database.Ballot{
+			{UserID: 1, Candidate: 1, VotedAt: at(1)},
+			{UserID: 2, Candidate: 1, VotedAt: at(2)},
+			{UserID: 3, Candidate: 0, VotedAt: at(3)},
+		}, []int{1, 2, 0}, 1, false},
+		{"tie goes to who reached the count first", []database.Ballot{
+			{UserID: 1, Candidate: 2, VotedAt: at(1)},
+			{UserID: 2, Candidate: 0, VotedAt: at(2)},
+			{UserID: 3, Candidate: 2, VotedAt: at(3)},
+			{UserID: 4, Candidate: 0, VotedAt: at(4)},
+		}, []int{2, 0, 2}, 2, true},
+		{"tie at the same time goes to the first listed", []database.Ballot{
+			{UserID: 1, Candidate: 1, VotedAt: at(1)},
+			{UserID: 2, Candidate: 2, VotedAt: at(1)},
+		}, []int{0, 1, 1}, 1, true},
+		{"a lower tie does not count", []database.Ballot{
+			{UserID: 1, Candidate: 0, VotedAt: at(1)},
+			{UserID: 2, Candidate: 1, VotedAt: at(2)},
+			{UserID: 3, Candidate: 2, VotedAt: at(3)},
+			{UserID: 4, Candidate: 2, VotedAt: at(4)},
+		}, []int{1, 1, 2}, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts, winner, tie := voteResult(candidates, tt.ballots)
+			if !reflect.DeepEqual(counts, tt.wantCounts) || winner != tt.wantWinner || tie != tt.wantTie {
+				t.Errorf("voteResult() = %v, %d, %v, want %v, %d, %v", counts, winner, tie, tt.wantCounts, tt.wantWinner, tt.wantTie)
+			}
+		})
+	}
+}
+
+func TestFormatVote(t *testing.T) {
+	b := newTestBot()
+	vote := &database.Vote{
+		ID:            123456,
+		CreatedByName: "Anna",
+		Candidates:    []string{"Pizza", strings.Repeat("D", maxCandidateLength)},
+		Deadline:      time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC),
+		IsOpen:        true,
+	}
+	ballots := []database.Ballot{{UserID: 1, Name: "Ben", Candidate: 0, VotedAt: time.Now()}}
+
+	text := b.formatVote(vote, ballots)
+	for _, want := range []string{"Wo bestellen wir?", "Anna", "12:30 Uhr", "Pizza: 1 (Ben)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatVote() = %q, missing %q", text, want)
+		}
+	}
+
+	for _, row := range voteKeyboard(vote, ballots).InlineKeyboard {
+		for _, button := range row {
+			if data := *button.CallbackData; len(data) > 64 || !strings.HasPrefix(data, voteCallbackPrefix) {
+				t.Errorf("Invalid callback data %q", data)
+			}
+		}
+	}
+
+	vote.IsOpen = false
+	if text := b.formatVote(vote, ballots); !strings.Contains(text, "🏆 Pizza") || strings.Contains(text, "Danach wird") {
+		t.Errorf("formatVote() of a closed vote = %q", text)
+	}
+}
+
+func TestFormatVoteEscapes(t *testing.T) {
+	b := newTestBot()
+	vote := &database.Vote{
+		CreatedByName: "Anna_<b>",
+		Candidates:    []string{"*Pizza*", "Döner & Co"},
+		Deadline:      time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC),
+		IsOpen:        true,
+	}
+	ballots := []database.Ballot{{UserID: 1, Name: "<Ben>", Candidate: 1, VotedAt: time.Now()}}
+
+	text := b.formatVote(vote, ballots)
+	for _, want := range []string{"Anna_&lt;b&gt;", "*Pizza*: 0", "Döner &amp; Co: 1 (&lt;Ben&gt;)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("formatVote() = %q, missing %q", text, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/metrics"
+)
+
+// secretTokenHeader is the header Telegram sends the webhook secret token in
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// secretTokenRegex matches the characters and length Telegram allows for secret tokens
+var secretTokenRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// WebhookConfig configures receiving updates via webhook instead of long polling
+type WebhookConfig struct {
+	URL         string // Public HTTPS URL Telegram sends updates to
+	ListenAddr  string // Local address of the embedded HTTP listener, e.g. ":8443"
+	SecretToken string // Secret token Telegram sends with every update (generated if empty)
+}
+
+// webhook is the state of a running webhook listener
+type webhook struct {
+	config  WebhookConfig
+	server  *http.Server
+	updates chan tgbotapi.Update
+	failed  chan error // Receives the error if the listener stops serving
+}
+
+// UseWebhook makes Run receive updates via webhook instead of long polling
+func (b *Bot) UseWebhook(config WebhookConfig) error {
+	if config.URL == "" {
+		return fmt.Errorf("webhook URL is required")
+	}
+	if _, err := url.Parse(config.URL); err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if config.ListenAddr == "" {
+		config.ListenAddr = ":8443"
+	}
+
+	if config.SecretToken == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		config.SecretToken = hex.EncodeToString(secret)
+	}
+	if !secretTokenRegex.MatchString(config.SecretToken) {
+		return fmt.Errorf("webhook secret token must be 1-256 characters of A-Z, a-z, 0-9, _ and -")
+	}
+
+	b.webhook = &webhook{config: config}
+	return nil
+}
+
+// startWebhook starts the HTTP listener and registers the webhook with Telegram.
+// The address is bound before Telegram is told to send updates to it.
+func (b *Bot) startWebhook() (tgbotapi.UpdatesChannel, error) {
+	webhookURL, err := url.Parse(b.webhook.config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	path := webhookURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	listener, err := net.Listen("tcp", b.webhook.config.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("webhook listener: %w", err)
+	}
+
+	updates := make(chan tgbotapi.Update, b.api.Buffer)
+	mux := http.NewServeMux()
+	mux.Handle(path, newWebhookHandler(b.webhook.config.SecretToken, func(update tgbotapi.Update) {
+		updates <- update
+	}))
+
+	b.webhook.updates = updates
+	b.webhook.failed = make(chan error, 1)
+	b.webhook.server = &http.Server{
+		Addr:              b.webhook.config.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		b.log.Info("Webhook listening", "addr", listener.Addr().String(), "path", path)
+		if err := b.webhook.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			b.webhook.failed <- fmt.Errorf("webhook listener: %w", err)
+		}
+	}()
+
+	_, err = b.api.MakeRequest("setWebhook", tgbotapi.Params{
+		"url":          b.webhook.config.URL,
+		"secret_token": b.webhook.config.SecretToken,
+	})
+	if err != nil {
+		metrics.TelegramAPIErrors.Inc("setWebhook")
+		b.webhook.server.Close()
+		return nil, fmt.Errorf("setWebhook failed: %w", err)
+	}
+
+	b.log.Info("Webhook registered", "url", b.webhook.config.URL)
+	return updates, nil
+}
+
+// stopWebhook stops the HTTP listener and removes the webhook from Telegram
+func (b *Bot) stopWebhook() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.webhook.server.Shutdown(ctx); err != nil {
+		b.log.Error("Error stopping webhook listener", "error", err)
+	}
+
+	// No handler can send anymore after Shutdown returned
+	close(b.webhook.updates)
+
+	b.deleteWebhook()
+}
+
+// deleteWebhook removes a registered webhook from Telegram. It bypasses the
+// outbox, which is closed independently during shutdown.
+func (b *Bot) deleteWebhook() {
+	if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		metrics.TelegramAPIErrors.Inc("deleteWebhook")
+		b.log.Error("Error deleting webhook", "error", err)
+	}
+}
+
+// newWebhookHandler returns an HTTP handler that verifies the secret token
+// and passes decoded updates to dispatch
+func newWebhookHandler(secretToken string, dispatch func(tgbotapi.Update)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), []byte(secretToken)) != 1 {
+			http.Error(w, "invalid secret token", http.StatusUnauthorized)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+
+		dispatch(update)
+		w.WriteHeader(http.StatusOK)
+	})
+}
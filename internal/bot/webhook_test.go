@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	recorded, err := os.ReadFile("testdata/update_order.json")
+	if err != nil {
+		t.Fatalf("Error reading recorded update: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		secret       string
+		body         string
+		wantStatus   int
+		wantDispatch bool
+	}{
+		{"recorded update", http.MethodPost, "s3cret", string(recorded), http.StatusOK, true},
+		{"missing secret", http.MethodPost, "", string(recorded), http.StatusUnauthorized, false},
+		{"wrong secret", http.MethodPost, "wrong", string(recorded), http.StatusUnauthorized, false},
+		{"invalid json", http.MethodPost, "s3cret", "{", http.StatusBadRequest, false},
+		{"wrong method", http.MethodGet, "s3cret", "", http.StatusMethodNotAllowed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dispatched []tgbotapi.Update
+			handler := newWebhookHandler("s3cret", func(update tgbotapi.Update) {
+				dispatched = append(dispatched, update)
+			})
+
+			req := httptest.NewRequest(tt.method, "/telegram", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				req.Header.Set(secretTokenHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if (len(dispatched) == 1) != tt.wantDispatch {
+				t.Fatalf("dispatched %d updates, want dispatch %v", len(dispatched), tt.wantDispatch)
+			}
+			if tt.wantDispatch {
+				update := dispatched[0]
+				if update.UpdateID != 100000001 || update.Message == nil || update.Message.Text != "2 fleisch" {
+					t.Errorf("dispatched unexpected update: %+v", update)
+				}
+			}
+		})
+	}
+}
+
+func TestUseWebhook(t *testing.T) {
+	b := newTestBot()
+
+	if err := b.UseWebhook(WebhookConfig{}); err == nil {
+		t.Error("UseWebhook() without URL should fail")
+	}
+
+	if err := b.UseWebhook(WebhookConfig{URL: "https://example.com/telegram", SecretToken: "not allowed!"}); err == nil {
+		t.Error("UseWebhook() with invalid secret token should fail")
+	}
+
+	if err := b.UseWebhook(WebhookConfig{URL: "https://example.com/telegram"}); err != nil {
+		t.Fatalf("UseWebhook() error = %v", err)
+	}
+	if b.webhook.config.ListenAddr != ":8443" {
+		t.Errorf("ListenAddr = %q, want default :8443", b.webhook.config.ListenAddr)
+	}
+	if !secretTokenRegex.MatchString(b.webhook.config.SecretToken) {
+		t.Errorf("generated secret token %q is not valid", b.webhook.config.SecretToken)
+	}
+}
+
+func TestStartWebhookAddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	defer listener.Close()
+
+	b := newTestBot()
+	if err := b.UseWebhook(WebhookConfig{URL: "https://example.com/telegram", ListenAddr: listener.Addr().String()}); err != nil {
+		t.Fatalf("UseWebhook() error = %v", err)
+	}
+
+	// Telegram must not be asked to send updates to an address that is not served
+	if _, err := b.startWebhook(); err == nil {
+		t.Error("startWebhook() on an address in use should fail")
+	}
+}
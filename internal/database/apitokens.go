@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// APIToken is an API token granting access to the gyroskops of a single chat
+type APIToken struct {
+	ChatID        int64  `json:"chat_id"`
+	CreatedBy     int64  `json:"created_by"`
+	CreatedByName string `json:"created_by_name"`
+}
+
+// apiTokenPrefix makes tokens recognisable, e.g. in secret scanners
+const apiTokenPrefix = "gyr_"
+
+// CreateAPIToken creates a new API token for a chat and returns it in plain text.
+// Only a SHA-256 hash of the token is stored.
+func (db *DB) CreateAPIToken(ctx context.Context, chatID, createdBy int64, createdByName string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(secret)
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO api_tokens (token_hash, chat_id, created_by, created_by_name)
+		VALUES ($1, $2, $3, $4)`,
+		hashAPIToken(token), chatID, createdBy, createdByName,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GetAPIToken looks up an API token, returning sql.ErrNoRows for unknown tokens
+func (db *DB) GetAPIToken(ctx context.Context, token string) (*APIToken, error) {
+	var t APIToken
+	err := db.QueryRowContext(ctx, `
+		SELECT chat_id, created_by, created_by_name FROM api_tokens WHERE token_hash = $1`,
+		hashAPIToken(token),
+	).Scan(&t.ChatID, &t.CreatedBy, &t.CreatedByName)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RevokeAPIToken deletes a single API token and returns whether it existed
+func (db *DB) RevokeAPIToken(ctx context.Context, token string) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM api_tokens WHERE token_hash = $1`, hashAPIToken(token))
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// RevokeAPITokens deletes all API tokens of a chat and returns how many were deleted
+func (db *DB) RevokeAPITokens(ctx context.Context, chatID int64) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM api_tokens WHERE chat_id = $1`, chatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// hashAPIToken returns the hex encoded SHA-256 hash of a token
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
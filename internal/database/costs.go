@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// Cost is an extra cost of a gyroskop like a delivery fee or a tip. Negative
+// amounts are discounts.
+type Cost struct {
+	Label   string `json:"label"`
+	Amount  int    `json:"amount,omitempty"`  // In cents, unused if Percent is set
+	Percent int    `json:"percent,omitempty"` // Of the entered amounts, e.g. for a tip
+}
+
+// Costs are the extra costs of a gyroskop and how they are split
+type Costs struct {
+	GyroskopID int
+	Extras     []Cost
+	Split      string        // Split strategy, empty for the default of the chat
+	Amounts    map[int64]int // Amount per user in cents as entered by the collector
+}
+
+// IsEmpty reports whether nothing was entered
+func (c *Costs) IsEmpty() bool {
+	return len(c.Extras) == 0 && c.Split == "" && len(c.Amounts) == 0
+}
+
+// GetGyroskopCosts returns the costs of a gyroskop. A gyroskop without costs
+// gets empty costs, not an error.
+func (db *DB) GetGyroskopCosts(ctx context.Context, gyroskopID int) (*Costs, error) {
+	var extrasJSON, amountsJSON []byte
+	var split string
+	err := db.QueryRowContext(ctx, `
+		SELECT extras, split, amounts FROM gyroskop_costs WHERE gyroskop_id = $1`,
+		gyroskopID,
+	).Scan(&extrasJSON, &split, &amountsJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Costs{GyroskopID: gyroskopID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Costs{GyroskopID: gyroskopID, Split: split}
+	if err := json.Unmarshal(extrasJSON, &c.Extras); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(amountsJSON, &c.Amounts); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SaveGyroskopCosts stores the costs of a gyroskop, replacing the previous
+// ones. Empty costs are deleted.
+func (db *DB) SaveGyroskopCosts(ctx context.Context, c *Costs, updatedBy int64) error {
+	if c.IsEmpty() {
+		_, err := db.ExecContext(ctx, `DELETE FROM gyroskop_costs WHERE gyroskop_id = $1`, c.GyroskopID)
+		return err
+	}
+
+	extras := c.Extras
+	if extras == nil {
+		extras = []Cost{}
+	}
+	extrasJSON, err := json.Marshal(extras)
+	if err != nil {
+		return err
+	}
+	amounts := c.Amounts
+	if amounts == nil {
+		amounts = map[int64]int{}
+	}
+	amountsJSON, err := json.Marshal(amounts)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO gyroskop_costs (gyroskop_id, extras, split, amounts, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (gyroskop_id) DO UPDATE SET
+			extras = EXCLUDED.extras,
+			split = EXCLUDED.split,
+			amounts = EXCLUDED.amounts,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at`,
+		c.GyroskopID, string(extrasJSON), c.Split, string(amountsJSON), updatedBy,
+	)
+	return err
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGyroskopCosts(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	gyroskop, err := db.CreateGyroskop(ctx, 12345, 67890, "Pizza", []string{"Margherita"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+
+	// A gyroskop without costs gets empty costs
+	costs, err := db.GetGyroskopCosts(ctx, gyroskop.ID)
+	if err != nil {
+		t.Fatalf("Error loading costs: %v", err)
+	}
+	if !costs.IsEmpty() {
+		t.Errorf("Expected empty costs, got: %+v", costs)
+	}
+
+	costs.Extras = []Cost{{Label: "Lieferung", Amount: 350}, {Label: "Trinkgeld", Percent: 10}, {Label: "Rabatt", Amount: -500}}
+	costs.Split = "betrag"
+	costs.Amounts = map[int64]int{111: 1250, 222: 800}
+	if err := db.SaveGyroskopCosts(ctx, costs, 67890); err != nil {
+		t.Fatalf("Error saving costs: %v", err)
+	}
+
+	loaded, err := db.GetGyroskopCosts(ctx, gyroskop.ID)
+	if err != nil {
+		t.Fatalf("Error loading costs: %v", err)
+	}
+	if len(loaded.Extras) != 3 || loaded.Extras[1] != (Cost{Label: "Trinkgeld", Percent: 10}) || loaded.Extras[2].Amount != -500 {
+		t.Errorf("Expected the extra costs, got: %+v", loaded.Extras)
+	}
+	if loaded.Split != "betrag" || loaded.Amounts[111] != 1250 || loaded.Amounts[222] != 800 {
+		t.Errorf("Expected split and amounts, got: %+v", loaded)
+	}
+
+	// Saving empty costs removes them
+	if err := db.SaveGyroskopCosts(ctx, &Costs{GyroskopID: gyroskop.ID}, 67890); err != nil {
+		t.Fatalf("Error clearing costs: %v", err)
+	}
+	if cleared, _ := db.GetGyroskopCosts(ctx, gyroskop.ID); !cleared.IsEmpty() {
+		t.Errorf("Expected costs to be cleared, got: %+v", cleared)
+	}
+}
@@ -1,10 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"os"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -14,18 +14,25 @@ import (
 
 type DB struct {
 	*sql.DB
+	log *slog.Logger
 }
 
 type Gyroskop struct {
-	ID          int       `json:"id"`
-	ChatID      int64     `json:"chat_id"`
-	CreatedBy   int64     `json:"created_by"`
-	MessageID   int       `json:"message_id"`
-	Name        string    `json:"name"`
-	FoodOptions []string  `json:"food_options"`
-	Deadline    time.Time `json:"deadline"`
-	IsOpen      bool      `json:"is_open"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int        `json:"id"`
+	ChatID      int64      `json:"chat_id"`
+	CreatedBy   int64      `json:"created_by"`
+	MessageID   int        `json:"message_id"`
+	Name        string     `json:"name"`
+	FoodOptions []string   `json:"food_options"`
+	Deadline    time.Time  `json:"deadline"`
+	IsOpen      bool       `json:"is_open"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Hidden      bool       `json:"hidden"` // Orders are only shown to the creator
+	State       State      `json:"state"`
+	ETA         *time.Time `json:"eta,omitempty"` // Expected delivery once ordered
+	// Message with the final summary and the buttons to advance the state
+	SummaryMessageID int  `json:"summary_message_id"`
+	RestaurantID     *int `json:"restaurant_id,omitempty"` // Restaurant the gyroskop orders from
 }
 
 type Order struct {
@@ -39,46 +46,37 @@ type Order struct {
 	CreatedAt  time.Time      `json:"created_at"`
 }
 
-// Init initializes the PostgreSQL database
-func Init() (*DB, error) {
-	// Get database connection info from environment variables
-	host := getEnvOrDefault("POSTGRES_HOST", "localhost")
-	port := getEnvOrDefault("POSTGRES_PORT", "5432")
-	user := getEnvOrDefault("POSTGRES_USER", "gyroskop")
-	password := getEnvOrDefault("POSTGRES_PASSWORD", "password")
-	dbname := getEnvOrDefault("POSTGRES_DB", "gyroskop")
-	sslmode := getEnvOrDefault("POSTGRES_SSLMODE", "disable")
-
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
+// OrderMessage is the message an order was written in. Editing the message
+// changes the order.
+type OrderMessage struct {
+	ChatID    int64
+	MessageID int
+}
 
-	db, err := sql.Open("postgres", dsn)
+// Init connects to the PostgreSQL database and creates the tables.
+// databaseURL is a postgres:// URL or a key=value connection string.
+func Init(ctx context.Context, databaseURL string, logger *slog.Logger) (*DB, error) {
+	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		return nil, err
 	}
 
-	dbWrapper := &DB{db}
-	if err := dbWrapper.createTables(); err != nil {
+	logger.InfoContext(ctx, "Connected to database")
+
+	dbWrapper := &DB{DB: db, log: logger}
+	if err := dbWrapper.createTables(ctx); err != nil {
 		return nil, err
 	}
 
 	return dbWrapper, nil
 }
 
-// getEnvOrDefault returns environment variable value or default if not set
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
 // createTables creates the necessary tables
-func (db *DB) createTables() error {
+func (db *DB) createTables(ctx context.Context) error {
 	gyroskopTable := `
 	CREATE TABLE IF NOT EXISTS gyroskops (
 		id SERIAL PRIMARY KEY,
@@ -92,6 +90,34 @@ func (db *DB) createTables() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
 
+	// Columns added after the first release
+	gyroskopColumnsAdded := `
+	ALTER TABLE gyroskops ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE gyroskops ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT 'open';
+	ALTER TABLE gyroskops ADD COLUMN IF NOT EXISTS eta TIMESTAMP;
+	ALTER TABLE gyroskops ADD COLUMN IF NOT EXISTS summary_message_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE gyroskops ADD COLUMN IF NOT EXISTS restaurant_id INTEGER REFERENCES restaurants (id) ON DELETE SET NULL;
+	UPDATE gyroskops SET state = 'closed' WHERE NOT is_open AND state = 'open';
+	UPDATE gyroskops SET state = 'closed', is_open = false WHERE state = 'open'
+		AND id NOT IN (SELECT max(id) FROM gyroskops WHERE state = 'open' GROUP BY chat_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS gyroskops_one_open ON gyroskops (chat_id) WHERE state = 'open';`
+
+	// Restaurants registered per chat, names are unique per chat ignoring case
+	restaurantsTable := `
+	CREATE TABLE IF NOT EXISTS restaurants (
+		id SERIAL PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		phone TEXT NOT NULL DEFAULT '',
+		address TEXT NOT NULL DEFAULT '',
+		url TEXT NOT NULL DEFAULT '',
+		opening_hours TEXT NOT NULL DEFAULT '',
+		menu JSONB NOT NULL DEFAULT '[]'::jsonb,
+		created_by BIGINT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS restaurants_chat_name ON restaurants (chat_id, lower(name));`
+
 	ordersTable := `
 	CREATE TABLE IF NOT EXISTS orders (
 		id SERIAL PRIMARY KEY,
@@ -106,19 +132,207 @@ func (db *DB) createTables() error {
 		UNIQUE(gyroskop_id, user_id)
 	);`
 
-	if _, err := db.Exec(gyroskopTable); err != nil {
+	// The message an order was written in, to apply edits of it
+	ordersColumnsAdded := `
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS source_chat_id BIGINT;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS source_message_id INTEGER;
+	CREATE INDEX IF NOT EXISTS orders_source ON orders (source_chat_id, source_message_id);`
+
+	apiTokensTable := `
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		token_hash TEXT PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		created_by BIGINT NOT NULL,
+		created_by_name TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	organizersTable := `
+	CREATE TABLE IF NOT EXISTS gyroskop_organizers (
+		gyroskop_id INTEGER NOT NULL REFERENCES gyroskops (id) ON DELETE CASCADE,
+		user_id BIGINT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		added_by BIGINT NOT NULL,
+		added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (gyroskop_id, user_id)
+	);`
+
+	// NULL columns use the bot defaults
+	chatSettingsTable := `
+	CREATE TABLE IF NOT EXISTS chat_settings (
+		chat_id BIGINT PRIMARY KEY,
+		default_duration_minutes INTEGER,
+		default_name TEXT,
+		default_food_options JSONB,
+		max_quantity INTEGER,
+		quantity_buttons INTEGER,
+		confirmations BOOLEAN,
+		open_permission TEXT,
+		updated_by BIGINT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS pin BOOLEAN;
+	ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS split TEXT;`
+
+	// Message templates overriding the defaults of the bot, per chat and message
+	chatTemplatesTable := `
+	CREATE TABLE IF NOT EXISTS chat_templates (
+		chat_id BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		body TEXT NOT NULL,
+		updated_by BIGINT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (chat_id, name)
+	);`
+
+	// Extra costs and discounts of a gyroskop and the amounts per person in cents
+	gyroskopCostsTable := `
+	CREATE TABLE IF NOT EXISTS gyroskop_costs (
+		gyroskop_id INTEGER PRIMARY KEY REFERENCES gyroskops (id) ON DELETE CASCADE,
+		extras JSONB NOT NULL DEFAULT '[]'::jsonb,
+		split TEXT NOT NULL DEFAULT '',
+		amounts JSONB NOT NULL DEFAULT '{}'::jsonb,
+		updated_by BIGINT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Users who started a private chat with the bot
+	privateChatsTable := `
+	CREATE TABLE IF NOT EXISTS private_chats (
+		user_id BIGINT PRIMARY KEY,
+		summaries BOOLEAN NOT NULL DEFAULT true,
+		started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Where users who collect the money of a gyroskop want to be paid
+	paymentDetailsTable := `
+	CREATE TABLE IF NOT EXISTS payment_details (
+		user_id BIGINT PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		iban TEXT NOT NULL DEFAULT '',
+		paypal TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Votes on where to order, one ballot per user and vote
+	votesTable := `
+	CREATE TABLE IF NOT EXISTS votes (
+		id SERIAL PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		created_by BIGINT NOT NULL,
+		created_by_name TEXT NOT NULL DEFAULT '',
+		message_id INTEGER NOT NULL DEFAULT 0,
+		candidates JSONB NOT NULL,
+		deadline TIMESTAMP NOT NULL,
+		is_open BOOLEAN NOT NULL DEFAULT true,
+		winner TEXT NOT NULL DEFAULT '',
+		gyroskop_id INTEGER REFERENCES gyroskops (id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS vote_ballots (
+		vote_id INTEGER NOT NULL REFERENCES votes (id) ON DELETE CASCADE,
+		user_id BIGINT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		candidate INTEGER NOT NULL,
+		voted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (vote_id, user_id)
+	);`
+
+	if _, err := db.ExecContext(ctx, gyroskopTable); err != nil {
+		return err
+	}
+
+	// Gyroskops reference restaurants
+	if _, err := db.ExecContext(ctx, restaurantsTable); err != nil {
 		return err
 	}
 
-	if _, err := db.Exec(ordersTable); err != nil {
+	if _, err := db.ExecContext(ctx, gyroskopColumnsAdded); err != nil {
 		return err
 	}
 
+	if _, err := db.ExecContext(ctx, ordersTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, ordersColumnsAdded); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, apiTokensTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, organizersTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, chatSettingsTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, chatTemplatesTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, gyroskopCostsTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, privateChatsTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, paymentDetailsTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, votesTable); err != nil {
+		return err
+	}
+
+	db.log.DebugContext(ctx, "Database tables created")
 	return nil
 }
 
+// gyroskopColumns are the columns of gyroskops read by scanGyroskop
+const gyroskopColumns = "id, chat_id, created_by, message_id, name, food_options, deadline, is_open, created_at, hidden, state, eta, summary_message_id, restaurant_id"
+
+// qualifiedColumns prefixes a list of columns with a table alias
+func qualifiedColumns(alias, columns string) string {
+	return alias + "." + strings.ReplaceAll(columns, ", ", ", "+alias+".")
+}
+
+// scanGyroskop scans a gyroskop selected with gyroskopColumns, followed by
+// the extra columns of the query
+func scanGyroskop(row interface{ Scan(dest ...any) error }, extra ...any) (*Gyroskop, error) {
+	var g Gyroskop
+	var foodOptionsJSON []byte
+	var eta sql.NullTime
+	var restaurantID sql.NullInt64
+	dest := []any{&g.ID, &g.ChatID, &g.CreatedBy, &g.MessageID, &g.Name, &foodOptionsJSON, &g.Deadline, &g.IsOpen, &g.CreatedAt, &g.Hidden,
+		&g.State, &eta, &g.SummaryMessageID, &restaurantID}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if eta.Valid {
+		g.ETA = &eta.Time
+	}
+	if restaurantID.Valid {
+		id := int(restaurantID.Int64)
+		g.RestaurantID = &id
+	}
+
+	if err := json.Unmarshal(foodOptionsJSON, &g.FoodOptions); err != nil {
+		return nil, err
+	}
+
+	return &g, nil
+}
+
 // CreateGyroskop creates a new gyroskop
-func (db *DB) CreateGyroskop(chatID, createdBy int64, name string, foodOptions []string, deadline time.Time) (*Gyroskop, error) {
+func (db *DB) CreateGyroskop(ctx context.Context, chatID, createdBy int64, name string, foodOptions []string, deadline time.Time) (*Gyroskop, error) {
 	// Default food options if none provided
 	if len(foodOptions) == 0 {
 		foodOptions = []string{"Fleisch", "Vegetarisch"}
@@ -135,15 +349,16 @@ func (db *DB) CreateGyroskop(chatID, createdBy int64, name string, foodOptions [
 	}
 
 	var id int
-	err = db.QueryRow(`
+	err = db.QueryRowContext(ctx, `
 		INSERT INTO gyroskops (chat_id, created_by, name, food_options, deadline)
 		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id`,
 		chatID, createdBy, name, foodOptionsJSON, deadline,
 	).Scan(&id)
 	if err != nil {
-		return nil, err
+		return nil, openGyroskopError(err)
 	}
+	db.log.DebugContext(ctx, "Gyroskop created", "chat_id", chatID, "gyroskop_id", id)
 
 	return &Gyroskop{
 		ID:          id,
@@ -153,13 +368,14 @@ func (db *DB) CreateGyroskop(chatID, createdBy int64, name string, foodOptions [
 		FoodOptions: foodOptions,
 		Deadline:    deadline,
 		IsOpen:      true,
+		State:       StateOpen,
 		CreatedAt:   time.Now(),
 	}, nil
 }
 
 // UpdateGyroskopMessageID updates the message ID for a gyroskop
-func (db *DB) UpdateGyroskopMessageID(gyroskopID, messageID int) error {
-	_, err := db.Exec(`
+func (db *DB) UpdateGyroskopMessageID(ctx context.Context, gyroskopID, messageID int) error {
+	_, err := db.ExecContext(ctx, `
 		UPDATE gyroskops SET message_id = $1 WHERE id = $2`,
 		messageID, gyroskopID,
 	)
@@ -167,31 +383,19 @@ func (db *DB) UpdateGyroskopMessageID(gyroskopID, messageID int) error {
 }
 
 // GetActiveGyroskop gets the active gyroskop for a chat
-func (db *DB) GetActiveGyroskop(chatID int64) (*Gyroskop, error) {
-	row := db.QueryRow(`
-		SELECT id, chat_id, created_by, message_id, name, food_options, deadline, is_open, created_at
+func (db *DB) GetActiveGyroskop(ctx context.Context, chatID int64) (*Gyroskop, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+gyroskopColumns+`
 		FROM gyroskops WHERE chat_id = $1 AND is_open = true`,
 		chatID,
 	)
-
-	var g Gyroskop
-	var foodOptionsJSON []byte
-	err := row.Scan(&g.ID, &g.ChatID, &g.CreatedBy, &g.MessageID, &g.Name, &foodOptionsJSON, &g.Deadline, &g.IsOpen, &g.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := json.Unmarshal(foodOptionsJSON, &g.FoodOptions); err != nil {
-		return nil, err
-	}
-
-	return &g, nil
+	return scanGyroskop(row)
 }
 
 // GetAllActiveGyroskops gets all active gyroskops
-func (db *DB) GetAllActiveGyroskops() ([]Gyroskop, error) {
-	rows, err := db.Query(`
-		SELECT id, chat_id, created_by, message_id, name, food_options, deadline, is_open, created_at
+func (db *DB) GetAllActiveGyroskops(ctx context.Context) ([]Gyroskop, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+gyroskopColumns+`
 		FROM gyroskops WHERE is_open = true`,
 	)
 	if err != nil {
@@ -201,57 +405,80 @@ func (db *DB) GetAllActiveGyroskops() ([]Gyroskop, error) {
 
 	var gyroskops []Gyroskop
 	for rows.Next() {
-		var g Gyroskop
-		var foodOptionsJSON []byte
-		err := rows.Scan(&g.ID, &g.ChatID, &g.CreatedBy, &g.MessageID, &g.Name, &foodOptionsJSON, &g.Deadline, &g.IsOpen, &g.CreatedAt)
+		g, err := scanGyroskop(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := json.Unmarshal(foodOptionsJSON, &g.FoodOptions); err != nil {
-			return nil, err
-		}
-
-		gyroskops = append(gyroskops, g)
+		gyroskops = append(gyroskops, *g)
 	}
 
 	return gyroskops, rows.Err()
 }
 
-// CloseGyroskop closes a gyroskop
-func (db *DB) CloseGyroskop(gyroskopID int) error {
-	_, err := db.Exec(`
-		UPDATE gyroskops SET is_open = false WHERE id = $1`,
+// CloseGyroskop closes an open gyroskop. It returns ErrInvalidTransition if
+// the gyroskop is not open anymore, e.g. because it was closed concurrently.
+func (db *DB) CloseGyroskop(ctx context.Context, gyroskopID int) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE gyroskops SET is_open = false, state = 'closed' WHERE id = $1 AND state = 'open'`,
 		gyroskopID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrInvalidTransition
+	}
+	db.log.DebugContext(ctx, "Gyroskop closed", "gyroskop_id", gyroskopID)
+	return nil
 }
 
 // AddOrUpdateOrder adds or updates an order
-func (db *DB) AddOrUpdateOrder(gyroskopID int, userID int64, username, firstName, lastName string, quantities map[string]int) error {
+func (db *DB) AddOrUpdateOrder(ctx context.Context, gyroskopID int, userID int64, username, firstName, lastName string, quantities map[string]int) error {
+	return db.upsertOrder(ctx, gyroskopID, userID, username, firstName, lastName, quantities, nil)
+}
+
+// AddOrUpdateOrderFromMessage adds or updates an order written in a message,
+// so that editing the message changes the order
+func (db *DB) AddOrUpdateOrderFromMessage(ctx context.Context, gyroskopID int, userID int64, username, firstName, lastName string, quantities map[string]int, source OrderMessage) error {
+	return db.upsertOrder(ctx, gyroskopID, userID, username, firstName, lastName, quantities, &source)
+}
+
+// upsertOrder adds or updates an order. Without a source message, edits of
+// the message the order was previously written in are ignored from now on.
+func (db *DB) upsertOrder(ctx context.Context, gyroskopID int, userID int64, username, firstName, lastName string, quantities map[string]int, source *OrderMessage) error {
 	quantitiesJSON, err := json.Marshal(quantities)
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec(`
-		INSERT INTO orders (gyroskop_id, user_id, username, first_name, last_name, quantities, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+	var sourceChatID, sourceMessageID sql.NullInt64
+	if source != nil {
+		sourceChatID = sql.NullInt64{Int64: source.ChatID, Valid: true}
+		sourceMessageID = sql.NullInt64{Int64: int64(source.MessageID), Valid: true}
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO orders (gyroskop_id, user_id, username, first_name, last_name, quantities, created_at, source_chat_id, source_message_id)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, $7, $8)
 		ON CONFLICT (gyroskop_id, user_id) 
 		DO UPDATE SET 
 			username = EXCLUDED.username,
 			first_name = EXCLUDED.first_name,
 			last_name = EXCLUDED.last_name,
 			quantities = EXCLUDED.quantities,
-			created_at = CURRENT_TIMESTAMP`,
-		gyroskopID, userID, username, firstName, lastName, quantitiesJSON,
+			created_at = CURRENT_TIMESTAMP,
+			source_chat_id = EXCLUDED.source_chat_id,
+			source_message_id = EXCLUDED.source_message_id`,
+		gyroskopID, userID, username, firstName, lastName, quantitiesJSON, sourceChatID, sourceMessageID,
 	)
 	return err
 }
 
 // GetOrdersByGyroskop gets all orders for a gyroskop
-func (db *DB) GetOrdersByGyroskop(gyroskopID int) ([]Order, error) {
-	rows, err := db.Query(`
+func (db *DB) GetOrdersByGyroskop(ctx context.Context, gyroskopID int) ([]Order, error) {
+	rows, err := db.QueryContext(ctx, `
 		SELECT id, gyroskop_id, user_id, username, first_name, last_name, quantities, created_at
 		FROM orders WHERE gyroskop_id = $1 ORDER BY created_at`,
 		gyroskopID,
@@ -297,17 +524,18 @@ func (db *DB) GetOrdersByGyroskop(gyroskopID int) ([]Order, error) {
 }
 
 // RemoveOrder removes an order (sets quantities to empty)
-func (db *DB) RemoveOrder(gyroskopID int, userID int64) error {
-	_, err := db.Exec(`
-		UPDATE orders SET quantities = '{}'::jsonb WHERE gyroskop_id = $1 AND user_id = $2`,
+func (db *DB) RemoveOrder(ctx context.Context, gyroskopID int, userID int64) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE orders SET quantities = '{}'::jsonb, source_chat_id = NULL, source_message_id = NULL
+		WHERE gyroskop_id = $1 AND user_id = $2`,
 		gyroskopID, userID,
 	)
 	return err
 }
 
 // GetOrder gets a specific order
-func (db *DB) GetOrder(gyroskopID int, userID int64) (*Order, error) {
-	row := db.QueryRow(`
+func (db *DB) GetOrder(ctx context.Context, gyroskopID int, userID int64) (*Order, error) {
+	row := db.QueryRowContext(ctx, `
 		SELECT id, gyroskop_id, user_id, username, first_name, last_name, quantities, created_at
 		FROM orders WHERE gyroskop_id = $1 AND user_id = $2`,
 		gyroskopID, userID,
@@ -327,40 +555,99 @@ func (db *DB) GetOrder(gyroskopID int, userID int64) (*Order, error) {
 	return &o, nil
 }
 
-// GetGyroskopByMessageID gets a gyroskop by its message ID
-func (db *DB) GetGyroskopByMessageID(chatID int64, messageID int) (*Gyroskop, error) {
-	row := db.QueryRow(`
-		SELECT id, chat_id, created_by, message_id, name, food_options, deadline, is_open, created_at
-		FROM gyroskops WHERE chat_id = $1 AND message_id = $2`,
-		chatID, messageID,
+// GetOrderByMessage returns the order of a user written in the given message, or
+// sql.ErrNoRows if the order was changed in another way since
+func (db *DB) GetOrderByMessage(ctx context.Context, source OrderMessage, userID int64) (*Order, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT id, gyroskop_id, user_id, username, first_name, last_name, quantities, created_at
+		FROM orders WHERE source_chat_id = $1 AND source_message_id = $2 AND user_id = $3
+		ORDER BY created_at DESC LIMIT 1`,
+		source.ChatID, source.MessageID, userID,
 	)
 
-	var g Gyroskop
-	var foodOptionsJSON []byte
-	err := row.Scan(&g.ID, &g.ChatID, &g.CreatedBy, &g.MessageID, &g.Name, &foodOptionsJSON, &g.Deadline, &g.IsOpen, &g.CreatedAt)
+	var o Order
+	var quantitiesJSON []byte
+	err := row.Scan(&o.ID, &o.GyroskopID, &o.UserID, &o.Username, &o.FirstName, &o.LastName, &quantitiesJSON, &o.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := json.Unmarshal(foodOptionsJSON, &g.FoodOptions); err != nil {
+	if err := json.Unmarshal(quantitiesJSON, &o.Quantities); err != nil {
 		return nil, err
 	}
 
-	return &g, nil
+	return &o, nil
 }
 
-// ReopenGyroskop reopens a closed gyroskop with a new deadline
-func (db *DB) ReopenGyroskop(gyroskopID int, deadline time.Time) error {
-	_, err := db.Exec(`
-		UPDATE gyroskops SET is_open = true, deadline = $1 WHERE id = $2`,
+// GetPreviousOrder returns the latest non-empty order of a user in an earlier
+// gyroskop of the chat with the same name (ignoring case) or the same
+// restaurant, or sql.ErrNoRows
+func (db *DB) GetPreviousOrder(ctx context.Context, gyroskop *Gyroskop, userID int64) (*Order, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT o.id, o.gyroskop_id, o.user_id, o.username, o.first_name, o.last_name, o.quantities, o.created_at
+		FROM orders o JOIN gyroskops g ON g.id = o.gyroskop_id
+		WHERE g.chat_id = $1 AND o.user_id = $2 AND g.id <> $3
+			AND (lower(g.name) = lower($4) OR g.restaurant_id = $5)
+			AND EXISTS (SELECT 1 FROM jsonb_each_text(o.quantities) q WHERE q.value::int > 0)
+		ORDER BY g.created_at DESC LIMIT 1`,
+		gyroskop.ChatID, userID, gyroskop.ID, gyroskop.Name, gyroskop.RestaurantID,
+	)
+
+	var o Order
+	var quantitiesJSON []byte
+	err := row.Scan(&o.ID, &o.GyroskopID, &o.UserID, &o.Username, &o.FirstName, &o.LastName, &quantitiesJSON, &o.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(quantitiesJSON, &o.Quantities); err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+// GetGyroskopByMessageID gets a gyroskop by the ID of its message or of its summary message
+func (db *DB) GetGyroskopByMessageID(ctx context.Context, chatID int64, messageID int) (*Gyroskop, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+gyroskopColumns+`
+		FROM gyroskops WHERE chat_id = $1 AND (message_id = $2 OR summary_message_id = $2)`,
+		chatID, messageID,
+	)
+	return scanGyroskop(row)
+}
+
+// ReopenGyroskop reopens a closed gyroskop with a new deadline. Gyroskops
+// that were already ordered cannot be reopened (ErrInvalidTransition).
+func (db *DB) ReopenGyroskop(ctx context.Context, gyroskopID int, deadline time.Time) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE gyroskops SET is_open = true, state = 'open', deadline = $1 WHERE id = $2 AND state = 'closed'`,
 		deadline, gyroskopID,
 	)
+	if err != nil {
+		return openGyroskopError(err)
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrInvalidTransition
+	}
+	db.log.DebugContext(ctx, "Gyroskop reopened", "gyroskop_id", gyroskopID, "deadline", deadline)
+	return nil
+}
+
+// SetGyroskopHidden sets whether the orders of a gyroskop are hidden from the group
+func (db *DB) SetGyroskopHidden(ctx context.Context, gyroskopID int, hidden bool) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE gyroskops SET hidden = $1 WHERE id = $2`,
+		hidden, gyroskopID,
+	)
 	return err
 }
 
 // UpdateGyroskopDeadline updates the deadline of an active gyroskop
-func (db *DB) UpdateGyroskopDeadline(gyroskopID int, deadline time.Time) error {
-	_, err := db.Exec(`
+func (db *DB) UpdateGyroskopDeadline(ctx context.Context, gyroskopID int, deadline time.Time) error {
+	_, err := db.ExecContext(ctx, `
 		UPDATE gyroskops SET deadline = $1 WHERE id = $2`,
 		deadline, gyroskopID,
 	)
@@ -368,13 +655,13 @@ func (db *DB) UpdateGyroskopDeadline(gyroskopID int, deadline time.Time) error {
 }
 
 // UpdateGyroskopOptions updates the name and food options of a gyroskop
-func (db *DB) UpdateGyroskopOptions(gyroskopID int, name string, foodOptions []string) error {
+func (db *DB) UpdateGyroskopOptions(ctx context.Context, gyroskopID int, name string, foodOptions []string) error {
 	foodOptionsJSON, err := json.Marshal(foodOptions)
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec(`
+	_, err = db.ExecContext(ctx, `
 		UPDATE gyroskops SET name = $1, food_options = $2 WHERE id = $3`,
 		name, foodOptionsJSON, gyroskopID,
 	)
@@ -395,3 +682,48 @@ func FuzzyMatchOption(input string, options []string) (string, bool) {
 		return "", false
 	}
 }
+
+// GetGyroskopByID gets a gyroskop by its ID
+func (db *DB) GetGyroskopByID(ctx context.Context, gyroskopID int) (*Gyroskop, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+gyroskopColumns+`
+		FROM gyroskops WHERE id = $1`,
+		gyroskopID,
+	)
+	return scanGyroskop(row)
+}
+
+// GetLatestGyroskop gets the most recently created gyroskop of a chat, open or closed
+func (db *DB) GetLatestGyroskop(ctx context.Context, chatID int64) (*Gyroskop, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+gyroskopColumns+`
+		FROM gyroskops WHERE chat_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1`,
+		chatID,
+	)
+	return scanGyroskop(row)
+}
+
+// GetGyroskopsByChat gets all gyroskops of a chat created in [from, to), oldest first
+func (db *DB) GetGyroskopsByChat(ctx context.Context, chatID int64, from, to time.Time) ([]Gyroskop, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+gyroskopColumns+`
+		FROM gyroskops WHERE chat_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at, id`,
+		chatID, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var gyroskops []Gyroskop
+	for rows.Next() {
+		g, err := scanGyroskop(rows)
+		if err != nil {
+			return nil, err
+		}
+		gyroskops = append(gyroskops, *g)
+	}
+
+	return gyroskops, rows.Err()
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// State is the state of a gyroskop in its lifecycle:
+// open → closed → ordered → delivered → archived
+type State string
+
+const (
+	StateOpen      State = "open"      // Orders are collected
+	StateClosed    State = "closed"    // The deadline passed or it was ended
+	StateOrdered   State = "ordered"   // The food was ordered, possibly with an ETA
+	StateDelivered State = "delivered" // The food arrived
+	StateArchived  State = "archived"  // Nothing more to do
+)
+
+// transitions are the states each state may change to. Ordered may change to
+// ordered again to update the ETA; a closed gyroskop nobody ordered from can
+// be archived directly.
+var transitions = map[State][]State{
+	StateOpen:      {StateClosed},
+	StateClosed:    {StateOpen, StateOrdered, StateArchived},
+	StateOrdered:   {StateOrdered, StateDelivered},
+	StateDelivered: {StateArchived},
+}
+
+// ErrInvalidTransition is returned for state changes the lifecycle does not allow
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// ErrGyroskopOpen is returned when opening a gyroskop in a chat that already has an open one
+var ErrGyroskopOpen = errors.New("chat already has an open gyroskop")
+
+// openGyroskopError maps a violation of the one open gyroskop per chat index to ErrGyroskopOpen
+func openGyroskopError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "gyroskops_one_open" {
+		return ErrGyroskopOpen
+	}
+	return err
+}
+
+// CanBecome checks whether the lifecycle allows changing from s to the given state
+func (s State) CanBecome(to State) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// statesBefore returns the states that may change to the given state
+func statesBefore(to State) []string {
+	var from []string
+	for state, allowed := range transitions {
+		for _, next := range allowed {
+			if next == to {
+				from = append(from, string(state))
+			}
+		}
+	}
+	return from
+}
+
+// TransitionGyroskop changes the state of a gyroskop if the lifecycle allows it
+// and returns the changed gyroskop. The ETA is only stored when changing to
+// StateOrdered, nil means unknown.
+func (db *DB) TransitionGyroskop(ctx context.Context, gyroskopID int, to State, eta *time.Time) (*Gyroskop, error) {
+	row := db.QueryRowContext(ctx, `
+		UPDATE gyroskops
+		SET state = $1, is_open = $2, eta = CASE WHEN $1 = 'ordered' THEN $3 ELSE eta END
+		WHERE id = $4 AND state = ANY($5)
+		RETURNING `+gyroskopColumns,
+		string(to), to == StateOpen, eta, gyroskopID, pq.Array(statesBefore(to)),
+	)
+	gyroskop, err := scanGyroskop(row)
+	if !errors.Is(err, sql.ErrNoRows) {
+		err = openGyroskopError(err)
+		if err == nil {
+			db.log.DebugContext(ctx, "Gyroskop state changed", "gyroskop_id", gyroskopID, "state", to)
+		}
+		return gyroskop, err
+	}
+
+	// Either the gyroskop does not exist or it is in a state that cannot change to the given one
+	current, err := db.GetGyroskopByID(ctx, gyroskopID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current.State, to)
+}
+
+// UpdateGyroskopSummaryMessageID stores the message ID of the final summary of a gyroskop
+func (db *DB) UpdateGyroskopSummaryMessageID(ctx context.Context, gyroskopID, messageID int) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE gyroskops SET summary_message_id = $1 WHERE id = $2`,
+		messageID, gyroskopID,
+	)
+	return err
+}
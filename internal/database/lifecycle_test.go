@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestStateCanBecome(t *testing.T) {
+	tests := []struct {
+		from State
+		to   State
+		want bool
+	}{
+		{StateOpen, StateClosed, true},
+		{StateOpen, StateOrdered, false},
+		{StateClosed, StateOpen, true},
+		{StateClosed, StateOrdered, true},
+		{StateClosed, StateArchived, true},
+		{StateClosed, StateDelivered, false},
+		{StateOrdered, StateOrdered, true},
+		{StateOrdered, StateDelivered, true},
+		{StateOrdered, StateOpen, false},
+		{StateDelivered, StateArchived, true},
+		{StateDelivered, StateOrdered, false},
+		{StateArchived, StateOpen, false},
+		{StateArchived, StateClosed, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanBecome(tt.to); got != tt.want {
+			t.Errorf("%s.CanBecome(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestStatesBefore(t *testing.T) {
+	got := statesBefore(StateArchived)
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != string(StateClosed) || got[1] != string(StateDelivered) {
+		t.Errorf("statesBefore(archived) = %v, want [closed delivered]", got)
+	}
+}
+
+func TestTransitionGyroskop(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	gyroskop, err := db.CreateGyroskop(ctx, 12345, 67890, "Gyros", []string{"Fleisch"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+
+	// An open gyroskop has to be closed first
+	if _, err := db.TransitionGyroskop(ctx, gyroskop.ID, StateOrdered, nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Ordering an open gyroskop: got %v, want ErrInvalidTransition", err)
+	}
+
+	if err := db.CloseGyroskop(ctx, gyroskop.ID); err != nil {
+		t.Fatalf("Error closing gyroskop: %v", err)
+	}
+
+	eta := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	ordered, err := db.TransitionGyroskop(ctx, gyroskop.ID, StateOrdered, &eta)
+	if err != nil {
+		t.Fatalf("Error ordering gyroskop: %v", err)
+	}
+	if ordered.State != StateOrdered || ordered.IsOpen || ordered.ETA == nil || !ordered.ETA.Equal(eta) {
+		t.Errorf("Unexpected ordered gyroskop: state %s, open %v, eta %v", ordered.State, ordered.IsOpen, ordered.ETA)
+	}
+
+	// Ordered gyroskops cannot be reopened
+	if err := db.ReopenGyroskop(ctx, gyroskop.ID, time.Now().Add(time.Hour)); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Reopening an ordered gyroskop: got %v, want ErrInvalidTransition", err)
+	}
+
+	delivered, err := db.TransitionGyroskop(ctx, gyroskop.ID, StateDelivered, nil)
+	if err != nil {
+		t.Fatalf("Error delivering gyroskop: %v", err)
+	}
+	if delivered.ETA == nil {
+		t.Error("The ETA should be kept when changing to delivered")
+	}
+
+	if _, err := db.TransitionGyroskop(ctx, gyroskop.ID, StateArchived, nil); err != nil {
+		t.Fatalf("Error archiving gyroskop: %v", err)
+	}
+	if _, err := db.TransitionGyroskop(ctx, gyroskop.ID, StateOrdered, nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Ordering an archived gyroskop: got %v, want ErrInvalidTransition", err)
+	}
+}
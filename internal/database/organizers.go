@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+)
+
+// Organizer is a co-organiser who may end and edit a gyroskop like its creator
+type Organizer struct {
+	UserID  int64
+	Name    string
+	AddedBy int64
+}
+
+// ChatUser is a user the bot has seen ordering in a chat
+type ChatUser struct {
+	UserID    int64
+	Username  string
+	FirstName string
+	LastName  string
+}
+
+// AddOrganizer adds a co-organiser to a gyroskop and returns false if they already were one
+func (db *DB) AddOrganizer(ctx context.Context, gyroskopID int, userID int64, name string, addedBy int64) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO gyroskop_organizers (gyroskop_id, user_id, name, added_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (gyroskop_id, user_id) DO NOTHING`,
+		gyroskopID, userID, name, addedBy,
+	)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// RemoveOrganizer removes a co-organiser from a gyroskop and returns whether they were one
+func (db *DB) RemoveOrganizer(ctx context.Context, gyroskopID int, userID int64) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM gyroskop_organizers WHERE gyroskop_id = $1 AND user_id = $2`,
+		gyroskopID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// GetOrganizers returns the co-organisers of a gyroskop in the order they were added
+func (db *DB) GetOrganizers(ctx context.Context, gyroskopID int) ([]Organizer, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT user_id, name, added_by FROM gyroskop_organizers
+		WHERE gyroskop_id = $1
+		ORDER BY added_at, user_id`,
+		gyroskopID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var organizers []Organizer
+	for rows.Next() {
+		var o Organizer
+		if err := rows.Scan(&o.UserID, &o.Name, &o.AddedBy); err != nil {
+			return nil, err
+		}
+		organizers = append(organizers, o)
+	}
+	return organizers, rows.Err()
+}
+
+// IsOrganizer checks whether a user is a co-organiser of a gyroskop
+func (db *DB) IsOrganizer(ctx context.Context, gyroskopID int, userID int64) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM gyroskop_organizers WHERE gyroskop_id = $1 AND user_id = $2)`,
+		gyroskopID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// TransferGyroskop makes another user the owner of a gyroskop. The new owner
+// is no co-organiser anymore, the previous owner becomes one.
+func (db *DB) TransferGyroskop(ctx context.Context, gyroskopID int, newOwner int64, previousOwnerName string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previousOwner int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT created_by FROM gyroskops WHERE id = $1 FOR UPDATE`,
+		gyroskopID,
+	).Scan(&previousOwner)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE gyroskops SET created_by = $1 WHERE id = $2`, newOwner, gyroskopID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM gyroskop_organizers WHERE gyroskop_id = $1 AND user_id = $2`,
+		gyroskopID, newOwner,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO gyroskop_organizers (gyroskop_id, user_id, name, added_by)
+		VALUES ($1, $2, $3, $2)
+		ON CONFLICT (gyroskop_id, user_id) DO NOTHING`,
+		gyroskopID, previousOwner, previousOwnerName,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FindChatUser looks up a user who ordered in a chat by username (without @),
+// returning sql.ErrNoRows if the bot has not seen them. Telegram offers no way
+// for bots to resolve usernames, so this is how @mentions are resolved.
+func (db *DB) FindChatUser(ctx context.Context, chatID int64, username string) (*ChatUser, error) {
+	var u ChatUser
+	err := db.QueryRowContext(ctx, `
+		SELECT o.user_id, o.username, o.first_name, o.last_name
+		FROM orders o
+		JOIN gyroskops g ON g.id = o.gyroskop_id
+		WHERE g.chat_id = $1 AND LOWER(o.username) = LOWER($2)
+		ORDER BY o.created_at DESC
+		LIMIT 1`,
+		chatID, username,
+	).Scan(&u.UserID, &u.Username, &u.FirstName, &u.LastName)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrganizers(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	gyroskop, err := db.CreateGyroskop(ctx, 12345, 1, "Gyros", []string{"Fleisch"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+
+	added, err := db.AddOrganizer(ctx, gyroskop.ID, 2, "Ben", 1)
+	if err != nil || !added {
+		t.Fatalf("AddOrganizer() = %v, %v, want true, nil", added, err)
+	}
+	if added, _ := db.AddOrganizer(ctx, gyroskop.ID, 2, "Ben", 1); added {
+		t.Error("Adding an organizer twice should return false")
+	}
+
+	if ok, _ := db.IsOrganizer(ctx, gyroskop.ID, 2); !ok {
+		t.Error("User 2 should be an organizer")
+	}
+	if ok, _ := db.IsOrganizer(ctx, gyroskop.ID, 3); ok {
+		t.Error("User 3 should not be an organizer")
+	}
+
+	// Transfer to the organizer: the previous owner becomes an organizer instead
+	if err := db.TransferGyroskop(ctx, gyroskop.ID, 2, "Anna"); err != nil {
+		t.Fatalf("Error transferring gyroskop: %v", err)
+	}
+	transferred, err := db.GetGyroskopByID(ctx, gyroskop.ID)
+	if err != nil {
+		t.Fatalf("Error loading gyroskop: %v", err)
+	}
+	if transferred.CreatedBy != 2 {
+		t.Errorf("Expected owner 2, got: %d", transferred.CreatedBy)
+	}
+
+	organizers, err := db.GetOrganizers(ctx, gyroskop.ID)
+	if err != nil {
+		t.Fatalf("Error loading organizers: %v", err)
+	}
+	if len(organizers) != 1 || organizers[0].UserID != 1 || organizers[0].Name != "Anna" {
+		t.Errorf("Expected only Anna as organizer, got: %+v", organizers)
+	}
+
+	if removed, _ := db.RemoveOrganizer(ctx, gyroskop.ID, 1); !removed {
+		t.Error("Removing an organizer should return true")
+	}
+	if removed, _ := db.RemoveOrganizer(ctx, gyroskop.ID, 1); removed {
+		t.Error("Removing a missing organizer should return false")
+	}
+}
+
+func TestFindChatUser(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	gyroskop, err := db.CreateGyroskop(ctx, 12345, 1, "Gyros", []string{"Fleisch"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+	db.AddOrUpdateOrder(ctx, gyroskop.ID, 2, "Ben_K", "Ben", "K", map[string]int{"Fleisch": 1})
+
+	user, err := db.FindChatUser(ctx, 12345, "ben_k")
+	if err != nil {
+		t.Fatalf("Error finding user: %v", err)
+	}
+	if user.UserID != 2 || user.FirstName != "Ben" {
+		t.Errorf("Expected Ben (2), got: %+v", user)
+	}
+
+	if _, err := db.FindChatUser(ctx, 99999, "ben_k"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for another chat, got: %v", err)
+	}
+}
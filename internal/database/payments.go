@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// PaymentDetails are where a user who collects the money of a gyroskop wants
+// to be paid. Empty fields are not set.
+type PaymentDetails struct {
+	UserID int64
+	Name   string // Beneficiary of transfers
+	IBAN   string // Normalised, without spaces
+	PayPal string // PayPal.me name
+}
+
+// IsEmpty reports whether the user can be paid neither by transfer nor by PayPal
+func (p *PaymentDetails) IsEmpty() bool {
+	return p.IBAN == "" && p.PayPal == ""
+}
+
+// GetPaymentDetails returns the payment details of a user. A user without
+// details gets empty details, not an error.
+func (db *DB) GetPaymentDetails(ctx context.Context, userID int64) (*PaymentDetails, error) {
+	p := &PaymentDetails{UserID: userID}
+	err := db.QueryRowContext(ctx, `
+		SELECT name, iban, paypal FROM payment_details WHERE user_id = $1`,
+		userID,
+	).Scan(&p.Name, &p.IBAN, &p.PayPal)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SavePaymentDetails stores the payment details of a user, replacing the previous ones
+func (db *DB) SavePaymentDetails(ctx context.Context, p *PaymentDetails) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO payment_details (user_id, name, iban, paypal, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			iban = EXCLUDED.iban,
+			paypal = EXCLUDED.paypal,
+			updated_at = EXCLUDED.updated_at`,
+		p.UserID, p.Name, p.IBAN, p.PayPal,
+	)
+	return err
+}
+
+// DeletePaymentDetails removes the payment details of a user
+func (db *DB) DeletePaymentDetails(ctx context.Context, userID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM payment_details WHERE user_id = $1`, userID)
+	return err
+}
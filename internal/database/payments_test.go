@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPaymentDetails(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	userID := int64(67890)
+
+	// A user without details gets empty details
+	details, err := db.GetPaymentDetails(ctx, userID)
+	if err != nil {
+		t.Fatalf("Error loading payment details: %v", err)
+	}
+	if !details.IsEmpty() {
+		t.Errorf("Expected empty details, got: %+v", details)
+	}
+
+	details.Name = "Anna Schmidt"
+	details.IBAN = "DE89370400440532013000"
+	if err := db.SavePaymentDetails(ctx, details); err != nil {
+		t.Fatalf("Error saving payment details: %v", err)
+	}
+
+	// Saving again replaces the details
+	details.PayPal = "annaschmidt"
+	if err := db.SavePaymentDetails(ctx, details); err != nil {
+		t.Fatalf("Error saving payment details: %v", err)
+	}
+	loaded, err := db.GetPaymentDetails(ctx, userID)
+	if err != nil {
+		t.Fatalf("Error loading payment details: %v", err)
+	}
+	if *loaded != *details {
+		t.Errorf("Expected %+v, got: %+v", details, loaded)
+	}
+
+	if err := db.DeletePaymentDetails(ctx, userID); err != nil {
+		t.Fatalf("Error deleting payment details: %v", err)
+	}
+	if deleted, _ := db.GetPaymentDetails(ctx, userID); !deleted.IsEmpty() {
+		t.Errorf("Expected details to be deleted, got: %+v", deleted)
+	}
+}
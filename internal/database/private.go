@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// UserOrder is an order of a user together with the gyroskop it belongs to
+type UserOrder struct {
+	Gyroskop Gyroskop
+	Order    Order
+}
+
+// AddPrivateChat remembers that a user started a private chat with the bot.
+// The bot can only send private messages to these users.
+func (db *DB) AddPrivateChat(ctx context.Context, userID int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO private_chats (user_id) VALUES ($1)
+		ON CONFLICT (user_id) DO NOTHING`,
+		userID,
+	)
+	return err
+}
+
+// RemovePrivateChat forgets a private chat, e.g. because the user blocked the bot
+func (db *DB) RemovePrivateChat(ctx context.Context, userID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM private_chats WHERE user_id = $1`, userID)
+	return err
+}
+
+// SetPrivateSummaries turns the private summaries of closed gyroskops on or off for a user
+func (db *DB) SetPrivateSummaries(ctx context.Context, userID int64, enabled bool) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO private_chats (user_id, summaries) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET summaries = EXCLUDED.summaries`,
+		userID, enabled,
+	)
+	return err
+}
+
+// GetPrivateSummaries returns whether a user gets private summaries. Users
+// without a private chat do not.
+func (db *DB) GetPrivateSummaries(ctx context.Context, userID int64) (bool, error) {
+	var enabled bool
+	err := db.QueryRowContext(ctx, `SELECT summaries FROM private_chats WHERE user_id = $1`, userID).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return enabled, err
+}
+
+// GetSummaryRecipients returns the users who ordered in a gyroskop and get private summaries
+func (db *DB) GetSummaryRecipients(ctx context.Context, gyroskopID int) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT o.user_id FROM orders o
+		JOIN private_chats p ON p.user_id = o.user_id
+		WHERE o.gyroskop_id = $1 AND o.quantities <> '{}'::jsonb AND p.summaries
+		ORDER BY o.created_at`,
+		gyroskopID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// GetOpenOrdersByUser returns the orders of a user in open gyroskops of all chats,
+// the earliest deadline first
+func (db *DB) GetOpenOrdersByUser(ctx context.Context, userID int64) ([]UserOrder, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+qualifiedColumns("g", gyroskopColumns)+`,
+			o.id, o.user_id, o.username, o.first_name, o.last_name, o.quantities, o.created_at
+		FROM orders o
+		JOIN gyroskops g ON g.id = o.gyroskop_id
+		WHERE o.user_id = $1 AND g.is_open AND o.quantities <> '{}'::jsonb
+		ORDER BY g.deadline, g.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []UserOrder
+	for rows.Next() {
+		var o Order
+		var quantitiesJSON []byte
+		g, err := scanGyroskop(rows, &o.ID, &o.UserID, &o.Username, &o.FirstName, &o.LastName, &quantitiesJSON, &o.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(quantitiesJSON, &o.Quantities); err != nil {
+			return nil, err
+		}
+		o.GyroskopID = g.ID
+
+		orders = append(orders, UserOrder{Gyroskop: *g, Order: o})
+	}
+
+	return orders, rows.Err()
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPrivateChats(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	gyroskop, err := db.CreateGyroskop(ctx, 12345, 1, "Gyros", []string{"Fleisch", "Vegetarisch"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+
+	// User 1 and 2 ordered, user 3 cancelled
+	for userID, quantities := range map[int64]map[string]int{1: {"Fleisch": 1}, 2: {"Vegetarisch": 2}, 3: {}} {
+		if err := db.AddOrUpdateOrder(ctx, gyroskop.ID, userID, "", "User", "", quantities); err != nil {
+			t.Fatalf("Error adding order: %v", err)
+		}
+		if err := db.AddPrivateChat(ctx, userID); err != nil {
+			t.Fatalf("Error adding private chat: %v", err)
+		}
+	}
+
+	if enabled, _ := db.GetPrivateSummaries(ctx, 1); !enabled {
+		t.Error("Summaries should be on by default")
+	}
+	if enabled, _ := db.GetPrivateSummaries(ctx, 4); enabled {
+		t.Error("Users without private chat should not get summaries")
+	}
+
+	if err := db.SetPrivateSummaries(ctx, 2, false); err != nil {
+		t.Fatalf("Error turning off summaries: %v", err)
+	}
+	// Adding the chat again keeps the setting
+	if err := db.AddPrivateChat(ctx, 2); err != nil {
+		t.Fatalf("Error adding private chat: %v", err)
+	}
+
+	recipients, err := db.GetSummaryRecipients(ctx, gyroskop.ID)
+	if err != nil {
+		t.Fatalf("Error loading recipients: %v", err)
+	}
+	if len(recipients) != 1 || recipients[0] != 1 {
+		t.Errorf("Expected only user 1 as recipient, got: %v", recipients)
+	}
+
+	orders, err := db.GetOpenOrdersByUser(ctx, 1)
+	if err != nil {
+		t.Fatalf("Error loading open orders: %v", err)
+	}
+	if len(orders) != 1 || orders[0].Gyroskop.ID != gyroskop.ID || orders[0].Order.Quantities["Fleisch"] != 1 {
+		t.Errorf("Unexpected open orders: %+v", orders)
+	}
+	if orders, _ := db.GetOpenOrdersByUser(ctx, 3); len(orders) != 0 {
+		t.Errorf("Cancelled orders should not be listed, got: %+v", orders)
+	}
+
+	if err := db.CloseGyroskop(ctx, gyroskop.ID); err != nil {
+		t.Fatalf("Error closing gyroskop: %v", err)
+	}
+	if orders, _ := db.GetOpenOrdersByUser(ctx, 1); len(orders) != 0 {
+		t.Errorf("Orders of closed gyroskops should not be listed, got: %+v", orders)
+	}
+
+	if err := db.RemovePrivateChat(ctx, 1); err != nil {
+		t.Fatalf("Error removing private chat: %v", err)
+	}
+	if recipients, _ := db.GetSummaryRecipients(ctx, gyroskop.ID); len(recipients) != 0 {
+		t.Errorf("Expected no recipients after removing the chat, got: %v", recipients)
+	}
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// MenuItem is a dish on the menu of a restaurant
+type MenuItem struct {
+	Name  string `json:"name"`
+	Price int    `json:"price,omitempty"` // In cents, 0 if unknown
+}
+
+// Restaurant is a restaurant registered in a chat. Gyroskops opened with
+// @Name use its menu as food options.
+type Restaurant struct {
+	ID           int        `json:"id"`
+	ChatID       int64      `json:"chat_id"`
+	Name         string     `json:"name"`
+	Phone        string     `json:"phone,omitempty"`
+	Address      string     `json:"address,omitempty"`
+	URL          string     `json:"url,omitempty"`
+	OpeningHours string     `json:"opening_hours,omitempty"` // e.g. "Mo-Fr 11:00-22:00, Sa 17:00-23:00"
+	Menu         []MenuItem `json:"menu"`
+	CreatedBy    int64      `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// MenuItem returns the menu item with the given name
+func (r *Restaurant) MenuItem(name string) (MenuItem, bool) {
+	for _, item := range r.Menu {
+		if item.Name == name {
+			return item, true
+		}
+	}
+	return MenuItem{}, false
+}
+
+const restaurantColumns = "id, chat_id, name, phone, address, url, opening_hours, menu, created_by, created_at"
+
+// scanRestaurant scans a row selected with restaurantColumns
+func scanRestaurant(row interface{ Scan(dest ...any) error }) (*Restaurant, error) {
+	var r Restaurant
+	var menuJSON []byte
+	err := row.Scan(&r.ID, &r.ChatID, &r.Name, &r.Phone, &r.Address, &r.URL, &r.OpeningHours, &menuJSON, &r.CreatedBy, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(menuJSON, &r.Menu); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// AddRestaurant registers a restaurant in its chat and returns false if the
+// chat already has one with the same name (ignoring case)
+func (db *DB) AddRestaurant(ctx context.Context, r *Restaurant) (bool, error) {
+	menuJSON, err := json.Marshal(menuOrEmpty(r.Menu))
+	if err != nil {
+		return false, err
+	}
+
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO restaurants (chat_id, name, phone, address, url, opening_hours, menu, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (chat_id, lower(name)) DO NOTHING
+		RETURNING id, created_at`,
+		r.ChatID, r.Name, r.Phone, r.Address, r.URL, r.OpeningHours, menuJSON, r.CreatedBy,
+	).Scan(&r.ID, &r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// UpdateRestaurant stores the contact data, opening hours and menu of a restaurant
+func (db *DB) UpdateRestaurant(ctx context.Context, r *Restaurant) error {
+	menuJSON, err := json.Marshal(menuOrEmpty(r.Menu))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE restaurants SET phone = $1, address = $2, url = $3, opening_hours = $4, menu = $5
+		WHERE id = $6`,
+		r.Phone, r.Address, r.URL, r.OpeningHours, menuJSON, r.ID,
+	)
+	return err
+}
+
+// DeleteRestaurant removes a restaurant. Its gyroskops keep their name and options.
+func (db *DB) DeleteRestaurant(ctx context.Context, restaurantID int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM restaurants WHERE id = $1`, restaurantID)
+	return err
+}
+
+// GetRestaurant returns a restaurant by ID
+func (db *DB) GetRestaurant(ctx context.Context, restaurantID int) (*Restaurant, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+restaurantColumns+` FROM restaurants WHERE id = $1`,
+		restaurantID,
+	)
+	return scanRestaurant(row)
+}
+
+// GetRestaurantByName returns the restaurant of a chat with the given name
+// (ignoring case), or sql.ErrNoRows
+func (db *DB) GetRestaurantByName(ctx context.Context, chatID int64, name string) (*Restaurant, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+restaurantColumns+` FROM restaurants WHERE chat_id = $1 AND lower(name) = lower($2)`,
+		chatID, name,
+	)
+	return scanRestaurant(row)
+}
+
+// GetRestaurantsByChat returns the restaurants of a chat sorted by name
+func (db *DB) GetRestaurantsByChat(ctx context.Context, chatID int64) ([]Restaurant, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+restaurantColumns+` FROM restaurants WHERE chat_id = $1 ORDER BY lower(name)`,
+		chatID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var restaurants []Restaurant
+	for rows.Next() {
+		r, err := scanRestaurant(rows)
+		if err != nil {
+			return nil, err
+		}
+		restaurants = append(restaurants, *r)
+	}
+	return restaurants, rows.Err()
+}
+
+// SetGyroskopRestaurant links a gyroskop to the restaurant it orders from, nil for none
+func (db *DB) SetGyroskopRestaurant(ctx context.Context, gyroskopID int, restaurantID *int) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE gyroskops SET restaurant_id = $1 WHERE id = $2`,
+		restaurantID, gyroskopID,
+	)
+	return err
+}
+
+// menuOrEmpty stores a missing menu as an empty list instead of null
+func menuOrEmpty(menu []MenuItem) []MenuItem {
+	if menu == nil {
+		return []MenuItem{}
+	}
+	return menu
+}
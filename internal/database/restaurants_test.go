@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRestaurants(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	r := &Restaurant{ChatID: 12345, Name: "Luigi", CreatedBy: 67890}
+	added, err := db.AddRestaurant(ctx, r)
+	if err != nil || !added || r.ID == 0 {
+		t.Fatalf("AddRestaurant() = %v, %v (id %d)", added, err, r.ID)
+	}
+
+	// Names are unique per chat ignoring case
+	if added, err := db.AddRestaurant(ctx, &Restaurant{ChatID: 12345, Name: "luigi", CreatedBy: 1}); err != nil || added {
+		t.Errorf("AddRestaurant() of a duplicate = %v, %v, want false", added, err)
+	}
+	if added, err := db.AddRestaurant(ctx, &Restaurant{ChatID: 54321, Name: "Luigi", CreatedBy: 1}); err != nil || !added {
+		t.Errorf("AddRestaurant() in another chat = %v, %v, want true", added, err)
+	}
+
+	r.Phone = "0711 123456"
+	r.OpeningHours = "Mo-Fr 11:00-22:00"
+	r.Menu = []MenuItem{{Name: "Margherita", Price: 850}, {Name: "Salami"}}
+	if err := db.UpdateRestaurant(ctx, r); err != nil {
+		t.Fatalf("UpdateRestaurant() error = %v", err)
+	}
+
+	loaded, err := db.GetRestaurantByName(ctx, 12345, "LUIGI")
+	if err != nil {
+		t.Fatalf("GetRestaurantByName() error = %v", err)
+	}
+	if loaded.Phone != r.Phone || loaded.OpeningHours != r.OpeningHours || !reflect.DeepEqual(loaded.Menu, r.Menu) {
+		t.Errorf("GetRestaurantByName() = %+v, want %+v", loaded, r)
+	}
+	if _, err := db.GetRestaurantByName(ctx, 12345, "Mario"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetRestaurantByName() of an unknown restaurant: got %v, want sql.ErrNoRows", err)
+	}
+
+	restaurants, err := db.GetRestaurantsByChat(ctx, 12345)
+	if err != nil || len(restaurants) != 1 {
+		t.Errorf("GetRestaurantsByChat() = %d restaurants, %v, want 1", len(restaurants), err)
+	}
+
+	// Gyroskops keep working when their restaurant is deleted
+	gyroskop, err := db.CreateGyroskop(ctx, 12345, 67890, "Luigi", []string{"Margherita", "Salami"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+	if err := db.SetGyroskopRestaurant(ctx, gyroskop.ID, &r.ID); err != nil {
+		t.Fatalf("SetGyroskopRestaurant() error = %v", err)
+	}
+	g, err := db.GetGyroskopByID(ctx, gyroskop.ID)
+	if err != nil {
+		t.Fatalf("Error getting gyroskop: %v", err)
+	}
+	if g.RestaurantID == nil || *g.RestaurantID != r.ID {
+		t.Errorf("Gyroskop restaurant = %v, want %d", g.RestaurantID, r.ID)
+	}
+
+	if err := db.DeleteRestaurant(ctx, r.ID); err != nil {
+		t.Fatalf("DeleteRestaurant() error = %v", err)
+	}
+	g, err = db.GetGyroskopByID(ctx, gyroskop.ID)
+	if err != nil {
+		t.Fatalf("Error getting gyroskop: %v", err)
+	}
+	if g.RestaurantID != nil {
+		t.Errorf("Gyroskop restaurant after deleting = %d, want nil", *g.RestaurantID)
+	}
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ChatSettings are the settings of a chat. Unset (nil) fields use the bot defaults.
+type ChatSettings struct {
+	ChatID          int64
+	DefaultDuration *time.Duration // Stored in whole minutes
+	DefaultName     *string
+	FoodOptions     []string
+	MaxQuantity     *int
+	QuantityButtons *int // Highest quantity button, the buttons are 1 to QuantityButtons
+	Confirmations   *bool
+	OpenPermission  *string // Who may open gyroskops: "alle" or "admins"
+	Pin             *bool   // Whether the gyroskop message is pinned while open
+	Split           *string // How extra costs are split: "gleich", "artikel" or "betrag"
+}
+
+// GetChatSettings returns the settings of a chat. A chat without settings
+// gets empty settings, not an error.
+func (db *DB) GetChatSettings(ctx context.Context, chatID int64) (*ChatSettings, error) {
+	var (
+		durationMinutes sql.NullInt64
+		name            sql.NullString
+		foodOptionsJSON []byte
+		maxQuantity     sql.NullInt64
+		quantityButtons sql.NullInt64
+		confirmations   sql.NullBool
+		openPermission  sql.NullString
+		pin             sql.NullBool
+		split           sql.NullString
+	)
+
+	err := db.QueryRowContext(ctx, `
+		SELECT default_duration_minutes, default_name, default_food_options, max_quantity,
+			quantity_buttons, confirmations, open_permission, pin, split
+		FROM chat_settings WHERE chat_id = $1`,
+		chatID,
+	).Scan(&durationMinutes, &name, &foodOptionsJSON, &maxQuantity, &quantityButtons, &confirmations, &openPermission, &pin, &split)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &ChatSettings{ChatID: chatID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ChatSettings{ChatID: chatID}
+	if durationMinutes.Valid {
+		duration := time.Duration(durationMinutes.Int64) * time.Minute
+		s.DefaultDuration = &duration
+	}
+	if name.Valid {
+		s.DefaultName = &name.String
+	}
+	if foodOptionsJSON != nil {
+		if err := json.Unmarshal(foodOptionsJSON, &s.FoodOptions); err != nil {
+			return nil, err
+		}
+	}
+	if maxQuantity.Valid {
+		value := int(maxQuantity.Int64)
+		s.MaxQuantity = &value
+	}
+	if quantityButtons.Valid {
+		value := int(quantityButtons.Int64)
+		s.QuantityButtons = &value
+	}
+	if confirmations.Valid {
+		s.Confirmations = &confirmations.Bool
+	}
+	if openPermission.Valid {
+		s.OpenPermission = &openPermission.String
+	}
+	if pin.Valid {
+		s.Pin = &pin.Bool
+	}
+	if split.Valid {
+		s.Split = &split.String
+	}
+	return s, nil
+}
+
+// SaveChatSettings stores the settings of a chat, replacing the previous ones
+func (db *DB) SaveChatSettings(ctx context.Context, s *ChatSettings, updatedBy int64) error {
+	var durationMinutes sql.NullInt64
+	if s.DefaultDuration != nil {
+		durationMinutes = sql.NullInt64{Int64: int64(*s.DefaultDuration / time.Minute), Valid: true}
+	}
+
+	var foodOptionsJSON sql.NullString
+	if s.FoodOptions != nil {
+		data, err := json.Marshal(s.FoodOptions)
+		if err != nil {
+			return err
+		}
+		foodOptionsJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO chat_settings (chat_id, default_duration_minutes, default_name, default_food_options,
+			max_quantity, quantity_buttons, confirmations, open_permission, pin, split, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP)
+		ON CONFLICT (chat_id) DO UPDATE SET
+			default_duration_minutes = EXCLUDED.default_duration_minutes,
+			default_name = EXCLUDED.default_name,
+			default_food_options = EXCLUDED.default_food_options,
+			max_quantity = EXCLUDED.max_quantity,
+			quantity_buttons = EXCLUDED.quantity_buttons,
+			confirmations = EXCLUDED.confirmations,
+			open_permission = EXCLUDED.open_permission,
+			pin = EXCLUDED.pin,
+			split = EXCLUDED.split,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at`,
+		s.ChatID, durationMinutes, s.DefaultName, foodOptionsJSON,
+		s.MaxQuantity, s.QuantityButtons, s.Confirmations, s.OpenPermission, s.Pin, s.Split, updatedBy,
+	)
+	return err
+}
+
+// DeleteChatSettings resets a chat to the bot defaults
+func (db *DB) DeleteChatSettings(ctx context.Context, chatID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM chat_settings WHERE chat_id = $1`, chatID)
+	return err
+}
+
+// GetChatTemplates returns the message templates of a chat by name
+func (db *DB) GetChatTemplates(ctx context.Context, chatID int64) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, body FROM chat_templates WHERE chat_id = $1`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make(map[string]string)
+	for rows.Next() {
+		var name, body string
+		if err := rows.Scan(&name, &body); err != nil {
+			return nil, err
+		}
+		templates[name] = body
+	}
+	return templates, rows.Err()
+}
+
+// SaveChatTemplate stores a message template of a chat, replacing the previous one
+func (db *DB) SaveChatTemplate(ctx context.Context, chatID int64, name, body string, updatedBy int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO chat_templates (chat_id, name, body, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (chat_id, name) DO UPDATE SET
+			body = EXCLUDED.body,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at`,
+		chatID, name, body, updatedBy,
+	)
+	return err
+}
+
+// DeleteChatTemplate resets a message template of a chat to the default
+func (db *DB) DeleteChatTemplate(ctx context.Context, chatID int64, name string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM chat_templates WHERE chat_id = $1 AND name = $2`, chatID, name)
+	return err
+}
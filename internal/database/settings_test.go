@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChatSettings(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	chatID := int64(12345)
+
+	// A chat without settings gets empty settings
+	settings, err := db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		t.Fatalf("Error loading settings: %v", err)
+	}
+	if settings.DefaultDuration != nil || settings.DefaultName != nil || settings.FoodOptions != nil {
+		t.Errorf("Expected empty settings, got: %+v", settings)
+	}
+
+	duration := 30 * time.Minute
+	name := "Pizza"
+	confirmations := false
+	pin := true
+	split := "artikel"
+	settings.DefaultDuration = &duration
+	settings.DefaultName = &name
+	settings.FoodOptions = []string{"Margherita", "Salami"}
+	settings.Confirmations = &confirmations
+	settings.Pin = &pin
+	settings.Split = &split
+	if err := db.SaveChatSettings(ctx, settings, 1); err != nil {
+		t.Fatalf("Error saving settings: %v", err)
+	}
+
+	loaded, err := db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		t.Fatalf("Error loading settings: %v", err)
+	}
+	if loaded.DefaultDuration == nil || *loaded.DefaultDuration != duration {
+		t.Errorf("Expected duration %v, got: %v", duration, loaded.DefaultDuration)
+	}
+	if loaded.DefaultName == nil || *loaded.DefaultName != name {
+		t.Errorf("Expected name %s, got: %v", name, loaded.DefaultName)
+	}
+	if len(loaded.FoodOptions) != 2 {
+		t.Errorf("Expected 2 options, got: %v", loaded.FoodOptions)
+	}
+	if loaded.Confirmations == nil || *loaded.Confirmations {
+		t.Errorf("Expected confirmations off, got: %v", loaded.Confirmations)
+	}
+	if loaded.Pin == nil || !*loaded.Pin {
+		t.Errorf("Expected pinning on, got: %v", loaded.Pin)
+	}
+	if loaded.Split == nil || *loaded.Split != split {
+		t.Errorf("Expected split %s, got: %v", split, loaded.Split)
+	}
+	if loaded.MaxQuantity != nil || loaded.OpenPermission != nil {
+		t.Errorf("Unset settings should stay nil, got: %+v", loaded)
+	}
+
+	// Saving again replaces the settings
+	loaded.FoodOptions = nil
+	if err := db.SaveChatSettings(ctx, loaded, 1); err != nil {
+		t.Fatalf("Error saving settings: %v", err)
+	}
+	if reloaded, _ := db.GetChatSettings(ctx, chatID); reloaded.FoodOptions != nil {
+		t.Errorf("Expected options to be reset, got: %v", reloaded.FoodOptions)
+	}
+
+	if err := db.DeleteChatSettings(ctx, chatID); err != nil {
+		t.Fatalf("Error deleting settings: %v", err)
+	}
+	if reset, _ := db.GetChatSettings(ctx, chatID); reset.DefaultName != nil {
+		t.Errorf("Expected settings to be reset, got: %+v", reset)
+	}
+}
+
+func TestChatTemplates(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	chatID := int64(12346)
+
+	templates, err := db.GetChatTemplates(ctx, chatID)
+	if err != nil {
+		t.Fatalf("Error loading templates: %v", err)
+	}
+	if len(templates) != 0 {
+		t.Errorf("Expected no templates, got: %v", templates)
+	}
+
+	if err := db.SaveChatTemplate(ctx, chatID, "status", "{{.Total}}", 1); err != nil {
+		t.Fatalf("Error saving template: %v", err)
+	}
+	if err := db.SaveChatTemplate(ctx, chatID, "status", "Gesamt: {{.Total}}", 2); err != nil {
+		t.Fatalf("Error replacing template: %v", err)
+	}
+	templates, err = db.GetChatTemplates(ctx, chatID)
+	if err != nil {
+		t.Fatalf("Error loading templates: %v", err)
+	}
+	if len(templates) != 1 || templates["status"] != "Gesamt: {{.Total}}" {
+		t.Errorf("Expected the replaced template, got: %v", templates)
+	}
+
+	if err := db.DeleteChatTemplate(ctx, chatID, "status"); err != nil {
+		t.Fatalf("Error deleting template: %v", err)
+	}
+	if templates, _ := db.GetChatTemplates(ctx, chatID); len(templates) != 0 {
+		t.Errorf("Expected the template to be deleted, got: %v", templates)
+	}
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// ChatStatistics contains aggregated values over all gyroskops of a chat
+type ChatStatistics struct {
+	GyroskopCount   int           `json:"gyroskop_count"`
+	OrderCount      int           `json:"order_count"`
+	ItemCount       int           `json:"item_count"`
+	AvgParticipants float64       `json:"avg_participants"`
+	TopNames        []NameCount   `json:"top_names"`
+	TopOptions      []NameCount   `json:"top_options"`
+	TopOrderers     []UserCount   `json:"top_orderers"`
+	Weekdays        []BucketCount `json:"weekdays"`
+	Hours           []BucketCount `json:"hours"`
+}
+
+// NameCount is a name (gyroskop name or food option) with how often it occurred
+type NameCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// UserCount is a user with the number of gyroskops they ordered in and the items ordered
+type UserCount struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Orders    int    `json:"orders"`
+	Items     int    `json:"items"`
+}
+
+// BucketCount is a numeric bucket (ISO weekday 1-7 or hour 0-23) with a count
+type BucketCount struct {
+	Bucket int `json:"bucket"`
+	Count  int `json:"count"`
+}
+
+// UserStatistics contains aggregated values for a single user in a chat
+type UserStatistics struct {
+	OrderCount int                `json:"order_count"`
+	ItemCount  int                `json:"item_count"`
+	TopOptions []NameCount        `json:"top_options"`
+	History    []UserHistoryEntry `json:"history"`
+}
+
+// UserHistoryEntry is a single past order of a user together with its gyroskop
+type UserHistoryEntry struct {
+	GyroskopID   int            `json:"gyroskop_id"`
+	GyroskopName string         `json:"gyroskop_name"`
+	FoodOptions  []string       `json:"food_options"`
+	Deadline     time.Time      `json:"deadline"`
+	Quantities   map[string]int `json:"quantities"`
+}
+
+// statisticsOrderItems is a common table expression with one row per ordered
+// food option (quantity > 0) of all gyroskops in chat $1 created since $2
+const statisticsOrderItems = `
+	WITH order_items AS (
+		SELECT o.gyroskop_id, o.user_id, o.created_at, q.key AS option, q.value::int AS qty
+		FROM orders o
+		JOIN gyroskops g ON g.id = o.gyroskop_id
+		CROSS JOIN LATERAL jsonb_each_text(o.quantities) q
+		WHERE g.chat_id = $1 AND g.created_at >= $2 AND q.value::int > 0
+	)`
+
+// GetChatStatistics aggregates all gyroskops of a chat created since the given time.
+// Weekdays and hours are computed from the deadline in the given timezone.
+func (db *DB) GetChatStatistics(ctx context.Context, chatID int64, since time.Time, timezone string, limit int) (*ChatStatistics, error) {
+	var stats ChatStatistics
+
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM gyroskops WHERE chat_id = $1 AND created_at >= $2`,
+		chatID, since,
+	).Scan(&stats.GyroskopCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRowContext(ctx, statisticsOrderItems+`
+		SELECT COUNT(DISTINCT (gyroskop_id, user_id)), COALESCE(SUM(qty), 0)
+		FROM order_items`,
+		chatID, since,
+	).Scan(&stats.OrderCount, &stats.ItemCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.GyroskopCount > 0 {
+		stats.AvgParticipants = float64(stats.OrderCount) / float64(stats.GyroskopCount)
+	}
+
+	stats.TopNames, err = db.queryNameCounts(ctx, `
+		SELECT name, COUNT(*) FROM gyroskops
+		WHERE chat_id = $1 AND created_at >= $2
+		GROUP BY name ORDER BY COUNT(*) DESC, name LIMIT $3`,
+		chatID, since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TopOptions, err = db.queryNameCounts(ctx, statisticsOrderItems+`
+		SELECT option, SUM(qty) FROM order_items
+		GROUP BY option ORDER BY SUM(qty) DESC, option LIMIT $3`,
+		chatID, since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TopOrderers, err = db.getTopOrderers(ctx, chatID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Weekdays, err = db.queryBucketCounts(ctx, `
+		SELECT EXTRACT(ISODOW FROM (deadline AT TIME ZONE 'UTC') AT TIME ZONE $3)::int, COUNT(*)
+		FROM gyroskops WHERE chat_id = $1 AND created_at >= $2
+		GROUP BY 1 ORDER BY 1`,
+		chatID, since, timezone,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Hours, err = db.queryBucketCounts(ctx, `
+		SELECT EXTRACT(HOUR FROM (deadline AT TIME ZONE 'UTC') AT TIME ZONE $3)::int, COUNT(*)
+		FROM gyroskops WHERE chat_id = $1 AND created_at >= $2
+		GROUP BY 1 ORDER BY 1`,
+		chatID, since, timezone,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// getTopOrderers returns the users with the most orders in a chat,
+// using the most recently stored name of each user
+func (db *DB) getTopOrderers(ctx context.Context, chatID int64, since time.Time, limit int) ([]UserCount, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT o.user_id,
+			COALESCE((array_agg(o.username ORDER BY o.created_at DESC))[1], ''),
+			COALESCE((array_agg(o.first_name ORDER BY o.created_at DESC))[1], ''),
+			COALESCE((array_agg(o.last_name ORDER BY o.created_at DESC))[1], ''),
+			COUNT(*),
+			SUM(t.items)
+		FROM orders o
+		JOIN gyroskops g ON g.id = o.gyroskop_id
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(value::int), 0) AS items FROM jsonb_each_text(o.quantities)
+		) t
+		WHERE g.chat_id = $1 AND g.created_at >= $2 AND t.items > 0
+		GROUP BY o.user_id
+		ORDER BY COUNT(*) DESC, SUM(t.items) DESC, o.user_id
+		LIMIT $3`,
+		chatID, since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserCount
+	for rows.Next() {
+		var u UserCount
+		if err := rows.Scan(&u.UserID, &u.Username, &u.FirstName, &u.LastName, &u.Orders, &u.Items); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// GetUserStatistics aggregates the orders of a single user in a chat since the given time.
+// The history contains at most historyLimit entries, newest first.
+func (db *DB) GetUserStatistics(ctx context.Context, chatID, userID int64, since time.Time, limit, historyLimit int) (*UserStatistics, error) {
+	var stats UserStatistics
+
+	err := db.QueryRowContext(ctx, statisticsOrderItems+`
+		SELECT COUNT(DISTINCT gyroskop_id), COALESCE(SUM(qty), 0)
+		FROM order_items WHERE user_id = $3`,
+		chatID, since, userID,
+	).Scan(&stats.OrderCount, &stats.ItemCount)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TopOptions, err = db.queryNameCounts(ctx, statisticsOrderItems+`
+		SELECT option, SUM(qty) FROM order_items WHERE user_id = $3
+		GROUP BY option ORDER BY SUM(qty) DESC, option LIMIT $4`,
+		chatID, since, userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.id, g.name, g.food_options, g.deadline, o.quantities
+		FROM orders o
+		JOIN gyroskops g ON g.id = o.gyroskop_id
+		WHERE g.chat_id = $1 AND g.created_at >= $2 AND o.user_id = $3
+			AND EXISTS (SELECT 1 FROM jsonb_each_text(o.quantities) q WHERE q.value::int > 0)
+		ORDER BY g.deadline DESC
+		LIMIT $4`,
+		chatID, since, userID, historyLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry UserHistoryEntry
+		var foodOptionsJSON, quantitiesJSON []byte
+		if err := rows.Scan(&entry.GyroskopID, &entry.GyroskopName, &foodOptionsJSON, &entry.Deadline, &quantitiesJSON); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(foodOptionsJSON, &entry.FoodOptions); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(quantitiesJSON, &entry.Quantities); err != nil {
+			return nil, err
+		}
+
+		stats.History = append(stats.History, entry)
+	}
+
+	return &stats, rows.Err()
+}
+
+// queryNameCounts runs a query returning (name, count) rows
+func (db *DB) queryNameCounts(ctx context.Context, query string, args ...interface{}) ([]NameCount, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []NameCount
+	for rows.Next() {
+		var c NameCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// queryBucketCounts runs a query returning (bucket, count) rows
+func (db *DB) queryBucketCounts(ctx context.Context, query string, args ...interface{}) ([]BucketCount, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []BucketCount
+	for rows.Next() {
+		var c BucketCount
+		if err := rows.Scan(&c.Bucket, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetChatStatistics(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	chatID := int64(12345)
+	since := time.Now().Add(-time.Hour)
+
+	gyros, err := db.CreateGyroskop(context.Background(), chatID, 1, "Gyros", []string{"Fleisch", "Vegetarisch"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+	// Only one gyroskop per chat can be open
+	if err := db.CloseGyroskop(context.Background(), gyros.ID); err != nil {
+		t.Fatalf("Error closing gyroskop: %v", err)
+	}
+	pizza, err := db.CreateGyroskop(context.Background(), chatID, 1, "Pizza", []string{"Margherita"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating gyroskop: %v", err)
+	}
+
+	db.AddOrUpdateOrder(context.Background(), gyros.ID, 1, "anna", "Anna", "", map[string]int{"Fleisch": 2})
+	db.AddOrUpdateOrder(context.Background(), gyros.ID, 2, "ben", "Ben", "", map[string]int{"Fleisch": 1, "Vegetarisch": 1})
+	db.AddOrUpdateOrder(context.Background(), pizza.ID, 1, "anna", "Anna", "", map[string]int{"Margherita": 1})
+	db.AddOrUpdateOrder(context.Background(), pizza.ID, 3, "carl", "Carl", "", map[string]int{})
+
+	stats, err := db.GetChatStatistics(context.Background(), chatID, since, "Europe/Berlin", 5)
+	if err != nil {
+		t.Fatalf("Error getting chat statistics: %v", err)
+	}
+
+	if stats.GyroskopCount != 2 {
+		t.Errorf("Expected 2 gyroskops, got: %d", stats.GyroskopCount)
+	}
+	if stats.OrderCount != 3 {
+		t.Errorf("Expected 3 orders, got: %d", stats.OrderCount)
+	}
+	if stats.ItemCount != 5 {
+		t.Errorf("Expected 5 items, got: %d", stats.ItemCount)
+	}
+	if len(stats.TopOptions) == 0 || stats.TopOptions[0].Name != "Fleisch" || stats.TopOptions[0].Count != 3 {
+		t.Errorf("Expected Fleisch (3) as top option, got: %v", stats.TopOptions)
+	}
+	if len(stats.TopOrderers) == 0 || stats.TopOrderers[0].UserID != 1 || stats.TopOrderers[0].Orders != 2 {
+		t.Errorf("Expected user 1 with 2 orders as top orderer, got: %v", stats.TopOrderers)
+	}
+
+	userStats, err := db.GetUserStatistics(context.Background(), chatID, 1, since, 5, 10)
+	if err != nil {
+		t.Fatalf("Error getting user statistics: %v", err)
+	}
+
+	if userStats.OrderCount != 2 || userStats.ItemCount != 3 {
+		t.Errorf("Expected 2 orders with 3 items, got: %d orders with %d items", userStats.OrderCount, userStats.ItemCount)
+	}
+	if len(userStats.History) != 2 {
+		t.Errorf("Expected 2 history entries, got: %d", len(userStats.History))
+	}
+}
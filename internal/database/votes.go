@@ -0,0 +1,245 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Vote is a vote on where to order. When it closes the winner opens a gyroskop.
+type Vote struct {
+	ID            int       `json:"id"`
+	ChatID        int64     `json:"chat_id"`
+	CreatedBy     int64     `json:"created_by"`
+	CreatedByName string    `json:"created_by_name"`
+	MessageID     int       `json:"message_id"`
+	Candidates    []string  `json:"candidates"`
+	Deadline      time.Time `json:"deadline"`
+	IsOpen        bool      `json:"is_open"`
+	Winner        string    `json:"winner,omitempty"` // Empty if nobody voted
+	GyroskopID    *int      `json:"gyroskop_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Ballot is the vote of a user for one of the candidates
+type Ballot struct {
+	UserID    int64
+	Name      string
+	Candidate int // Index into Vote.Candidates
+	VotedAt   time.Time
+}
+
+// ErrVoteClosed is returned when voting in a vote that has already closed
+var ErrVoteClosed = errors.New("vote closed")
+
+const voteColumns = "id, chat_id, created_by, created_by_name, message_id, candidates, deadline, is_open, winner, gyroskop_id, created_at"
+
+// scanVote scans a row selected with voteColumns
+func scanVote(row interface{ Scan(dest ...any) error }) (*Vote, error) {
+	var v Vote
+	var candidatesJSON []byte
+	var gyroskopID sql.NullInt64
+	err := row.Scan(&v.ID, &v.ChatID, &v.CreatedBy, &v.CreatedByName, &v.MessageID, &candidatesJSON,
+		&v.Deadline, &v.IsOpen, &v.Winner, &gyroskopID, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if gyroskopID.Valid {
+		id := int(gyroskopID.Int64)
+		v.GyroskopID = &id
+	}
+	if err := json.Unmarshal(candidatesJSON, &v.Candidates); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// CreateVote starts a vote and sets its ID
+func (db *DB) CreateVote(ctx context.Context, v *Vote) error {
+	candidatesJSON, err := json.Marshal(v.Candidates)
+	if err != nil {
+		return err
+	}
+
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO votes (chat_id, created_by, created_by_name, candidates, deadline)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		v.ChatID, v.CreatedBy, v.CreatedByName, candidatesJSON, v.Deadline,
+	).Scan(&v.ID, &v.CreatedAt)
+	if err != nil {
+		return err
+	}
+	v.IsOpen = true
+
+	db.log.DebugContext(ctx, "Vote created", "vote_id", v.ID, "chat_id", v.ChatID)
+	return nil
+}
+
+// UpdateVoteMessageID stores the message ID of the vote message
+func (db *DB) UpdateVoteMessageID(ctx context.Context, voteID, messageID int) error {
+	_, err := db.ExecContext(ctx, `UPDATE votes SET message_id = $1 WHERE id = $2`, messageID, voteID)
+	return err
+}
+
+// GetVote returns a vote by ID
+func (db *DB) GetVote(ctx context.Context, voteID int) (*Vote, error) {
+	return scanVote(db.QueryRowContext(ctx, `SELECT `+voteColumns+` FROM votes WHERE id = $1`, voteID))
+}
+
+// GetOpenVote returns the open vote of a chat, or sql.ErrNoRows
+func (db *DB) GetOpenVote(ctx context.Context, chatID int64) (*Vote, error) {
+	return scanVote(db.QueryRowContext(ctx, `
+		SELECT `+voteColumns+` FROM votes WHERE chat_id = $1 AND is_open
+		ORDER BY created_at DESC LIMIT 1`,
+		chatID,
+	))
+}
+
+// GetOpenVotes returns the open votes of all chats
+func (db *DB) GetOpenVotes(ctx context.Context) ([]Vote, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+voteColumns+` FROM votes WHERE is_open`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var votes []Vote
+	for rows.Next() {
+		v, err := scanVote(rows)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, *v)
+	}
+	return votes, rows.Err()
+}
+
+// CastBallot votes for a candidate. Voting for the same candidate again takes
+// the vote back, which is reported by counted being false.
+func (db *DB) CastBallot(ctx context.Context, voteID int, userID int64, name string, candidate int) (counted bool, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var open bool
+	if err := tx.QueryRowContext(ctx, `SELECT is_open FROM votes WHERE id = $1 FOR UPDATE`, voteID).Scan(&open); err != nil {
+		return false, err
+	}
+	if !open {
+		return false, ErrVoteClosed
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM vote_ballots WHERE vote_id = $1 AND user_id = $2 AND candidate = $3`,
+		voteID, userID, candidate,
+	)
+	if err != nil {
+		return false, err
+	}
+	retracted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if retracted > 0 {
+		return false, tx.Commit()
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO vote_ballots (vote_id, user_id, name, candidate)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (vote_id, user_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			candidate = EXCLUDED.candidate,
+			voted_at = CURRENT_TIMESTAMP`,
+		voteID, userID, name, candidate,
+	)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetBallots returns the ballots of a vote, oldest first
+func (db *DB) GetBallots(ctx context.Context, voteID int) ([]Ballot, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT user_id, name, candidate, voted_at FROM vote_ballots
+		WHERE vote_id = $1
+		ORDER BY voted_at, user_id`,
+		voteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ballots []Ballot
+	for rows.Next() {
+		var ballot Ballot
+		if err := rows.Scan(&ballot.UserID, &ballot.Name, &ballot.Candidate, &ballot.VotedAt); err != nil {
+			return nil, err
+		}
+		ballots = append(ballots, ballot)
+	}
+	return ballots, rows.Err()
+}
+
+// CloseVote closes a vote with its winner and returns false if it was already closed
+func (db *DB) CloseVote(ctx context.Context, voteID int, winner string) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE votes SET is_open = false, winner = $1 WHERE id = $2 AND is_open`,
+		winner, voteID,
+	)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// SetVoteGyroskop stores the gyroskop opened for the winner of a vote
+func (db *DB) SetVoteGyroskop(ctx context.Context, voteID, gyroskopID int) error {
+	_, err := db.ExecContext(ctx, `UPDATE votes SET gyroskop_id = $1 WHERE id = $2`, gyroskopID, voteID)
+	return err
+}
+
+// GetRecentGyroskopNames returns the distinct names of the latest gyroskops of a chat, newest first
+func (db *DB) GetRecentGyroskopNames(ctx context.Context, chatID int64, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT name FROM gyroskops
+		WHERE chat_id = $1
+		GROUP BY name
+		ORDER BY MAX(created_at) DESC
+		LIMIT $2`,
+		chatID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// GetLatestGyroskopByName returns the latest gyroskop of a chat with the given
+// name (ignoring case), or sql.ErrNoRows
+func (db *DB) GetLatestGyroskopByName(ctx context.Context, chatID int64, name string) (*Gyroskop, error) {
+	return scanGyroskop(db.QueryRowContext(ctx, `
+		SELECT `+gyroskopColumns+` FROM gyroskops
+		WHERE chat_id = $1 AND lower(name) = lower($2)
+		ORDER BY created_at DESC LIMIT 1`,
+		chatID, name,
+	))
+}
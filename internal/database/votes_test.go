@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVotes(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	vote := &Vote{ChatID: 12345, CreatedBy: 67890, CreatedByName: "Anna", Candidates: []string{"Pizza", "Döner"}, Deadline: time.Now().Add(10 * time.Minute)}
+	if err := db.CreateVote(ctx, vote); err != nil {
+		t.Fatalf("CreateVote() error = %v", err)
+	}
+
+	open, err := db.GetOpenVote(ctx, 12345)
+	if err != nil || open.ID != vote.ID || !reflect.DeepEqual(open.Candidates, vote.Candidates) {
+		t.Fatalf("GetOpenVote() = %+v, %v", open, err)
+	}
+
+	// Voting again for the same candidate takes the vote back, another candidate changes it
+	for _, step := range []struct {
+		candidate   int
+		wantCounted bool
+	}{{0, true}, {0, false}, {0, true}, {1, true}} {
+		counted, err := db.CastBallot(ctx, vote.ID, 1, "Ben", step.candidate)
+		if err != nil || counted != step.wantCounted {
+			t.Errorf("CastBallot(%d) = %v, %v, want %v", step.candidate, counted, err, step.wantCounted)
+		}
+	}
+	ballots, err := db.GetBallots(ctx, vote.ID)
+	if err != nil || len(ballots) != 1 || ballots[0].Candidate != 1 {
+		t.Errorf("GetBallots() = %+v, %v, want one ballot for Döner", ballots, err)
+	}
+
+	if closed, err := db.CloseVote(ctx, vote.ID, "Döner"); err != nil || !closed {
+		t.Errorf("CloseVote() = %v, %v", closed, err)
+	}
+	if closed, err := db.CloseVote(ctx, vote.ID, "Pizza"); err != nil || closed {
+		t.Errorf("CloseVote() of a closed vote = %v, %v, want false", closed, err)
+	}
+	if _, err := db.CastBallot(ctx, vote.ID, 2, "Carla", 0); !errors.Is(err, ErrVoteClosed) {
+		t.Errorf("CastBallot() in a closed vote: got %v, want ErrVoteClosed", err)
+	}
+
+	loaded, err := db.GetVote(ctx, vote.ID)
+	if err != nil || loaded.IsOpen || loaded.Winner != "Döner" {
+		t.Errorf("GetVote() = %+v, %v", loaded, err)
+	}
+}
+
+func TestRecentGyroskopNames(t *testing.T) {
+	db := setupTestDB(t)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, name := range []string{"Pizza", "Döner", "Pizza", "Burger"} {
+		g, err := db.CreateGyroskop(ctx, 12345, 67890, name, []string{name + " 1"}, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Error creating gyroskop: %v", err)
+		}
+		// Only one gyroskop per chat can be open
+		if err := db.CloseGyroskop(ctx, g.ID); err != nil {
+			t.Fatalf("Error closing gyroskop: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	names, err := db.GetRecentGyroskopNames(ctx, 12345, 10)
+	if err != nil || !reflect.DeepEqual(names, []string{"Burger", "Pizza", "Döner"}) {
+		t.Errorf("GetRecentGyroskopNames() = %v, %v", names, err)
+	}
+
+	latest, err := db.GetLatestGyroskopByName(ctx, 12345, "pizza")
+	if err != nil || latest.Name != "Pizza" {
+		t.Errorf("GetLatestGyroskopByName() = %+v, %v", latest, err)
+	}
+}
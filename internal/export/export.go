@@ -0,0 +1,353 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV      Format = "csv"
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "md"
+	FormatSheet    Format = "txt" // Bestellzettel for the restaurant
+)
+
+// Entry is a gyroskop together with its orders
+type Entry struct {
+	Gyroskop database.Gyroskop
+	Orders   []database.Order
+	Contact  Contact // Restaurant shown on the order sheet, optional
+}
+
+// Contact is the restaurant an order sheet is meant for
+type Contact struct {
+	Name  string
+	Phone string
+}
+
+// ParseFormat parses an export format name, defaulting to CSV for empty input
+func ParseFormat(input string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "", "csv":
+		return FormatCSV, nil
+	case "json":
+		return FormatJSON, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "txt", "zettel", "bestellzettel":
+		return FormatSheet, nil
+	}
+	return "", fmt.Errorf("unknown export format: %s", input)
+}
+
+// Extension returns the file extension for the format
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Render renders the entries in the given format.
+// Timestamps in CSV and Markdown are shown in the given location.
+func Render(format Format, entries []Entry, loc *time.Location) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		return CSV(entries, loc)
+	case FormatJSON:
+		return JSON(entries)
+	case FormatMarkdown:
+		return Markdown(entries, loc), nil
+	case FormatSheet:
+		return Sheet(entries, loc), nil
+	}
+	return nil, fmt.Errorf("unknown export format: %s", format)
+}
+
+// Values of the row_type column of CSV exports
+const (
+	csvOrderRow = "order" // Quantity of one option ordered by one person
+	csvTotalRow = "total" // Quantity of one option ordered in a gyroskop
+)
+
+// CSV renders one row per ordered food option, suitable for spreadsheets,
+// followed by a total row per option of each gyroskop. The row_type column
+// tells them apart, so that sums over the quantity column can filter by it.
+func CSV(entries []Entry, loc *time.Location) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"gyroskop_id", "gyroskop_name", "deadline", "user_id", "username", "first_name", "last_name", "name", "option", "quantity", "row_type"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		id := strconv.Itoa(entry.Gyroskop.ID)
+		deadline := entry.Gyroskop.Deadline.In(loc).Format("2006-01-02 15:04")
+		for _, order := range entry.Orders {
+			for _, option := range orderedOptions(entry.Gyroskop.FoodOptions, order.Quantities) {
+				record := []string{
+					id,
+					entry.Gyroskop.Name,
+					deadline,
+					strconv.FormatInt(order.UserID, 10),
+					order.Username,
+					order.FirstName,
+					order.LastName,
+					displayName(&order),
+					option,
+					strconv.Itoa(order.Quantities[option]),
+					csvOrderRow,
+				}
+				if err := w.Write(csvSafe(record)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		totals, _ := Totals(entry.Orders)
+		for _, option := range orderedOptions(entry.Gyroskop.FoodOptions, totals) {
+			record := []string{id, entry.Gyroskop.Name, deadline, "", "", "", "", "Gesamt", option, strconv.Itoa(totals[option]), csvTotalRow}
+			if err := w.Write(csvSafe(record)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// csvSafe prefixes cells that spreadsheets would read as formulas with an
+// apostrophe. Names and options are chosen by users.
+func csvSafe(record []string) []string {
+	for i, cell := range record {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			record[i] = "'" + cell
+		}
+	}
+	return record
+}
+
+// jsonEntry is the JSON representation of an exported gyroskop
+type jsonEntry struct {
+	database.Gyroskop
+	Orders     []database.Order `json:"orders"`
+	Totals     map[string]int   `json:"totals"`
+	TotalItems int              `json:"total_items"`
+}
+
+// JSON renders the entries as a JSON document with per-gyroskop totals
+func JSON(entries []Entry) ([]byte, error) {
+	doc := struct {
+		Gyroskops  []jsonEntry `json:"gyroskops"`
+		TotalItems int         `json:"total_items"`
+	}{
+		Gyroskops: make([]jsonEntry, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		totals, totalItems := Totals(entry.Orders)
+		orders := entry.Orders
+		if orders == nil {
+			orders = []database.Order{}
+		}
+		doc.Gyroskops = append(doc.Gyroskops, jsonEntry{
+			Gyroskop:   entry.Gyroskop,
+			Orders:     orders,
+			Totals:     totals,
+			TotalItems: totalItems,
+		})
+		doc.TotalItems += totalItems
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Markdown renders one table per gyroskop with a row per person and a totals row
+func Markdown(entries []Entry, loc *time.Location) []byte {
+	var buf bytes.Buffer
+
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+
+		g := entry.Gyroskop
+		fmt.Fprintf(&buf, "# %s (#%d)\n\n", g.Name, g.ID)
+		fmt.Fprintf(&buf, "Deadline: %s\n\n", g.Deadline.In(loc).Format("02.01.2006 15:04"))
+
+		if len(entry.Orders) == 0 {
+			buf.WriteString("Keine Bestellungen.\n")
+			continue
+		}
+
+		options := allOptions(g.FoodOptions, entry.Orders)
+
+		buf.WriteString("| Name |")
+		for _, option := range options {
+			fmt.Fprintf(&buf, " %s |", escapeMarkdownCell(option))
+		}
+		buf.WriteString(" Summe |\n|---|")
+		for range options {
+			buf.WriteString("---:|")
+		}
+		buf.WriteString("---:|\n")
+
+		for _, order := range entry.Orders {
+			fmt.Fprintf(&buf, "| %s |", escapeMarkdownCell(displayName(&order)))
+			sum := 0
+			for _, option := range options {
+				qty := order.Quantities[option]
+				sum += qty
+				fmt.Fprintf(&buf, " %d |", qty)
+			}
+			fmt.Fprintf(&buf, " %d |\n", sum)
+		}
+
+		totals, totalItems := Totals(entry.Orders)
+		buf.WriteString("| **Gesamt** |")
+		for _, option := range options {
+			fmt.Fprintf(&buf, " **%d** |", totals[option])
+		}
+		fmt.Fprintf(&buf, " **%d** |\n", totalItems)
+	}
+
+	return buf.Bytes()
+}
+
+// Sheet renders a plain-text order sheet per gyroskop: the totals per option
+// for phoning the restaurant or copying into a delivery website, followed by
+// who gets what for handing out the food
+func Sheet(entries []Entry, loc *time.Location) []byte {
+	var buf bytes.Buffer
+
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteString("\n\n")
+		}
+
+		g := entry.Gyroskop
+		fmt.Fprintf(&buf, "BESTELLZETTEL %s (#%d)\n", g.Name, g.ID)
+		if entry.Contact.Name != "" {
+			fmt.Fprintf(&buf, "Restaurant: %s\n", entry.Contact.Name)
+		}
+		if entry.Contact.Phone != "" {
+			fmt.Fprintf(&buf, "Telefon: %s\n", entry.Contact.Phone)
+		}
+		fmt.Fprintf(&buf, "Bestellschluss: %s\n\n", g.Deadline.In(loc).Format("02.01.2006 15:04"))
+
+		totals, totalItems := Totals(entry.Orders)
+		if totalItems == 0 {
+			buf.WriteString("Keine Bestellungen.\n")
+			continue
+		}
+
+		options := allOptions(g.FoodOptions, entry.Orders)
+		for _, option := range options {
+			if totals[option] > 0 {
+				fmt.Fprintf(&buf, "%dx %s\n", totals[option], option)
+			}
+		}
+		fmt.Fprintf(&buf, "Gesamt: %d\n\nVerteilung:\n", totalItems)
+
+		for _, option := range options {
+			if totals[option] == 0 {
+				continue
+			}
+			fmt.Fprintf(&buf, "%s (%d)\n", option, totals[option])
+			for _, order := range entry.Orders {
+				if qty := order.Quantities[option]; qty > 0 {
+					fmt.Fprintf(&buf, "  - %s: %d\n", displayName(&order), qty)
+				}
+			}
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// Totals sums up the quantities of all orders per option
+func Totals(orders []database.Order) (map[string]int, int) {
+	totals := make(map[string]int)
+	totalItems := 0
+	for _, order := range orders {
+		for option, qty := range order.Quantities {
+			if qty > 0 {
+				totals[option] += qty
+				totalItems += qty
+			}
+		}
+	}
+	return totals, totalItems
+}
+
+// orderedOptions returns the options with a positive quantity, in the order of foodOptions
+// followed by options no longer offered by the gyroskop (sorted by name)
+func orderedOptions(foodOptions []string, quantities map[string]int) []string {
+	var options []string
+	for _, option := range allOptions(foodOptions, []database.Order{{Quantities: quantities}}) {
+		if quantities[option] > 0 {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+// allOptions returns foodOptions followed by all further options appearing in the orders
+func allOptions(foodOptions []string, orders []database.Order) []string {
+	seen := make(map[string]bool)
+	options := make([]string, 0, len(foodOptions))
+	for _, option := range foodOptions {
+		if !seen[option] {
+			seen[option] = true
+			options = append(options, option)
+		}
+	}
+
+	var extra []string
+	for _, order := range orders {
+		for option, qty := range order.Quantities {
+			if qty > 0 && !seen[option] {
+				seen[option] = true
+				extra = append(extra, option)
+			}
+		}
+	}
+	sort.Strings(extra)
+
+	return append(options, extra...)
+}
+
+// displayName formats the name from an order like the bot does
+func displayName(order *database.Order) string {
+	if order.FirstName != "" {
+		if order.LastName != "" {
+			return fmt.Sprintf("%s %s", order.FirstName, order.LastName)
+		}
+		return order.FirstName
+	}
+	if order.Username != "" {
+		return "@" + order.Username
+	}
+	return fmt.Sprintf("User %d", order.UserID)
+}
+
+// escapeMarkdownCell escapes characters that would break a Markdown table cell
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,159 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tionis/gyroskop/internal/database"
+)
+
+func testEntries() []Entry {
+	return []Entry{
+		{
+			Gyroskop: database.Gyroskop{
+				ID:          42,
+				Name:        "Gyros",
+				FoodOptions: []string{"Fleisch", "Vegetarisch"},
+				Deadline:    time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC),
+			},
+			Orders: []database.Order{
+				{UserID: 1, Username: "anna", FirstName: "Anna", Quantities: map[string]int{"Fleisch": 2}},
+				{UserID: 2, Username: "ben_b", Quantities: map[string]int{"Vegetarisch": 1, "Fleisch": 1, "Alt": 1}},
+			},
+		},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]Format{"": FormatCSV, "CSV": FormatCSV, "json": FormatJSON, "markdown": FormatMarkdown, "md": FormatMarkdown, "Zettel": FormatSheet}
+	for input, want := range tests {
+		got, err := ParseFormat(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Error("ParseFormat(xlsx) should fail")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	data, err := CSV(testEntries(), time.UTC)
+	if err != nil {
+		t.Fatalf("CSV() error = %v", err)
+	}
+
+	want := "gyroskop_id,gyroskop_name,deadline,user_id,username,first_name,last_name,name,option,quantity,row_type\n" +
+		"42,Gyros,2024-03-01 11:30,1,anna,Anna,,Anna,Fleisch,2,order\n" +
+		"42,Gyros,2024-03-01 11:30,2,ben_b,,,'@ben_b,Fleisch,1,order\n" +
+		"42,Gyros,2024-03-01 11:30,2,ben_b,,,'@ben_b,Vegetarisch,1,order\n" +
+		"42,Gyros,2024-03-01 11:30,2,ben_b,,,'@ben_b,Alt,1,order\n" +
+		"42,Gyros,2024-03-01 11:30,,,,,Gesamt,Fleisch,3,total\n" +
+		"42,Gyros,2024-03-01 11:30,,,,,Gesamt,Vegetarisch,1,total\n" +
+		"42,Gyros,2024-03-01 11:30,,,,,Gesamt,Alt,1,total\n"
+	if string(data) != want {
+		t.Errorf("CSV() =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestCSVFormulas(t *testing.T) {
+	entries := []Entry{{
+		Gyroskop: database.Gyroskop{ID: 1, Name: "=HYPERLINK(\"x\")", FoodOptions: []string{"+Cola", "-Wasser"}},
+		Orders:   []database.Order{{UserID: 1, FirstName: "@SUM(A1)", LastName: "Test", Quantities: map[string]int{"+Cola": 1, "-Wasser": 2}}},
+	}}
+	data, err := CSV(entries, time.UTC)
+	if err != nil {
+		t.Fatalf("CSV() error = %v", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+		for _, cell := range strings.Split(line, ",") {
+			cell = strings.Trim(cell, `"`)
+			if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+				t.Errorf("CSV() cell %q in %q starts a formula", cell, line)
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := JSON(testEntries())
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+
+	var doc struct {
+		Gyroskops []struct {
+			ID         int              `json:"id"`
+			Orders     []database.Order `json:"orders"`
+			Totals     map[string]int   `json:"totals"`
+			TotalItems int              `json:"total_items"`
+		} `json:"gyroskops"`
+		TotalItems int `json:"total_items"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSON() produced invalid JSON: %v", err)
+	}
+
+	if len(doc.Gyroskops) != 1 || doc.Gyroskops[0].ID != 42 || len(doc.Gyroskops[0].Orders) != 2 {
+		t.Fatalf("JSON() unexpected content: %s", data)
+	}
+	if doc.Gyroskops[0].Totals["Fleisch"] != 3 || doc.TotalItems != 5 {
+		t.Errorf("JSON() totals = %v (%d), want Fleisch=3 and 5 items", doc.Gyroskops[0].Totals, doc.TotalItems)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	text := string(Markdown(testEntries(), time.UTC))
+
+	for _, want := range []string{
+		"# Gyros (#42)",
+		"Deadline: 01.03.2024 11:30",
+		"| Name | Fleisch | Vegetarisch | Alt | Summe |",
+		"| Anna | 2 | 0 | 0 | 2 |",
+		"| @ben_b | 1 | 1 | 1 | 3 |",
+		"| **Gesamt** | **3** | **1** | **1** | **5** |",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Markdown() missing %q in:\n%s", want, text)
+		}
+	}
+
+	empty := string(Markdown([]Entry{{Gyroskop: database.Gyroskop{ID: 1, Name: "Pizza"}}}, time.UTC))
+	if !strings.Contains(empty, "Keine Bestellungen.") {
+		t.Errorf("Markdown() for empty gyroskop = %q", empty)
+	}
+}
+
+func TestSheet(t *testing.T) {
+	entries := testEntries()
+	entries[0].Contact = Contact{Name: "Luigi", Phone: "0711 123456"}
+
+	want := "BESTELLZETTEL Gyros (#42)\n" +
+		"Restaurant: Luigi\n" +
+		"Telefon: 0711 123456\n" +
+		"Bestellschluss: 01.03.2024 11:30\n\n" +
+		"3x Fleisch\n" +
+		"1x Vegetarisch\n" +
+		"1x Alt\n" +
+		"Gesamt: 5\n\n" +
+		"Verteilung:\n" +
+		"Fleisch (3)\n" +
+		"  - Anna: 2\n" +
+		"  - @ben_b: 1\n" +
+		"Vegetarisch (1)\n" +
+		"  - @ben_b: 1\n" +
+		"Alt (1)\n" +
+		"  - @ben_b: 1\n"
+	if got := string(Sheet(entries, time.UTC)); got != want {
+		t.Errorf("Sheet() =\n%s\nwant\n%s", got, want)
+	}
+
+	empty := string(Sheet([]Entry{{Gyroskop: database.Gyroskop{ID: 1, Name: "Pizza"}}}, time.UTC))
+	if strings.Contains(empty, "Restaurant:") || !strings.Contains(empty, "Keine Bestellungen.") {
+		t.Errorf("Sheet() for empty gyroskop without contact = %q", empty)
+	}
+}
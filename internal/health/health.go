@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Check is a named readiness check
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// checkTimeout bounds the time a single readiness check may take
+const checkTimeout = 5 * time.Second
+
+// Register adds /healthz (process up) and /readyz (all checks pass) to a mux
+func Register(mux *http.ServeMux, checks ...Check) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		results := make(map[string]string, len(checks)+1)
+
+		for _, check := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			err := check.Check(ctx)
+			cancel()
+
+			// Errors can contain secrets such as the bot token in request URLs,
+			// so they are only logged by the redacting default logger
+			if err != nil {
+				slog.WarnContext(r.Context(), "Readiness check failed", "check", check.Name, "error", err)
+				status = http.StatusServiceUnavailable
+				results[check.Name] = "unavailable"
+				continue
+			}
+			results[check.Name] = "ok"
+		}
+
+		if status == http.StatusOK {
+			results["status"] = "ok"
+		} else {
+			results["status"] = "unavailable"
+		}
+		writeStatus(w, status, results)
+	})
+}
+
+// writeStatus writes a JSON status response
+func writeStatus(w http.ResponseWriter, status int, body map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error writing health response", "error", err)
+	}
+}
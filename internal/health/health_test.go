@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpoints(t *testing.T) {
+	ok := Check{Name: "database", Check: func(ctx context.Context) error { return nil }}
+	failing := Check{Name: "telegram", Check: func(ctx context.Context) error {
+		return errors.New("Get \"https://api.telegram.org/bot123456:secret/getMe\": unreachable")
+	}}
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		path       string
+		wantStatus int
+		wantBody   map[string]string
+	}{
+		{"healthz ignores checks", []Check{failing}, "/healthz", http.StatusOK, map[string]string{"status": "ok"}},
+		{"readyz all ok", []Check{ok}, "/readyz", http.StatusOK, map[string]string{"status": "ok", "database": "ok"}},
+		{"readyz failing check", []Check{ok, failing}, "/readyz", http.StatusServiceUnavailable,
+			map[string]string{"status": "unavailable", "database": "ok", "telegram": "unavailable"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			Register(mux, tt.checks...)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			for key, want := range tt.wantBody {
+				if body[key] != want {
+					t.Errorf("%s = %q, want %q", key, body[key], want)
+				}
+			}
+		})
+	}
+}
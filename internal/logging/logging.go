@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Output formats supported by New
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// tokenRegex matches Telegram bot tokens, e.g. in request URLs contained in error strings
+var tokenRegex = regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`)
+
+// Redact replaces Telegram bot tokens in a string
+func Redact(s string) string {
+	return tokenRegex.ReplaceAllString(s, "[REDACTED]")
+}
+
+// ParseLevel parses a log level (debug, info, warn, error)
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", level)
+	}
+	return l, nil
+}
+
+// New creates a logger writing to w with the given level and format (text or json).
+// Telegram bot tokens are redacted from messages, string values and errors, and
+// attributes added to the context with With are added to every record.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: l, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (use text or json)", format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// redactAttr removes Telegram bot tokens from string and error attributes
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// contextKey is the context key for attributes added with With
+type contextKey struct{}
+
+// With returns a context whose attributes are added to every record logged with it,
+// e.g. the update, chat, user and gyroskop ID of the update being handled
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append(Attrs(ctx), argsToAttrs(args)...)
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+// Attrs returns the attributes added to a context with With
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs[:len(attrs):len(attrs)]
+}
+
+// argsToAttrs converts alternating keys and values to attributes like slog.Logger.With
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// contextHandler adds the attributes of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		r.AddAttrs(Attrs(ctx)...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
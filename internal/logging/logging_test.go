@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"no token", "connection refused", "connection refused"},
+		{"token in URL", `Post "https://api.telegram.org/bot` + testToken + `/getMe": EOF`, `Post "https://api.telegram.org/bot[REDACTED]/getMe": EOF`},
+		{"bare token", testToken, "[REDACTED]"},
+		{"time is no token", "deadline 17:30", "deadline 17:30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.input); got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "debug", "json")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := With(context.Background(), "update_id", 7, "chat_id", int64(-100))
+	ctx = With(ctx, "gyroskop_id", 42)
+	logger.ErrorContext(ctx, "Fehler beim Senden der Nachricht bot"+testToken, "error", errors.New("request to bot"+testToken+" failed"))
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+
+	if strings.Contains(buf.String(), testToken) {
+		t.Errorf("token not redacted: %s", buf.String())
+	}
+	for key, want := range map[string]interface{}{"update_id": 7.0, "chat_id": -100.0, "gyroskop_id": 42.0, "level": "ERROR"} {
+		if record[key] != want {
+			t.Errorf("%s = %v, want %v", key, record[key], want)
+		}
+	}
+}
+
+func TestNewLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "loud", "text"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("expected error for invalid format")
+	}
+}
@@ -0,0 +1,279 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics exported by the bot
+var (
+	GyroskopsOpened = NewCounterVec(
+		"gyroskop_gyroskops_opened_total",
+		"Number of gyroskops opened or reopened.",
+		"source",
+	)
+	GyroskopsClosed = NewCounterVec(
+		"gyroskop_gyroskops_closed_total",
+		"Number of gyroskops closed.",
+		"reason",
+	)
+	OrdersPlaced = NewCounterVec(
+		"gyroskop_orders_placed_total",
+		"Number of orders placed or changed.",
+		"source",
+	)
+	ParseFailures = NewCounterVec(
+		"gyroskop_parse_failures_total",
+		"Number of user inputs that could not be parsed.",
+		"kind",
+	)
+	TelegramAPIErrors = NewCounterVec(
+		"gyroskop_telegram_api_errors_total",
+		"Number of failed Telegram Bot API requests.",
+		"method",
+	)
+	TelegramRetries = NewCounterVec(
+		"gyroskop_telegram_retries_total",
+		"Number of Telegram Bot API requests retried after a flood limit or transient error.",
+		"reason",
+	)
+	TelegramEditsCoalesced = NewCounterVec(
+		"gyroskop_telegram_edits_coalesced_total",
+		"Number of queued message edits replaced by a newer edit of the same message.",
+	)
+	HandlerDuration = NewHistogramVec(
+		"gyroskop_handler_duration_seconds",
+		"Time spent handling a Telegram update.",
+		DefaultBuckets,
+		"handler",
+	)
+)
+
+// DefaultBuckets are the default histogram buckets in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector is a metric that can write itself in the Prometheus text format
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+// register adds a metric to the set of metrics served by Handler
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// Handler serves all metrics in the Prometheus text exposition format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func WriteTo(w io.Writer) {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// CounterVec is a counter partitioned by label values
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec creates and registers a new counter
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key := labelKey(c.labelNames, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += value
+}
+
+// Value returns the current value for the given label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := labelKey(c.labelNames, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, key, formatFloat(c.values[key]))
+	}
+}
+
+// HistogramVec is a histogram partitioned by label values
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+// histogram holds the observations for one set of label values
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // Non-cumulative count per bucket
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec creates and registers a new histogram with the given upper bucket bounds
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, buckets: buckets, labelNames: labelNames, values: make(map[string]*histogram)}
+	register(h)
+	return h
+}
+
+// Observe adds an observation for the given label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := labelKey(h.labelNames, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+// ObserveDuration observes the time elapsed since start in seconds
+func (h *HistogramVec) ObserveDuration(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hist := h.values[key]
+		names := append(append([]string(nil), h.labelNames...), "le")
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			values := append(append([]string(nil), hist.labelValues...), formatFloat(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelKey(names, values), cumulative)
+		}
+		values := append(append([]string(nil), hist.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelKey(names, values), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, key, formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, key, hist.count)
+	}
+}
+
+// labelValueEscaper escapes label values as required by the text exposition format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelKey formats label names and values as {name="value",...}.
+// Missing values are treated as empty strings.
+func labelKey(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(value))
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// sortedKeys returns the keys of a map in sorted order for stable output
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats a value like the Prometheus client libraries do
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
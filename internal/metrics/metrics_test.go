@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	c := &CounterVec{name: "test_total", help: "Test counter.", labelNames: []string{"source"}, values: make(map[string]float64)}
+	c.Inc("text")
+	c.Inc("text")
+	c.Add(3, "api")
+
+	if got := c.Value("text"); got != 2 {
+		t.Errorf("Value(text) = %v, want 2", got)
+	}
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	want := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{source="api"} 3
+test_total{source="text"} 2
+`
+	if buf.String() != want {
+		t.Errorf("write() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := &HistogramVec{name: "test_seconds", help: "Test histogram.", buckets: []float64{0.1, 1}, labelNames: []string{"handler"}, values: make(map[string]*histogram)}
+	h.Observe(0.05, "command")
+	h.Observe(0.5, "command")
+	h.Observe(5, "command")
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	want := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{handler="command",le="0.1"} 1
+test_seconds_bucket{handler="command",le="1"} 2
+test_seconds_bucket{handler="command",le="+Inf"} 3
+test_seconds_sum{handler="command"} 5.55
+test_seconds_count{handler="command"} 3
+`
+	if buf.String() != want {
+		t.Errorf("write() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestLabelKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  []string
+		values []string
+		want   string
+	}{
+		{"no labels", nil, nil, ""},
+		{"single label", []string{"method"}, []string{"sendMessage"}, `{method="sendMessage"}`},
+		{"missing value", []string{"a", "b"}, []string{"x"}, `{a="x",b=""}`},
+		{"escaping", []string{"kind"}, []string{"a\"b\\c\nd"}, `{kind="a\"b\\c\nd"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := labelKey(tt.names, tt.values); got != tt.want {
+				t.Errorf("labelKey() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteToContainsRegisteredMetrics(t *testing.T) {
+	GyroskopsOpened.Inc("command")
+
+	var buf bytes.Buffer
+	WriteTo(&buf)
+	for _, name := range []string{
+		"gyroskop_gyroskops_opened_total",
+		"gyroskop_gyroskops_closed_total",
+		"gyroskop_orders_placed_total",
+		"gyroskop_parse_failures_total",
+		"gyroskop_telegram_api_errors_total",
+		"gyroskop_handler_duration_seconds",
+	} {
+		if !strings.Contains(buf.String(), "# TYPE "+name) {
+			t.Errorf("output does not contain %s", name)
+		}
+	}
+	if !strings.Contains(buf.String(), `gyroskop_gyroskops_opened_total{source="command"}`) {
+		t.Errorf("output does not contain the incremented counter")
+	}
+}
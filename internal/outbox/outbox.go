@@ -0,0 +1,496 @@
+// Package outbox sends requests to the Telegram Bot API through a queue that
+// respects Telegram's flood limits, retries failed requests and coalesces
+// pending edits of the same message.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tionis/gyroskop/internal/metrics"
+)
+
+// ErrClosed is returned for requests queued after Close
+var ErrClosed = errors.New("outbox is closed")
+
+// Sender performs Telegram Bot API requests, implemented by tgbotapi.BotAPI
+type Sender interface {
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// BuildFunc builds a queued edit when it is sent, so it always shows the
+// latest state. It returns nil if there is nothing to send anymore.
+type BuildFunc func(ctx context.Context) (tgbotapi.Chattable, error)
+
+// Rate allows Burst requests at once and Count requests per Per on average
+type Rate struct {
+	Count int
+	Per   time.Duration
+	Burst int
+}
+
+// Config configures the limits and retries of a Dispatcher
+type Config struct {
+	Global      Rate          // Limit of all requests
+	PerChat     Rate          // Limit of the requests to a single chat
+	MaxAttempts int           // Attempts per request including the first one
+	Backoff     time.Duration // Delay before the first retry of a transient failure, doubled for every further one
+	Timeout     time.Duration // Timeout of building a queued edit
+}
+
+// DefaultConfig follows the limits documented by Telegram: about 30 messages
+// per second overall and 20 messages per minute in a group
+var DefaultConfig = Config{
+	Global:      Rate{Count: 30, Per: time.Second, Burst: 30},
+	PerChat:     Rate{Count: 20, Per: time.Minute, Burst: 5},
+	MaxAttempts: 4,
+	Backoff:     time.Second,
+	Timeout:     10 * time.Second,
+}
+
+// Dispatcher sends queued requests in the background. Requests to the same
+// chat are sent one after another in the order they were queued.
+type Dispatcher struct {
+	sender Sender
+	config Config
+	log    *slog.Logger
+
+	mu       sync.Mutex
+	chats    map[int64]*chat
+	global   *bucket
+	seq      uint64 // Order in which jobs were queued
+	inFlight int
+	closing  bool
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// chat is the queue of one chat
+type chat struct {
+	jobs    []*job
+	limit   *bucket
+	blocked time.Time // No requests before this time, set by retry_after and backoff
+	busy    bool      // A request is in flight, keeps the requests of the chat in order
+}
+
+// job is a queued request
+type job struct {
+	ctx       context.Context
+	seq       uint64
+	chatID    int64
+	messageID int // Set for edits, which are coalesced
+	chattable tgbotapi.Chattable
+	build     BuildFunc
+	attempts  int
+	done      chan result // Nil for edits, which nobody waits for
+}
+
+// result is the outcome of a job
+type result struct {
+	resp *tgbotapi.APIResponse
+	err  error
+}
+
+// New creates a dispatcher and starts sending in the background
+func New(sender Sender, config Config, logger *slog.Logger) *Dispatcher {
+	d := &Dispatcher{
+		sender: sender,
+		config: config,
+		log:    logger,
+		chats:  make(map[int64]*chat),
+		global: newBucket(config.Global),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Send queues a request to a chat and waits until it was sent. Requests that
+// do not post to a chat, like answering callback queries, use chatID 0 and
+// are only subject to the global limit.
+func (d *Dispatcher) Send(ctx context.Context, chatID int64, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	j := &job{ctx: ctx, chatID: chatID, chattable: c, done: make(chan result, 1)}
+	if err := d.enqueue(j); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-j.done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Edit queues an edit of a message without waiting for it. A pending edit of
+// the same message is replaced, so only the latest state is sent. Errors are
+// logged with ctx.
+func (d *Dispatcher) Edit(ctx context.Context, chatID int64, messageID int, build BuildFunc) {
+	j := &job{ctx: context.WithoutCancel(ctx), chatID: chatID, messageID: messageID, build: build}
+	if err := d.enqueue(j); err != nil {
+		d.log.ErrorContext(ctx, "Error queueing message edit", "error", err)
+	}
+}
+
+// Close stops accepting requests and waits until the queued ones are sent.
+// If ctx is done first, the remaining requests are dropped.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closing = true
+	d.mu.Unlock()
+	d.signal()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.stopOnce.Do(func() { close(d.stop) })
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dropped := 0
+	for _, c := range d.chats {
+		for _, j := range c.jobs {
+			if j.done != nil {
+				j.done <- result{err: ErrClosed}
+			}
+			dropped++
+		}
+		c.jobs = nil
+	}
+	return fmt.Errorf("%d queued requests dropped: %w", dropped, ctx.Err())
+}
+
+// enqueue adds a job to the queue of its chat, replacing a pending edit of the same message
+func (d *Dispatcher) enqueue(j *job) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closing {
+		return ErrClosed
+	}
+
+	c := d.chat(j.chatID)
+	if pending := c.pendingEdit(j.messageID); pending != nil {
+		pending.ctx = j.ctx
+		pending.build = j.build
+		metrics.TelegramEditsCoalesced.Inc()
+		return nil
+	}
+
+	d.seq++
+	j.seq = d.seq
+	c.jobs = append(c.jobs, j)
+	d.signal()
+	return nil
+}
+
+// chat returns the queue of a chat, creating it if necessary. Chat 0 has no per-chat limit.
+func (d *Dispatcher) chat(chatID int64) *chat {
+	c, ok := d.chats[chatID]
+	if !ok {
+		c = &chat{}
+		if chatID != 0 {
+			c.limit = newBucket(d.config.PerChat)
+		}
+		d.chats[chatID] = c
+	}
+	return c
+}
+
+// pendingEdit returns the queued edit of a message, if any
+func (c *chat) pendingEdit(messageID int) *job {
+	if messageID == 0 {
+		return nil
+	}
+	for _, j := range c.jobs {
+		if j.messageID == messageID {
+			return j
+		}
+	}
+	return nil
+}
+
+// signal wakes up the dispatch loop
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run starts queued jobs as soon as the limits allow until the dispatcher is
+// closed and all jobs are done
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for {
+		d.mu.Lock()
+		wait, finished := d.dispatch(time.Now())
+		d.mu.Unlock()
+		if finished {
+			return
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-d.wake:
+		case <-timeout:
+		case <-d.stop:
+			return
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// dispatch starts all jobs that are ready. It returns how long to wait until
+// the next job could be ready (0 if none is waiting for a limit) and whether
+// the dispatcher is closed and done.
+func (d *Dispatcher) dispatch(now time.Time) (time.Duration, bool) {
+	for {
+		var next *chat
+		var wait time.Duration
+		pending := false
+
+		for chatID, c := range d.chats {
+			c.dropCancelled()
+			if len(c.jobs) == 0 {
+				if !c.busy && c.limit.full(now) {
+					delete(d.chats, chatID)
+				}
+				continue
+			}
+
+			pending = true
+			if c.busy {
+				continue
+			}
+			if w := c.wait(now); w > 0 {
+				wait = minWait(wait, w)
+				continue
+			}
+			if next == nil || c.jobs[0].seq < next.jobs[0].seq {
+				next = c
+			}
+		}
+
+		if next == nil {
+			return wait, d.closing && !pending && d.inFlight == 0
+		}
+		if w := d.global.wait(now); w > 0 {
+			return minWait(wait, w), false
+		}
+
+		d.global.take(now)
+		next.limit.take(now)
+
+		j := next.jobs[0]
+		next.jobs = next.jobs[1:]
+		if j.chatID != 0 {
+			next.busy = true
+		}
+		d.inFlight++
+		go d.execute(j)
+	}
+}
+
+// dropCancelled removes jobs whose caller stopped waiting
+func (c *chat) dropCancelled() {
+	jobs := c.jobs[:0]
+	for _, j := range c.jobs {
+		if err := j.ctx.Err(); err != nil && j.done != nil {
+			j.done <- result{err: err}
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	c.jobs = jobs
+}
+
+// wait returns how long until the chat may send its next request
+func (c *chat) wait(now time.Time) time.Duration {
+	wait := c.limit.wait(now)
+	if blocked := c.blocked.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	return wait
+}
+
+// execute sends a job and either retries it or reports the result
+func (d *Dispatcher) execute(j *job) {
+	resp, err := d.request(j)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.signal()
+
+	d.inFlight--
+	c := d.chat(j.chatID)
+	c.busy = false
+
+	if err != nil && j.attempts < d.config.MaxAttempts {
+		if delay, reason, ok := d.retryDelay(err, j.attempts); ok {
+			metrics.TelegramRetries.Inc(reason)
+			d.log.WarnContext(j.ctx, "Retrying Telegram request", "method", method(j.chattable), "attempt", j.attempts, "delay", delay, "error", err)
+
+			c.blocked = time.Now().Add(delay)
+			// A newer edit of the same message makes the retry unnecessary
+			if c.pendingEdit(j.messageID) == nil {
+				c.jobs = append([]*job{j}, c.jobs...)
+			}
+			return
+		}
+	}
+
+	if j.done != nil {
+		j.done <- result{resp: resp, err: err}
+		return
+	}
+	if err != nil && !isNotModified(err) {
+		d.log.ErrorContext(j.ctx, "Error sending queued request", "method", method(j.chattable), "error", err)
+	}
+}
+
+// request builds the request of a job if necessary and sends it
+func (d *Dispatcher) request(j *job) (*tgbotapi.APIResponse, error) {
+	j.attempts++
+
+	if j.build != nil {
+		ctx, cancel := context.WithTimeout(j.ctx, d.config.Timeout)
+		defer cancel()
+
+		c, err := j.build(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, nil
+		}
+		j.chattable = c
+	}
+
+	resp, err := d.sender.Request(j.chattable)
+	if err != nil {
+		metrics.TelegramAPIErrors.Inc(method(j.chattable))
+	}
+	return resp, err
+}
+
+// retryDelay returns whether a failed request should be retried and after which delay
+func (d *Dispatcher) retryDelay(err error, attempts int) (time.Duration, string, bool) {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.RetryAfter > 0:
+			return time.Duration(apiErr.RetryAfter) * time.Second, "retry_after", true
+		case apiErr.Code >= 500:
+			return d.config.Backoff << (attempts - 1), "transient", true
+		default:
+			return 0, "", false
+		}
+	}
+
+	// Network errors, unreadable responses and failures to build an edit
+	return d.config.Backoff << (attempts - 1), "transient", true
+}
+
+// isNotModified reports whether an edit failed only because nothing changed
+func isNotModified(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Message, "message is not modified")
+}
+
+// method returns a metric label for a chattable, e.g. "MessageConfig"
+func method(c tgbotapi.Chattable) string {
+	if c == nil {
+		return "unknown"
+	}
+	name := fmt.Sprintf("%T", c)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// minWait returns the shorter of two waits, where 0 means no wait yet
+func minWait(a, b time.Duration) time.Duration {
+	if a == 0 || b < a {
+		return b
+	}
+	return a
+}
+
+// bucket is a token bucket rate limiter. A nil bucket does not limit.
+type bucket struct {
+	rate   float64 // Tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newBucket creates a full bucket for a rate, or nil if the rate is unlimited
+func newBucket(r Rate) *bucket {
+	if r.Count <= 0 || r.Per <= 0 {
+		return nil
+	}
+	burst := math.Max(float64(r.Burst), 1)
+	return &bucket{rate: float64(r.Count) / r.Per.Seconds(), burst: burst, tokens: burst}
+}
+
+// refill adds the tokens accumulated since the last call
+func (b *bucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// wait returns how long until a token is available
+func (b *bucket) wait(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take uses up a token
+func (b *bucket) take(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.refill(now)
+	b.tokens--
+}
+
+// full reports whether the bucket has refilled completely, so it can be dropped
+func (b *bucket) full(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.refill(now)
+	return b.tokens >= b.burst
+}
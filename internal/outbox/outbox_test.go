@@ -0,0 +1,267 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeSender records the texts of sent messages and edits and fails with the queued errors
+type fakeSender struct {
+	mu    sync.Mutex
+	sent  []string
+	times []time.Time
+	errs  []error
+	block chan struct{} // If set, every request waits for it
+}
+
+func (s *fakeSender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.times = append(s.times, time.Now())
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		s.sent = append(s.sent, c.Text)
+	case tgbotapi.EditMessageTextConfig:
+		s.sent = append(s.sent, c.Text)
+	}
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (s *fakeSender) requests() ([]string, []time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sent...), append([]time.Time(nil), s.times...)
+}
+
+func newTestDispatcher(t *testing.T, sender Sender, config Config) *Dispatcher {
+	t.Helper()
+	d := New(sender, config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		d.Close(ctx)
+	})
+	return d
+}
+
+func testConfig() Config {
+	return Config{MaxAttempts: 3, Backoff: time.Millisecond, Timeout: time.Second}
+}
+
+func editText(text string) BuildFunc {
+	return func(ctx context.Context) (tgbotapi.Chattable, error) {
+		return tgbotapi.NewEditMessageText(1, 10, text), nil
+	}
+}
+
+func TestPerChatLimit(t *testing.T) {
+	sender := &fakeSender{}
+	config := testConfig()
+	config.PerChat = Rate{Count: 10, Per: time.Second, Burst: 1}
+	d := newTestDispatcher(t, sender, config)
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := d.Send(ctx, 1, tgbotapi.NewMessage(1, "a")); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("3 requests to one chat took %v, want at least 200ms", elapsed)
+	}
+
+	// Other chats and requests without a chat are not limited by chat 1
+	start = time.Now()
+	if _, err := d.Send(ctx, 2, tgbotapi.NewMessage(2, "b")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if _, err := d.Send(ctx, 0, tgbotapi.NewCallback("1", "c")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("requests to other chats took %v, want no delay", elapsed)
+	}
+}
+
+func TestGlobalLimit(t *testing.T) {
+	sender := &fakeSender{}
+	config := testConfig()
+	config.Global = Rate{Count: 20, Per: time.Second, Burst: 1}
+	d := newTestDispatcher(t, sender, config)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for chatID := int64(1); chatID <= 3; chatID++ {
+		wg.Add(1)
+		go func(chatID int64) {
+			defer wg.Done()
+			d.Send(context.Background(), chatID, tgbotapi.NewMessage(chatID, "a"))
+		}(chatID)
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	floodErr := &tgbotapi.Error{Code: 429, Message: "Too Many Requests: retry after 1", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+	serverErr := &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}
+	badRequest := &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}
+	networkErr := errors.New("connection reset by peer")
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantErr      error
+		wantRequests int
+		minDuration  time.Duration
+	}{
+		{"success", nil, nil, 1, 0},
+		{"retry after", []error{floodErr}, nil, 2, time.Second},
+		{"server error", []error{serverErr, serverErr}, nil, 3, 0},
+		{"network error", []error{networkErr}, nil, 2, 0},
+		{"gives up", []error{networkErr, networkErr, networkErr}, networkErr, 3, 0},
+		{"bad request", []error{badRequest}, badRequest, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &fakeSender{errs: tt.errs}
+			d := newTestDispatcher(t, sender, testConfig())
+
+			start := time.Now()
+			_, err := d.Send(context.Background(), 1, tgbotapi.NewMessage(1, "a"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Send() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, times := sender.requests(); len(times) != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", len(times), tt.wantRequests)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minDuration {
+				t.Errorf("Send() took %v, want at least %v", elapsed, tt.minDuration)
+			}
+		})
+	}
+}
+
+func TestEditCoalescing(t *testing.T) {
+	sender := &fakeSender{block: make(chan struct{})}
+	d := newTestDispatcher(t, sender, testConfig())
+	ctx := context.Background()
+
+	// The first edit is in flight while the others are queued
+	d.Edit(ctx, 1, 10, editText("1"))
+	time.Sleep(20 * time.Millisecond)
+	d.Edit(ctx, 1, 10, editText("2"))
+	d.Edit(ctx, 1, 10, editText("3"))
+	d.Edit(ctx, 1, 10, editText("4"))
+	close(sender.block)
+
+	closeCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := d.Close(closeCtx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	sent, _ := sender.requests()
+	if len(sent) != 2 || sent[0] != "1" || sent[1] != "4" {
+		t.Errorf("sent = %v, want [1 4]", sent)
+	}
+}
+
+func TestOrderWithinChat(t *testing.T) {
+	sender := &fakeSender{}
+	config := testConfig()
+	config.PerChat = Rate{Count: 100, Per: time.Second, Burst: 1}
+	d := newTestDispatcher(t, sender, config)
+	ctx := context.Background()
+
+	d.Edit(ctx, 1, 10, editText("1"))
+	d.Edit(ctx, 1, 11, editText("2"))
+	if _, err := d.Send(ctx, 1, tgbotapi.NewMessage(1, "3")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	sent, _ := sender.requests()
+	if len(sent) != 3 || sent[0] != "1" || sent[1] != "2" || sent[2] != "3" {
+		t.Errorf("sent = %v, want [1 2 3]", sent)
+	}
+}
+
+func TestClose(t *testing.T) {
+	sender := &fakeSender{}
+	d := newTestDispatcher(t, sender, testConfig())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := d.Send(ctx, 1, tgbotapi.NewMessage(1, "a")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send() after Close() error = %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	sender := &fakeSender{}
+	config := testConfig()
+	config.PerChat = Rate{Count: 1, Per: time.Hour, Burst: 1}
+	d := newTestDispatcher(t, sender, config)
+	ctx := context.Background()
+
+	d.Edit(ctx, 1, 10, editText("1"))
+	d.Edit(ctx, 1, 11, editText("2"))
+
+	closeCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := d.Close(closeCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := newBucket(Rate{Count: 2, Per: time.Second, Burst: 2})
+
+	for i := 0; i < 2; i++ {
+		if wait := b.wait(now); wait != 0 {
+			t.Fatalf("wait() = %v for token %d, want 0", wait, i+1)
+		}
+		b.take(now)
+	}
+	if wait := b.wait(now); wait != 500*time.Millisecond {
+		t.Errorf("wait() = %v with empty bucket, want 500ms", wait)
+	}
+	if b.full(now.Add(time.Second)) != true {
+		t.Errorf("full() = false after refilling, want true")
+	}
+
+	var unlimited *bucket
+	if wait := unlimited.wait(now); wait != 0 {
+		t.Errorf("wait() = %v for nil bucket, want 0", wait)
+	}
+}
@@ -0,0 +1,144 @@
+// Package payment builds payment requests: EPC QR codes for SEPA credit
+// transfers, which banking apps scan to fill in a transfer, and PayPal.me links.
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrInvalidIBAN   = errors.New("invalid IBAN")
+	ErrInvalidPayPal = errors.New("invalid PayPal.me name")
+	ErrInvalidName   = errors.New("invalid beneficiary name")
+	ErrInvalidAmount = errors.New("invalid amount")
+)
+
+// Limits of the EPC QR code, see EPC069-12
+const (
+	maxNameLength      = 70
+	maxReferenceLength = 140
+	maxAmount          = 99999999999 // 999.999.999,99 € in cents
+)
+
+// ibanPattern matches a normalised IBAN: country code, check digits and up to 30 letters or digits
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+
+// NormalizeIBAN removes spaces, upper-cases the IBAN and checks its check digits
+func NormalizeIBAN(input string) (string, error) {
+	iban := strings.ToUpper(strings.Join(strings.Fields(input), ""))
+	if !ibanPattern.MatchString(iban) {
+		return "", ErrInvalidIBAN
+	}
+
+	// The IBAN with the first four characters moved to the end, letters as
+	// numbers from A = 10, has to be 1 modulo 97
+	remainder := 0
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' {
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		} else {
+			remainder = (remainder*10 + int(c-'0')) % 97
+		}
+	}
+	if remainder != 1 {
+		return "", ErrInvalidIBAN
+	}
+	return iban, nil
+}
+
+// FormatIBAN groups an IBAN in blocks of four for reading
+func FormatIBAN(iban string) string {
+	var blocks []string
+	for len(iban) > 4 {
+		blocks = append(blocks, iban[:4])
+		iban = iban[4:]
+	}
+	return strings.Join(append(blocks, iban), " ")
+}
+
+// paypalPattern matches PayPal.me names
+var paypalPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,20}$`)
+
+// NormalizePayPal returns the PayPal.me name of a link like paypal.me/name or of @name
+func NormalizePayPal(input string) (string, error) {
+	name := strings.TrimSpace(input)
+	for _, prefix := range []string{"https://", "http://", "www.", "paypal.me/", "PayPal.Me/", "@"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	name = strings.TrimSuffix(name, "/")
+	if !paypalPattern.MatchString(name) {
+		return "", ErrInvalidPayPal
+	}
+	return name, nil
+}
+
+// PayPalLink returns the PayPal.me link requesting an amount in cents
+func PayPalLink(name string, cents int) string {
+	return fmt.Sprintf("https://paypal.me/%s/%d.%02dEUR", name, cents/100, cents%100)
+}
+
+// Transfer is a SEPA credit transfer in euros
+type Transfer struct {
+	Name      string // Beneficiary
+	IBAN      string // Normalised, see NormalizeIBAN
+	Amount    int    // In cents
+	Reference string // Shown to the beneficiary, e.g. "Gyroskop #42"
+}
+
+// ValidateName checks that a beneficiary name fits into an EPC QR code
+func ValidateName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength || strings.ContainsAny(name, "\r\n") {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// EPCPayload returns the content of the EPC QR code of a transfer: version
+// 002 without BIC, UTF-8, with the reference as unstructured remittance
+func EPCPayload(t Transfer) (string, error) {
+	if err := ValidateName(t.Name); err != nil {
+		return "", err
+	}
+	if _, err := NormalizeIBAN(t.IBAN); err != nil {
+		return "", err
+	}
+	if t.Amount <= 0 || t.Amount > maxAmount {
+		return "", ErrInvalidAmount
+	}
+
+	reference := strings.ReplaceAll(t.Reference, "\n", " ")
+	if utf8.RuneCountInString(reference) > maxReferenceLength {
+		reference = string([]rune(reference)[:maxReferenceLength])
+	}
+
+	return strings.Join([]string{
+		"BCD", // Service tag
+		"002", // Version
+		"1",   // Character set UTF-8
+		"SCT", // SEPA credit transfer
+		"",    // BIC, optional within the EEA
+		strings.TrimSpace(t.Name),
+		t.IBAN,
+		fmt.Sprintf("EUR%d.%02d", t.Amount/100, t.Amount%100),
+		"", // Purpose
+		"", // Structured reference
+		reference,
+	}, "\n"), nil
+}
+
+// QRCode returns the EPC QR code of a transfer as PNG image. The standard
+// requires error correction level M.
+func QRCode(t Transfer) ([]byte, error) {
+	payload, err := EPCPayload(t)
+	if err != nil {
+		return nil, err
+	}
+	return qrcode.Encode(payload, qrcode.Medium, 512)
+}
@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNormalizeIBAN(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"DE89 3704 0044 0532 0130 00", "DE89370400440532013000", false},
+		{"de89370400440532013000", "DE89370400440532013000", false},
+		{"AT61 1904 3002 3457 3201", "AT611904300234573201", false},
+		{"GB82 WEST 1234 5698 7654 32", "GB82WEST12345698765432", false},
+		{"DE88 3704 0044 0532 0130 00", "", true}, // Wrong check digits
+		{"DE89 3704", "", true},
+		{"1234567890123456", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeIBAN(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeIBAN(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatIBAN(t *testing.T) {
+	if got := FormatIBAN("DE89370400440532013000"); got != "DE89 3704 0044 0532 0130 00" {
+		t.Errorf("FormatIBAN() = %q", got)
+	}
+}
+
+func TestNormalizePayPal(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"annaschmidt", "annaschmidt", false},
+		{"@AnnaSchmidt", "AnnaSchmidt", false},
+		{"paypal.me/anna", "anna", false},
+		{"https://www.paypal.me/anna/", "anna", false},
+		{"https://evil.example/anna", "", true},
+		{"anna schmidt", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePayPal(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizePayPal(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestPayPalLink(t *testing.T) {
+	if got := PayPalLink("anna", 1205); got != "https://paypal.me/anna/12.05EUR" {
+		t.Errorf("PayPalLink() = %q", got)
+	}
+}
+
+func TestEPCPayload(t *testing.T) {
+	transfer := Transfer{Name: "Anna Schmidt", IBAN: "DE89370400440532013000", Amount: 1250, Reference: "Gyroskop #42"}
+	want := "BCD\n002\n1\nSCT\n\nAnna Schmidt\nDE89370400440532013000\nEUR12.50\n\n\nGyroskop #42"
+	if got, err := EPCPayload(transfer); err != nil || got != want {
+		t.Errorf("EPCPayload() = %q, %v, want %q", got, err, want)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(*Transfer)
+		wantErr error
+	}{
+		{"no name", func(t *Transfer) { t.Name = " " }, ErrInvalidName},
+		{"long name", func(t *Transfer) { t.Name = string(bytes.Repeat([]byte("a"), 71)) }, ErrInvalidName},
+		{"invalid IBAN", func(t *Transfer) { t.IBAN = "DE00370400440532013000" }, ErrInvalidIBAN},
+		{"no amount", func(t *Transfer) { t.Amount = 0 }, ErrInvalidAmount},
+		{"negative amount", func(t *Transfer) { t.Amount = -100 }, ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		invalid := transfer
+		tt.change(&invalid)
+		if _, err := EPCPayload(invalid); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: EPCPayload() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	png, err := QRCode(Transfer{Name: "Anna Schmidt", IBAN: "DE89370400440532013000", Amount: 1250, Reference: "Gyroskop #42"})
+	if err != nil {
+		t.Fatalf("QRCode() error = %v", err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("QRCode() did not return a PNG image")
+	}
+}
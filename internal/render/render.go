@@ -0,0 +1,160 @@
+// Package render builds Telegram messages from view models and templates. All
+// text is escaped for the parse mode of the chosen format, so that names
+// containing markup characters like Spaghetti_Carbonara or *Pizza* cannot
+// break a message.
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Format is a parse mode of the Telegram Bot API
+type Format int
+
+const (
+	HTML Format = iota
+	MarkdownV2
+)
+
+// ParseMode returns the parse mode to send messages in the format with
+func (f Format) ParseMode() string {
+	if f == MarkdownV2 {
+		return tgbotapi.ModeMarkdownV2
+	}
+	return tgbotapi.ModeHTML
+}
+
+var (
+	htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+	// All characters with a meaning in MarkdownV2, the backslash first
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	// Inside the URL of a MarkdownV2 link only ) and \ have to be escaped,
+	// inside preformatted text only ` and \
+	markdownV2URLEscaper = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+	markdownV2PreEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+)
+
+// Escape escapes text so that it is shown as is
+func (f Format) Escape(text string) string {
+	if f == MarkdownV2 {
+		return markdownV2Escaper.Replace(text)
+	}
+	return htmlEscaper.Replace(text)
+}
+
+// Span is a piece of text with one style
+type Span struct {
+	Text string
+	Bold bool
+	URL  string // Makes the span a link
+	Pre  bool   // Preformatted, e.g. source code
+	Code bool   // Monospace within a line, e.g. a token to copy
+}
+
+// Plain returns an unstyled span
+func Plain(text string) Span {
+	return Span{Text: text}
+}
+
+// Bold returns a bold span
+func Bold(text string) Span {
+	return Span{Text: text, Bold: true}
+}
+
+// Pre returns a preformatted span
+func Pre(text string) Span {
+	return Span{Text: text, Pre: true}
+}
+
+// Code returns a monospace span
+func Code(text string) Span {
+	return Span{Text: text, Code: true}
+}
+
+// Link returns a span linking to url
+func Link(text, url string) Span {
+	return Span{Text: text, URL: url}
+}
+
+// Line is text made of spans. Spans may contain line breaks.
+type Line []Span
+
+// Line renders a line
+func (f Format) Line(line Line) string {
+	var text strings.Builder
+	for _, span := range line {
+		text.WriteString(f.span(span))
+	}
+	return text.String()
+}
+
+// Lines renders lines, one below the other
+func (f Format) Lines(lines ...Line) string {
+	rendered := make([]string, len(lines))
+	for i, line := range lines {
+		rendered[i] = f.Line(line)
+	}
+	return strings.Join(rendered, "\n")
+}
+
+func (f Format) span(span Span) string {
+	switch {
+	case span.Text == "":
+		return ""
+	case span.Pre && f == MarkdownV2:
+		return "```\n" + markdownV2PreEscaper.Replace(span.Text) + "\n```"
+	case span.Pre:
+		return "<pre>" + htmlEscaper.Replace(span.Text) + "</pre>"
+	case span.Code && f == MarkdownV2:
+		return "`" + markdownV2PreEscaper.Replace(span.Text) + "`"
+	case span.Code:
+		return "<code>" + htmlEscaper.Replace(span.Text) + "</code>"
+	}
+
+	text := f.Escape(span.Text)
+	switch {
+	case span.URL != "" && f == MarkdownV2:
+		return "[" + text + "](" + markdownV2URLEscaper.Replace(span.URL) + ")"
+	case span.URL != "":
+		return `<a href="` + htmlEscaper.Replace(span.URL) + `">` + text + "</a>"
+	case span.Bold && f == MarkdownV2:
+		return "*" + text + "*"
+	case span.Bold:
+		return "<b>" + text + "</b>"
+	default:
+		return text
+	}
+}
+
+// Item is a quantity of a food option
+type Item struct {
+	Option   string
+	Quantity int
+}
+
+func (i Item) String() string {
+	return fmt.Sprintf("%d %s", i.Quantity, i.Option)
+}
+
+// Items formats items as a comma-separated list, e.g. "2 Fleisch, 1 Vegetarisch"
+func Items(items []Item) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = item.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Person is what someone ordered
+type Person struct {
+	Name  string
+	Items []Item
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		format Format
+		text   string
+		want   string
+	}{
+		{HTML, "Spaghetti_Carbonara", "Spaghetti_Carbonara"},
+		{HTML, "<b>Fett</b> & Co", "&lt;b&gt;Fett&lt;/b&gt; &amp; Co"},
+		{MarkdownV2, "Spaghetti_Carbonara", `Spaghetti\_Carbonara`},
+		{MarkdownV2, "*Pizza* (groß)!", `\*Pizza\* \(groß\)\!`},
+		{MarkdownV2, `C:\Döner`, `C:\\Döner`},
+		{MarkdownV2, "1.5 - [x]", `1\.5 \- \[x\]`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.Escape(tt.text); got != tt.want {
+			t.Errorf("Escape(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLine(t *testing.T) {
+	line := Line{Plain("📋 "), Bold("a_b"), Plain(" "), Link("Zur (Übersicht)", "https://t.me/c/1/2?a=1&b=(2)"), Plain("\n"), Pre("<b>{{.Name}}</b> `x`"), Plain(" "), Code("a_`b")}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{HTML, "📋 <b>a_b</b> <a href=\"https://t.me/c/1/2?a=1&amp;b=(2)\">Zur (Übersicht)</a>\n<pre>&lt;b&gt;{{.Name}}&lt;/b&gt; `x`</pre> <code>a_`b</code>"},
+		{MarkdownV2, "📋 *a\\_b* [Zur \\(Übersicht\\)](https://t.me/c/1/2?a=1&b=(2\\))\n```\n<b>{{.Name}}</b> \\`x\\`\n``` `a_\\`b`"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.Line(line); got != tt.want {
+			t.Errorf("Line() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	cases := []string{"visible", "hidden", "empty"} // In the order of sampleViews
+	for _, name := range TemplateNames {
+		tmpl := DefaultTemplate(name)
+		if err := tmpl.Validate(); err != nil {
+			t.Errorf("DefaultTemplate(%q).Validate() error = %v", name, err)
+		}
+
+		for i, view := range sampleViews() {
+			t.Run(name+"_"+cases[i], func(t *testing.T) {
+				got, err := tmpl.Execute(view)
+				if err != nil {
+					t.Fatalf("Execute() error = %v", err)
+				}
+
+				path := filepath.Join("testdata", name+"_"+cases[i]+".golden")
+				if *update {
+					if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("reading golden file (run with -update to create it): %v", err)
+				}
+				if got != string(want) {
+					t.Errorf("Execute() =\n%s\nwant\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestTemplateEscapesView(t *testing.T) {
+	view := sampleViews()[0]
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{HTML, "*Mittag* bei Luigi's_Pizza: 1 Spaghetti_Carbonara, 1 &lt;Pizza&gt; &amp; Co"},
+		{MarkdownV2, `\*Mittag\* bei Luigi's\_Pizza: 1 Spaghetti\_Carbonara, 1 <Pizza\> & Co`},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.format, "test", `{{.Gyroskop.Name}}: {{items (index .Orders 1).Items}}`)
+		if err != nil {
+			t.Fatalf("ParseTemplate() error = %v", err)
+		}
+		if got, err := tmpl.Execute(view); err != nil || got != tt.want {
+			t.Errorf("Execute() = %q, %v, want %q", got, err, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{"valid", "<b>{{.Gyroskop.Name}}</b> bis {{.Deadline.Format \"15:04\"}}: {{.Total}}", false},
+		{"unknown field", "{{.Restaurant}}", true},
+		{"unknown function", "{{upper .Creator}}", true},
+		{"index out of range", "{{(index .Orders 0).Name}}", true},
+		{"empty", "{{if .Hidden}}verdeckt{{end}}", true},
+		{"unclosed tag", "<b>{{.Gyroskop.Name}}", true},
+		{"unsupported tag", "<h1>{{.Gyroskop.Name}}</h1>", true},
+		{"raw ampersand", "Pizza & Pasta", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(HTML, "test", tt.text)
+			if err == nil {
+				err = tmpl.Validate()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
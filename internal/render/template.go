@@ -0,0 +1,202 @@
+package render
+
+import (
+	"embed"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Names of the message templates
+const (
+	TemplateLive    = "gyroskop"   // The gyroskop message while it is open
+	TemplateStatus  = "status"     // The reply to /status
+	TemplateSummary = "uebersicht" // The final summary
+)
+
+// TemplateNames are the names of all message templates
+var TemplateNames = []string{TemplateLive, TemplateStatus, TemplateSummary}
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Gyroskop is the gyroskop shown in a message
+type Gyroskop struct {
+	ID       int
+	Name     string
+	Reopened bool // Only set in the live message sent when reopening
+}
+
+// View is the data model of the message templates. All text in it is escaped
+// for the format of the template before the template is executed.
+type View struct {
+	Gyroskop     Gyroskop
+	Creator      string    // Name of who opened the gyroskop
+	Deadline     time.Time // In the time zone of the bot
+	Hidden       bool      // Orders is empty, only Participants and Totals are known
+	Orders       []Person  // Who ordered what
+	Participants int
+	Totals       []Item // In the order of the food options
+	Total        int    // Number of items ordered
+	Instructions string // How to order, only in the live message
+}
+
+// escaped returns a copy of the view with all text escaped for the format
+func (f Format) escaped(v View) View {
+	escapeItems := func(items []Item) []Item {
+		escaped := make([]Item, len(items))
+		for i, item := range items {
+			escaped[i] = Item{Option: f.Escape(item.Option), Quantity: item.Quantity}
+		}
+		return escaped
+	}
+
+	v.Gyroskop.Name = f.Escape(v.Gyroskop.Name)
+	v.Creator = f.Escape(v.Creator)
+	v.Instructions = f.Escape(v.Instructions)
+	v.Totals = escapeItems(v.Totals)
+	orders := make([]Person, len(v.Orders))
+	for i, person := range v.Orders {
+		orders[i] = Person{Name: f.Escape(person.Name), Items: escapeItems(person.Items)}
+	}
+	v.Orders = orders
+	return v
+}
+
+// Template is a parsed message template
+type Template struct {
+	format Format
+	text   string
+	tmpl   *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"items": Items,
+}
+
+// ParseTemplate parses a message template written in the markup of format
+func ParseTemplate(format Format, name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{format: format, text: text, tmpl: tmpl}, nil
+}
+
+// defaults are the built-in templates by name
+var defaults = parseDefaults()
+
+func parseDefaults() map[string]*Template {
+	templates := make(map[string]*Template, len(TemplateNames))
+	for _, name := range TemplateNames {
+		text, err := defaultTemplates.ReadFile("templates/" + name + ".tmpl")
+		if err != nil {
+			panic(err)
+		}
+		tmpl, err := ParseTemplate(HTML, name, string(text))
+		if err != nil {
+			panic(err)
+		}
+		templates[name] = tmpl
+	}
+	return templates
+}
+
+// DefaultTemplate returns the built-in HTML template of a message, nil for unknown names
+func DefaultTemplate(name string) *Template {
+	return defaults[name]
+}
+
+// Text returns the source of the template
+func (t *Template) Text() string {
+	return t.text
+}
+
+// Execute renders the template with view
+func (t *Template) Execute(view View) (string, error) {
+	var text strings.Builder
+	if err := t.tmpl.Execute(&text, t.format.escaped(view)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// Tags supported by the HTML parse mode of Telegram
+var htmlTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true, "s": true, "strike": true, "del": true,
+	"a": true, "code": true, "pre": true, "tg-spoiler": true, "span": true, "blockquote": true,
+}
+
+// Validate renders the template against sample views and checks that the
+// results are messages Telegram accepts
+func (t *Template) Validate() error {
+	for _, view := range sampleViews() {
+		text, err := t.Execute(view)
+		if err != nil {
+			return err
+		}
+		if text == "" {
+			return errors.New("the message is empty")
+		}
+		if t.format == HTML {
+			if err := validateHTML(text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateHTML checks that text only uses the tags Telegram supports and that they are closed
+func validateHTML(text string) error {
+	decoder := xml.NewDecoder(strings.NewReader("<message>" + text + "</message>"))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid HTML: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local != "message" && !htmlTags[start.Name.Local] {
+			return fmt.Errorf("unsupported HTML tag <%s>", start.Name.Local)
+		}
+	}
+}
+
+// sampleViews are the views templates are validated against: visible and
+// hidden orders with names full of markup characters, and no orders at all
+func sampleViews() []View {
+	deadline := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	totals := []Item{{"Spaghetti_Carbonara", 3}, {"<Pizza> & Co", 1}}
+	visible := View{
+		Gyroskop:     Gyroskop{ID: 42, Name: "*Mittag* bei Luigi's_Pizza"},
+		Creator:      "Anna_Lena",
+		Deadline:     deadline,
+		Participants: 2,
+		Orders: []Person{
+			{Name: "Anna_Lena", Items: []Item{{"Spaghetti_Carbonara", 2}}},
+			{Name: "[Ben](tg://user?id=1)", Items: []Item{{"Spaghetti_Carbonara", 1}, {"<Pizza> & Co", 1}}},
+		},
+		Totals:       totals,
+		Total:        4,
+		Instructions: "Zum Bestellen schreibt '2 spaghetti_carbonara' oder nutzt die Buttons unten.",
+	}
+
+	hidden := visible
+	hidden.Hidden = true
+	hidden.Orders = nil
+
+	empty := visible
+	empty.Gyroskop.Reopened = true
+	empty.Participants = 0
+	empty.Orders = nil
+	empty.Totals = nil
+	empty.Total = 0
+
+	return []View{visible, hidden, empty}
+}
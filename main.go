@@ -1,44 +1,147 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/tionis/gyroskop/internal/api"
 	"github.com/tionis/gyroskop/internal/bot"
+	"github.com/tionis/gyroskop/internal/config"
 	"github.com/tionis/gyroskop/internal/database"
+	"github.com/tionis/gyroskop/internal/health"
+	"github.com/tionis/gyroskop/internal/logging"
+	"github.com/tionis/gyroskop/internal/metrics"
 )
 
 func main() {
-	// Read bot token from environment variable
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if token == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN environment variable is required")
+	// Load configuration: defaults < config file < environment < flags
+	cfg, printConfig, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fatal("Error loading configuration", "error", err)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("Error printing configuration", "error", err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	// Set up structured logging; the standard log package is routed through it as well
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fatal("Error configuring logging", "error", err)
 	}
+	slog.SetDefault(logger)
+
+	// Cancelled on SIGINT or SIGTERM to shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Initialize database
-	db, err := database.Init()
+	db, err := database.Init(ctx, cfg.DatabaseURL, logger)
 	if err != nil {
-		log.Fatalf("Error initializing database: %v", err)
+		fatal("Error initializing database", "error", err)
 	}
 	defer db.Close()
 
 	// Start bot
-	bot, err := bot.New(token, db)
+	b, err := bot.New(cfg.TelegramToken, db, logger, bot.Defaults{
+		Duration:    time.Duration(cfg.Defaults.Duration),
+		Name:        cfg.Defaults.Name,
+		FoodOptions: cfg.Defaults.FoodOptions,
+		MaxQuantity: cfg.Defaults.MaxQuantity,
+		Location:    cfg.Location(),
+	})
 	if err != nil {
-		log.Fatalf("Error creating bot: %v", err)
+		fatal("Error creating bot", "error", err)
+	}
+
+	// Receive updates via webhook instead of long polling if configured
+	if cfg.Webhook.URL != "" {
+		err := b.UseWebhook(bot.WebhookConfig{
+			URL:         cfg.Webhook.URL,
+			ListenAddr:  cfg.Webhook.ListenAddr,
+			SecretToken: cfg.Webhook.SecretToken,
+		})
+		if err != nil {
+			fatal("Error configuring webhook", "error", err)
+		}
 	}
 
-	// Set up signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Start health, readiness and metrics endpoints if configured
+	var servers []*http.Server
+	opsMux := http.NewServeMux()
+	health.Register(opsMux,
+		health.Check{Name: "database", Check: db.PingContext},
+		health.Check{Name: "telegram", Check: b.Ping},
+	)
+	opsMux.Handle("/metrics", metrics.Handler())
 
-	// Start bot in a goroutine
-	go bot.Run()
+	// Start REST API if configured, sharing the listener if the address is the same
+	if cfg.APIListenAddr != "" {
+		if cfg.APIListenAddr == cfg.MetricsListenAddr {
+			opsMux.Handle("/api/", api.New(db, b, logger).Handler())
+		} else {
+			servers = append(servers, serve("REST API", cfg.APIListenAddr, api.New(db, b, logger).Handler()))
+		}
+	}
+	if cfg.MetricsListenAddr != "" {
+		servers = append(servers, serve("Health and metrics endpoints", cfg.MetricsListenAddr, opsMux))
+	}
+
+	// Run the bot until a shutdown signal is received; the HTTP servers keep
+	// serving until it has finished the pending work
+	runErr := b.Run(ctx)
+	stop()
+	if runErr != nil {
+		slog.Error("Error running bot", "error", runErr)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Error stopping HTTP server", "addr", server.Addr, "error", err)
+		}
+	}
+
+	if runErr != nil {
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+// serve starts an HTTP server in the background
+func serve(name, addr string, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		slog.Info(name+" listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Error running "+name, "error", err)
+		}
+	}()
+	return server
+}
 
-	// Wait for shutdown signal
-	<-sigChan
-	log.Println("Shutdown signal received, stopping bot...")
-	bot.Stop()
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }